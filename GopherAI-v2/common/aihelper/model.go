@@ -165,8 +165,8 @@ func NewAliRAGModel(ctx context.Context, username string) (*AliRAGModel, error)
 }
 
 func (o *AliRAGModel) GenerateResponse(ctx context.Context, messages []*schema.Message) (*schema.Message, error) {
-	// 1. 创建 RAG 查询器
-	ragQuery, err := rag.NewRAGQuery(ctx, o.username)
+	// 1. 获取 RAG 查询器（同一用户的查询器会被复用）
+	ragQuery, release, err := rag.AcquireQuery(ctx, o.username, rag.QueryOptions{})
 	if err != nil {
 		log.Printf("Failed to create RAG query (user may not have uploaded file): %v", err)
 		// 如果用户没有上传文件，直接使用原始问题
@@ -176,6 +176,7 @@ func (o *AliRAGModel) GenerateResponse(ctx context.Context, messages []*schema.M
 		}
 		return resp, nil
 	}
+	defer release()
 
 	// 2. 获取用户最后一条消息作为查询
 	if len(messages) == 0 {
@@ -185,7 +186,7 @@ func (o *AliRAGModel) GenerateResponse(ctx context.Context, messages []*schema.M
 	query := lastMessage.Content
 
 	// 3. 检索相关文档
-	docs, err := ragQuery.RetrieveDocuments(ctx, query)
+	docs, err := ragQuery.RetrieveDocuments(ctx, query, rag.RetrieveOptions{})
 	if err != nil {
 		log.Printf("Failed to retrieve documents: %v", err)
 		// 检索失败，使用原始问题
@@ -216,13 +217,14 @@ func (o *AliRAGModel) GenerateResponse(ctx context.Context, messages []*schema.M
 }
 
 func (o *AliRAGModel) StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback) (string, error) {
-	// 1. 创建 RAG 查询器
-	ragQuery, err := rag.NewRAGQuery(ctx, o.username)
+	// 1. 获取 RAG 查询器（同一用户的查询器会被复用）
+	ragQuery, release, err := rag.AcquireQuery(ctx, o.username, rag.QueryOptions{})
 	if err != nil {
 		log.Printf("Failed to create RAG query (user may not have uploaded file): %v", err)
 		// 如果用户没有上传文件，直接使用原始问题
 		return o.streamWithoutRAG(ctx, messages, cb)
 	}
+	defer release()
 
 	// 2. 获取用户最后一条消息作为查询
 	if len(messages) == 0 {
@@ -232,7 +234,7 @@ func (o *AliRAGModel) StreamResponse(ctx context.Context, messages []*schema.Mes
 	query := lastMessage.Content
 
 	// 3. 检索相关文档
-	docs, err := ragQuery.RetrieveDocuments(ctx, query)
+	docs, err := ragQuery.RetrieveDocuments(ctx, query, rag.RetrieveOptions{})
 	if err != nil {
 		log.Printf("Failed to retrieve documents: %v", err)
 		// 检索失败，使用原始问题
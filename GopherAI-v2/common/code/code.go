@@ -17,6 +17,10 @@ const (
 	CodeInvalidCaptcha   Code = 2008
 	CodeRecordNotFound   Code = 2009
 	CodeIllegalPassword  Code = 2010
+	CodeTooManyRequests  Code = 2011
+	CodeInvalidEmail     Code = 2012
+	CodeAccountLocked    Code = 2013
+	CodeQuotaExceeded    Code = 2014
 
 	CodeForbidden Code = 3001
 
@@ -41,7 +45,11 @@ var msg = map[Code]string{
 	CodeNotLogin:         "用户未登录",
 	CodeInvalidCaptcha:   "验证码错误",
 	CodeRecordNotFound:   "记录不存在",
-	CodeIllegalPassword:  "密码不合法",
+	CodeIllegalPassword:  "密码不合法，长度需为 8-64 位（不超过 72 字节）且同时包含字母和数字",
+	CodeTooManyRequests:  "请求过于频繁，请稍后再试",
+	CodeInvalidEmail:     "邮箱格式不正确",
+	CodeAccountLocked:    "登录失败次数过多，账号已被临时锁定，请稍后再试",
+	CodeQuotaExceeded:    "知识库存储配额已用完，请删除不需要的文件后再试",
 
 	CodeForbidden: "权限不足",
 
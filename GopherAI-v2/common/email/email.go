@@ -2,36 +2,177 @@ package email
 
 import (
 	"GopherAI/config"
+	"errors"
 	"fmt"
+	"log"
+	"net/textproto"
+	"sync"
+	"time"
 
 	"gopkg.in/gomail.v2"
 )
 
 const (
-	CodeMsg     = "GopherAI验证码如下(验证码仅限于2分钟有效): "
-	UserNameMsg = "GopherAI的账号如下，请保留好，后续可以用账号/邮箱登录 "
+	CodeMsg          = "GopherAI验证码如下(验证码仅限于2分钟有效): "
+	UserNameMsg      = "GopherAI的账号如下，请保留好，后续可以用账号/邮箱登录 "
+	ResetPasswordMsg = "GopherAI重置密码验证码如下(验证码仅限于15分钟有效，如非本人操作请忽略): "
+	EmailChangeMsg   = "GopherAI更换邮箱验证码如下(验证码仅限于15分钟有效，如非本人操作请忽略): "
 )
 
-func SendCaptcha(email, code, msg string) error {
-	m := gomail.NewMessage()
+// 未配置时使用的 SMTP 服务器，587：是 SMTP 的明文/STARTTLS 端口号
+const (
+	defaultSMTPHost = "smtp.qq.com"
+	defaultSMTPPort = 587
+)
+
+// 发送失败时的重试配置
+const (
+	defaultSendAttempts   = 3
+	defaultSendRetryDelay = time.Second // 第一次重试前的等待时间，之后每次翻倍
+)
+
+// Mailer 发送系统邮件
+type Mailer interface {
+	// SendCode 发送注册验证码
+	SendCode(to, code string) error
+	// SendUsername 发送注册生成的账号
+	SendUsername(to, username string) error
+	// SendResetCode 发送重置密码验证码
+	SendResetCode(to, code string) error
+	// SendEmailChangeCode 向新邮箱发送更换邮箱验证码
+	SendEmailChangeCode(to, code string) error
+}
+
+// SendError 邮件发送失败（已重试），可通过 errors.As 判断
+type SendError struct {
+	To       string
+	Attempts int
+	Err      error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("send mail to %s failed after %d attempt(s): %v", e.To, e.Attempts, e.Err)
+}
+
+func (e *SendError) Unwrap() error { return e.Err }
+
+// SMTPMailer 通过 SMTP 发送邮件，临时错误会按指数退避重试
+type SMTPMailer struct {
+	Host       string
+	Port       int
+	From       string // 发件人，同时作为 SMTP 登录账号
+	Authcode   string // SMTP 授权码
+	Attempts   int    // 最多尝试次数（包括第一次），0 表示默认 3 次
+	RetryDelay time.Duration
+}
+
+// NewSMTPMailer 按 emailConfig 创建 SMTP 发送器
+func NewSMTPMailer() *SMTPMailer {
+	conf := config.GetConfig().EmailConfig
+	m := &SMTPMailer{
+		Host:     conf.SmtpHost,
+		Port:     conf.SmtpPort,
+		From:     conf.Email,
+		Authcode: conf.Authcode,
+	}
+	if m.Host == "" {
+		m.Host = defaultSMTPHost
+	}
+	if m.Port == 0 {
+		m.Port = defaultSMTPPort
+	}
+	return m
+}
+
+func (m *SMTPMailer) SendCode(to, code string) error {
+	return m.send(to, CodeMsg+" "+code)
+}
 
+func (m *SMTPMailer) SendUsername(to, username string) error {
+	return m.send(to, UserNameMsg+" "+username)
+}
+
+func (m *SMTPMailer) SendResetCode(to, code string) error {
+	return m.send(to, ResetPasswordMsg+" "+code)
+}
+
+func (m *SMTPMailer) SendEmailChangeCode(to, code string) error {
+	return m.send(to, EmailChangeMsg+" "+code)
+}
+
+// send 发送纯文本邮件；SMTP 返回 5xx（地址不存在、认证失败等）时不再重试
+func (m *SMTPMailer) send(to, body string) error {
+	msg := gomail.NewMessage()
 	// 发件人
-	m.SetHeader("From", config.GetConfig().EmailConfig.Email)
+	msg.SetHeader("From", m.From)
 	// 收件人
-	m.SetHeader("To", email)
+	msg.SetHeader("To", to)
 	// 主题
-	m.SetHeader("Subject", "来自GopherAI的信息")
+	msg.SetHeader("Subject", "来自GopherAI的信息")
 	// 正文内容（纯文本形式，也可以用 text/html）
-	m.SetBody("text/plain", msg+" "+code)
+	msg.SetBody("text/plain", body)
+
+	d := gomail.NewDialer(m.Host, m.Port, m.From, m.Authcode)
+
+	attempts := m.Attempts
+	if attempts <= 0 {
+		attempts = defaultSendAttempts
+	}
+	delay := m.RetryDelay
+	if delay <= 0 {
+		delay = defaultSendRetryDelay
+	}
 
-	// 配置 SMTP 服务器和授权码,587：是 SMTP 的明文/STARTTLS 端口号
-	d := gomail.NewDialer("smtp.qq.com", 587, config.GetConfig().EmailConfig.Email, config.GetConfig().EmailConfig.Authcode)
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if err = d.DialAndSend(msg); err == nil {
+			return nil
+		}
+		log.Printf("send mail attempt %d/%d failed: %v", i, attempts, err)
+		if isPermanentSMTPError(err) {
+			return &SendError{To: to, Attempts: i, Err: err}
+		}
+		if i < attempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return &SendError{To: to, Attempts: attempts, Err: err}
+}
+
+// isPermanentSMTPError SMTP 5xx 错误重试也不会成功
+func isPermanentSMTPError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500 && protoErr.Code < 600
+}
+
+// NoopMailer 不发送任何邮件，用于测试和本地开发
+type NoopMailer struct{}
+
+func (NoopMailer) SendCode(string, string) error            { return nil }
+func (NoopMailer) SendUsername(string, string) error        { return nil }
+func (NoopMailer) SendResetCode(string, string) error       { return nil }
+func (NoopMailer) SendEmailChangeCode(string, string) error { return nil }
+
+var (
+	mailerMu sync.RWMutex
+	mailer   Mailer
+)
+
+// SetMailer 替换全局使用的邮件发送器，传入 nil 时恢复为按配置创建的 SMTP 发送器
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	mailer = m
+	mailerMu.Unlock()
+}
 
-	// 发送邮件
-	if err := d.DialAndSend(m); err != nil {
-		fmt.Printf("DialAndSend err %v:\n", err)
-		return err
+// GetMailer 返回全局使用的邮件发送器，未设置时按配置创建 SMTP 发送器
+func GetMailer() Mailer {
+	mailerMu.RLock()
+	m := mailer
+	mailerMu.RUnlock()
+	if m != nil {
+		return m
 	}
-	fmt.Printf("send mail success\n")
-	return nil
+	return NewSMTPMailer()
 }
@@ -3,6 +3,7 @@ package mysql
 import (
 	"GopherAI/config"
 	"GopherAI/model"
+	"context"
 	"fmt"
 	"time"
 
@@ -41,6 +42,8 @@ func InitMysql() error {
 		SkipInitializeWithVersion: false,
 	}), &gorm.Config{
 		Logger: log,
+		// 唯一索引冲突转换为 gorm.ErrDuplicatedKey，便于上层区分
+		TranslateError: true,
 	})
 	if err != nil {
 		return err
@@ -60,26 +63,113 @@ func InitMysql() error {
 }
 
 func migration() error {
+	if err := normalizeUserIdentifiers(); err != nil {
+		return fmt.Errorf("normalize usernames and emails: %w", err)
+	}
+	// 邮箱原来是普通索引，换成唯一索引（已有重复邮箱时创建唯一索引会失败，需要先手动处理重复的账号）
+	if DB.Migrator().HasTable(&model.User{}) && DB.Migrator().HasIndex(&model.User{}, "idx_users_email") {
+		if err := DB.Migrator().DropIndex(&model.User{}, "idx_users_email"); err != nil {
+			return fmt.Errorf("drop non-unique email index: %w", err)
+		}
+	}
 	return DB.AutoMigrate(
 		new(model.User),
 		new(model.Session),
 		new(model.Message),
+		new(model.Feedback),
+		new(model.IndexVisibility),
 	)
 }
 
+// normalizeUserIdentifiers 把大小写规范化之前写入的账号和邮箱统一改为小写（已经是小写的行不会被修改），
+// 之后的查询直接比较列的值，可以使用 username / email 上的索引
+func normalizeUserIdentifiers() error {
+	if !DB.Migrator().HasTable(&model.User{}) {
+		return nil
+	}
+	differs := "username <> LOWER(username) OR email <> LOWER(email)"
+	if DB.Dialector.Name() == "mysql" {
+		// MySQL 默认的排序规则不区分大小写，需要按二进制比较才能找出含大写字母的行
+		differs = "BINARY username <> LOWER(username) OR BINARY email <> LOWER(email)"
+	}
+	return DB.Exec("UPDATE users SET username = LOWER(username), email = LOWER(email) WHERE " + differs).Error
+}
+
+// InsertUser 插入用户，成功后 user.ID 为数据库生成的主键
 func InsertUser(user *model.User) (*model.User, error) {
-	err := DB.Create(&user).Error
+	err := DB.Create(user).Error
+	return user, err
+}
+
+// GetUserByID 按主键查询用户，不存在（或已被软删除）时返回 gorm.ErrRecordNotFound
+func GetUserByID(id int64) (*model.User, error) {
+	user := new(model.User)
+	err := DB.First(user, id).Error
 	return user, err
 }
 
+// GetUserByUsername 按账号查询用户（参数需为小写）
+// 账号统一以小写存储（旧数据在启动迁移时改为小写，见 normalizeUserIdentifiers），直接比较可以使用唯一索引
 func GetUserByUsername(username string) (*model.User, error) {
 	user := new(model.User)
 	err := DB.Where("username = ?", username).First(user).Error
 	return user, err
 }
 
+// GetUserByEmail 按邮箱查询用户（参数需为小写，邮箱统一以小写存储）
 func GetUserByEmail(email string) (*model.User, error) {
 	user := new(model.User)
 	err := DB.Where("email = ?", email).First(user).Error
 	return user, err
 }
+
+// UpdateUserPassword 更新用户的密码哈希
+func UpdateUserPassword(id int64, passwordHash string) error {
+	return DB.Model(&model.User{}).Where("id = ?", id).Update("password", passwordHash).Error
+}
+
+// UpdateUserEmail 更新用户的邮箱（参数需为小写）
+func UpdateUserEmail(id int64, email string) error {
+	return DB.Model(&model.User{}).Where("id = ?", id).Update("email", email).Error
+}
+
+// SoftDeleteUser 软删除用户（写入 deleted_at），普通查询将不再返回该用户
+func SoftDeleteUser(id int64) error {
+	return DB.Delete(&model.User{}, id).Error
+}
+
+// HardDeleteUser 从数据库中彻底删除用户（包括已被软删除的用户），删除后不能再恢复
+func HardDeleteUser(id int64) error {
+	return DB.Unscoped().Delete(&model.User{}, id).Error
+}
+
+// GetDeletedUserByUsername 按账号查询已被软删除的用户（参数需为小写）
+func GetDeletedUserByUsername(username string) (*model.User, error) {
+	user := new(model.User)
+	err := DB.Unscoped().Where("username = ? AND deleted_at IS NOT NULL", username).First(user).Error
+	return user, err
+}
+
+// GetDeletedUserByEmail 按邮箱查询已被软删除的用户（参数需为小写）
+func GetDeletedUserByEmail(email string) (*model.User, error) {
+	user := new(model.User)
+	err := DB.Unscoped().Where("email = ? AND deleted_at IS NOT NULL", email).First(user).Error
+	return user, err
+}
+
+// RestoreUser 恢复被软删除的用户
+func RestoreUser(id int64) error {
+	return DB.Unscoped().Model(&model.User{}).Where("id = ?", id).Update("deleted_at", nil).Error
+}
+
+// ListUsers 按注册时间（从早到晚）分页查询未删除的用户，同时返回用户总数
+// 查询时不读取密码哈希，返回的 Password 字段为空
+func ListUsers(ctx context.Context, offset, limit int) ([]*model.User, int64, error) {
+	var total int64
+	if err := DB.WithContext(ctx).Model(&model.User{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []*model.User
+	err := DB.WithContext(ctx).Omit("password").Order("created_at ASC, id ASC").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"GopherAI/model"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB 把 DB 替换为临时目录中的 SQLite 数据库，测试结束后恢复
+func useTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := DB
+	DB = db
+	t.Cleanup(func() { DB = old })
+}
+
+func TestMigrationNormalizesIdentifiers(t *testing.T) {
+	useTestDB(t)
+	// 旧版本的表结构：账号和邮箱保留了注册时的大小写
+	if err := DB.AutoMigrate(new(model.User)); err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct {
+		username, email         string
+		wantUsername, wantEmail string
+	}{
+		{"Alice", "Alice@Example.com", "alice", "alice@example.com"},
+		{"BOB", "bob@example.com", "bob", "bob@example.com"},
+		{"carol", "CAROL@EXAMPLE.COM", "carol", "carol@example.com"},
+		{"dave", "dave@example.com", "dave", "dave@example.com"},
+	}
+	for _, r := range rows {
+		if err := DB.Create(&model.User{Username: r.username, Email: r.email}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migration(); err != nil {
+		t.Fatalf("migration: %v", err)
+	}
+
+	for _, r := range rows {
+		t.Run(r.username, func(t *testing.T) {
+			u, err := GetUserByUsername(r.wantUsername)
+			if err != nil {
+				t.Fatalf("GetUserByUsername(%q): %v", r.wantUsername, err)
+			}
+			if u.Email != r.wantEmail {
+				t.Errorf("email = %q, want %q", u.Email, r.wantEmail)
+			}
+			if _, err := GetUserByEmail(r.wantEmail); err != nil {
+				t.Errorf("GetUserByEmail(%q): %v", r.wantEmail, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ChatOptions 问答使用的对话模型参数，零值的项使用配置中的值
+type ChatOptions struct {
+	Model       string   // 对话模型名，为空时使用配置中的 chatModelName
+	Temperature *float32 // 采样温度，为 nil 时使用配置中的 chatTemperature
+	MaxTokens   int      // 最多生成的 token 数，0 表示使用配置中的 chatMaxTokens
+}
+
+// newChatModel 按配置创建 RAG 使用的对话模型（OpenAI 兼容接口）
+func newChatModel(ctx context.Context, opts ChatOptions) (model.ToolCallingChatModel, error) {
+	conf := config.GetConfig().RagModelConfig
+	if opts.Model == "" {
+		opts.Model = conf.RagChatModelName
+	}
+	if opts.Temperature == nil {
+		opts.Temperature = conf.RagChatTemperature
+	}
+	if opts.MaxTokens == 0 {
+		opts.MaxTokens = conf.RagChatMaxTokens
+	}
+	if opts.MaxTokens < 0 {
+		return nil, fmt.Errorf("invalid MaxTokens %d: must be >= 0", opts.MaxTokens)
+	}
+
+	apiKey := conf.APIKey()
+	if apiKey == "" {
+		return nil, fmt.Errorf("chat model: %w", ErrAPIKeyMissing)
+	}
+	modelConfig := &openai.ChatModelConfig{
+		BaseURL:     conf.RagBaseUrl,
+		Model:       opts.Model,
+		APIKey:      apiKey,
+		Temperature: opts.Temperature,
+	}
+	if opts.MaxTokens > 0 {
+		modelConfig.MaxTokens = &opts.MaxTokens
+	}
+	llm, err := openai.NewChatModel(ctx, modelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat model: %w", err)
+	}
+	return llm, nil
+}
+
+// buildPrompt 按 MaxContextTokensEstimate 预算构建提示词，返回提示词和实际放入的文档
+func (r *RAGQuery) buildPrompt(query string, docs []*schema.Document) (string, []*schema.Document) {
+	prompt, included, err := BuildRAGPromptWithOptions(query, docs, PromptOptions{MaxContextTokensEstimate: r.maxContextTokens})
+	if err != nil {
+		// 默认模板在初始化时已校验，这里不会出错；兜底返回原始问题
+		return query, nil
+	}
+	if included < len(docs) {
+		logger.Info("rag prompt context truncated", "included", included, "retrieved", len(docs), "max_tokens", r.maxContextTokens)
+	}
+	return prompt, docs[:included]
+}
+
+// Answer 检索相关文档、构建提示词并调用对话模型，返回完整回答以及用到的参考文档（便于展示引用来源）
+// 对话模型不可用且配置为降级（见 degrade.go）时，返回空回答、参考文档和 *GenerationUnavailableError
+func (r *RAGQuery) Answer(ctx context.Context, query string) (string, []*schema.Document, error) {
+	docs, err := r.RetrieveDocuments(ctx, query, RetrieveOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+	prompt, docs := r.buildPrompt(query, docs)
+
+	llm, err := newChatModel(ctx, r.chat)
+	if err != nil {
+		docs, err = generationFailed(ctx, err, docs)
+		return "", docs, err
+	}
+	resp, err := llm.Generate(ctx, []*schema.Message{schema.UserMessage(prompt)})
+	if err != nil {
+		docs, err = generationFailed(ctx, fmt.Errorf("failed to generate answer: %w", err), docs)
+		return "", docs, err
+	}
+	return resp.Content, docs, nil
+}
+
+// AnswerStream 检索相关文档、构建提示词并流式调用对话模型，
+// 模型生成的内容片段会按到达顺序写入 out，便于 HTTP 层以 SSE 推送给前端
+// 无论成功与否，返回前都会关闭 out；ctx 取消时停止生成并返回 ctx.Err()
+// 对话模型不可用且配置为降级时返回 *GenerationUnavailableError（此时没有写入任何内容），
+// 调用方可以改用 RetrieveDocuments 展示参考文档
+func (r *RAGQuery) AnswerStream(ctx context.Context, query string, out chan<- string) error {
+	defer close(out)
+
+	docs, err := r.RetrieveDocuments(ctx, query, RetrieveOptions{})
+	if err != nil {
+		return err
+	}
+	prompt, _ := r.buildPrompt(query, docs)
+
+	llm, err := newChatModel(ctx, r.chat)
+	if err != nil {
+		_, err = generationFailed(ctx, err, nil)
+		return err
+	}
+	stream, err := llm.Stream(ctx, []*schema.Message{schema.UserMessage(prompt)})
+	if err != nil {
+		_, err = generationFailed(ctx, fmt.Errorf("failed to start answer stream: %w", err), nil)
+		return err
+	}
+	defer stream.Close()
+
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to receive answer stream: %w", err)
+		}
+		if len(msg.Content) == 0 {
+			continue
+		}
+		select {
+		case out <- msg.Content:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
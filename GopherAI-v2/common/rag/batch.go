@@ -0,0 +1,98 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 默认同时向量化、存储的批次数
+const defaultMaxConcurrency = 4
+
+// storeBatches 将文档按 batchSize 分批，最多 maxConcurrency 个批次并发向量化并写入 Redis
+// 每个文档块的 ID 在切块时已经确定，并发只影响写入的先后，不影响存储结果和块的顺序
+// 任意一批失败时取消其余尚未完成的批次，并返回第一个错误；返回值为成功存储的文档块数
+func (r *RAGIndexer) storeBatches(ctx context.Context, docs []*schema.Document, maxConcurrency int, progress ProgressFunc) (stored int, err error) {
+	total := len(docs)
+	if total == 0 {
+		return 0, nil
+	}
+	ctx, span := startSpan(ctx, "rag.redis.store",
+		attribute.Int("rag.chunks", total),
+		attribute.Int("rag.batch_size", r.batchSize))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     int
+		firstErr error
+	)
+	sem := make(chan struct{}, maxConcurrency)
+
+	for start := 0; start < total; start += r.batchSize {
+		end := start + r.batchSize
+		if end > total {
+			end = total
+		}
+
+		// 等待空闲的 worker；已经出错时不再提交新的批次
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(batch []*schema.Document) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			batchCtx, batchSpan := startSpan(ctx, "rag.redis.store_batch", attribute.Int("rag.chunks", len(batch)))
+			_, err := r.store.Store(batchCtx, batch)
+			endSpan(batchSpan, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to store document: %w", err)
+					cancel()
+				}
+				return
+			}
+			// 进度回调在锁内执行，保证回调串行且 done 单调递增
+			done += len(batch)
+			observeIndexed(r.indexName, len(batch))
+			if progress != nil && firstErr == nil {
+				progress(done, total)
+			}
+		}(docs[start:end])
+	}
+	wg.Wait()
+	if done > 0 {
+		// 部分批次失败时 ctx 已被取消，但已写入的文档块同样需要让缓存失效
+		invalidateResultCache(context.WithoutCancel(ctx), r.indexName)
+	}
+
+	if firstErr != nil {
+		logger.Error("rag store failed", "key_prefix", r.keyPrefix, "stored", done, "total", total, "error", firstErr)
+		return done, firstErr
+	}
+	// 外部 ctx 被取消时，部分批次可能没有提交
+	if err := ctx.Err(); err != nil {
+		return done, err
+	}
+	logger.Info("rag documents stored", "key_prefix", r.keyPrefix, "count", total, "latency_ms", time.Since(start).Milliseconds())
+	return done, nil
+}
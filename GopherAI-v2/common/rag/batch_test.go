@@ -0,0 +1,148 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeEmbedder 按文本长度和首字节生成二维向量，不访问外部服务
+type fakeEmbedder struct {
+	calls atomic.Int32
+}
+
+func (e *fakeEmbedder) EmbedStrings(_ context.Context, texts []string, _ ...embedding.Option) ([][]float64, error) {
+	e.calls.Add(1)
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		first := 0.0
+		if text != "" {
+			first = float64(text[0])
+		}
+		vectors[i] = []float64{float64(len(text)) + 1, first}
+	}
+	return vectors, nil
+}
+
+// trackingStore 包装内存存储，记录同时执行的 Store 调用数，包含 failID 的批次返回错误
+type trackingStore struct {
+	*MemoryVectorStore
+	failID   string
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+var errStoreFailed = errors.New("store failed")
+
+func (s *trackingStore) Store(ctx context.Context, docs []*schema.Document) ([]string, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	// 留出时间让其他批次进入，才能观察到并发
+	time.Sleep(5 * time.Millisecond)
+	for _, doc := range docs {
+		if doc.ID == s.failID {
+			return nil, errStoreFailed
+		}
+	}
+	return s.MemoryVectorStore.Store(ctx, docs)
+}
+
+func batchDocs(n int) []*schema.Document {
+	docs := make([]*schema.Document, n)
+	for i := range docs {
+		docs[i] = &schema.Document{ID: fmt.Sprintf("doc-%d", i), Content: fmt.Sprintf("chunk %d", i)}
+	}
+	return docs
+}
+
+func TestStoreBatches(t *testing.T) {
+	SetLogger(nil)
+
+	tests := []struct {
+		name           string
+		docs           int
+		batchSize      int
+		maxConcurrency int
+		failID         string
+		wantStored     int
+		wantErr        bool
+	}{
+		{"single batch", 3, 10, 4, "", 3, false},
+		{"exact batches", 8, 2, 2, "", 8, false},
+		{"partial last batch", 7, 3, 4, "", 7, false},
+		{"sequential", 5, 1, 1, "", 5, false},
+		{"empty", 0, 2, 4, "", 0, false},
+		{"failing batch", 6, 2, 1, "doc-2", 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &trackingStore{MemoryVectorStore: NewMemoryVectorStore(&fakeEmbedder{}), failID: tt.failID}
+			r := &RAGIndexer{store: store, indexName: "test", batchSize: tt.batchSize}
+
+			var (
+				mu    sync.Mutex
+				calls [][2]int
+			)
+			progress := func(done, total int) {
+				mu.Lock()
+				calls = append(calls, [2]int{done, total})
+				mu.Unlock()
+			}
+			stored, err := r.storeBatches(context.Background(), batchDocs(tt.docs), tt.maxConcurrency, progress)
+			if tt.wantErr {
+				if !errors.Is(err, errStoreFailed) {
+					t.Fatalf("err = %v, want %v", err, errStoreFailed)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if stored != tt.wantStored {
+				t.Errorf("stored = %d, want %d", stored, tt.wantStored)
+			}
+			if got := len(store.data.docs); got != tt.wantStored {
+				t.Errorf("store holds %d docs, want %d", got, tt.wantStored)
+			}
+			if peak := int(store.peak.Load()); peak > tt.maxConcurrency {
+				t.Errorf("peak concurrency %d exceeds %d", peak, tt.maxConcurrency)
+			}
+
+			// 进度回调串行且单调递增，成功时最后一次为 total
+			prev := 0
+			for _, c := range calls {
+				if c[0] <= prev || c[1] != tt.docs {
+					t.Errorf("progress calls %v not increasing towards %d", calls, tt.docs)
+					break
+				}
+				prev = c[0]
+			}
+			if !tt.wantErr && tt.docs > 0 && prev != tt.docs {
+				t.Errorf("last progress %d, want %d", prev, tt.docs)
+			}
+		})
+	}
+}
+
+func TestStoreBatchesRunsConcurrently(t *testing.T) {
+	SetLogger(nil)
+	store := &trackingStore{MemoryVectorStore: NewMemoryVectorStore(&fakeEmbedder{})}
+	r := &RAGIndexer{store: store, indexName: "test", batchSize: 1}
+	if _, err := r.storeBatches(context.Background(), batchDocs(8), 4, nil); err != nil {
+		t.Fatal(err)
+	}
+	if peak := store.peak.Load(); peak < 2 {
+		t.Errorf("peak concurrency %d, want batches to overlap", peak)
+	}
+}
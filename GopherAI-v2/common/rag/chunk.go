@@ -0,0 +1,330 @@
+package rag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// 默认每块大小（按字符数计算，中文一个字即一个字符）
+	defaultChunkSize = 512
+	// 默认相邻块之间的重叠字符数，避免语义在切分处被截断
+	defaultChunkOverlap = 64
+)
+
+// 切块策略
+const (
+	ChunkStrategyFixed    = "fixed"    // 固定窗口切分
+	ChunkStrategyMarkdown = "markdown" // 按 Markdown 标题切分，保留标题上下文
+	ChunkStrategySentence = "sentence" // 按句子边界切分，不在句子中间截断
+)
+
+// 匹配一到三级 Markdown 标题
+var markdownHeadingRe = regexp.MustCompile(`^(#{1,3})\s+(.+?)\s*#*\s*$`)
+
+// ChunkOptions 文本切块配置
+// 零值表示使用默认配置（512 字符 / 64 重叠）
+type ChunkOptions struct {
+	ChunkSize int    // 每块最大字符数
+	Overlap   int    // 相邻块重叠字符数（sentence 策略下为重叠的整句总字符数上限）
+	Strategy  string // 切块策略：fixed（默认）/ markdown / sentence
+	// Normalize 切块后、向量化前对文档块内容的规范化，零值表示删除控制字符、合并多余空白并做 NFC 规范化
+	Normalize NormalizeOptions
+	// MinChunkLength 文档块的最小字符数，0 表示不限制；必须小于 ChunkSize
+	// 更短的块（单个词、页码等）向量化后噪声很大：并入同一章节的前一块（没有前一块时并入后一块），
+	// 同一章节中没有可以合并的块时丢弃；整个文档只有这一块时保留
+	MinChunkLength int
+}
+
+// textChunk 切块结果
+type textChunk struct {
+	Content string
+	Heading string // 所属的标题路径，例如 "安装 > 配置"，没有标题时为空
+}
+
+// withDefaults 补全未设置的字段并校验参数
+func (o ChunkOptions) withDefaults() (ChunkOptions, error) {
+	if o.ChunkSize == 0 && o.Overlap == 0 {
+		o.Overlap = defaultChunkOverlap
+	}
+	if o.ChunkSize == 0 {
+		o.ChunkSize = defaultChunkSize
+	}
+	if o.ChunkSize < 0 || o.Overlap < 0 {
+		return o, fmt.Errorf("invalid chunk options: size=%d overlap=%d", o.ChunkSize, o.Overlap)
+	}
+	if o.Overlap >= o.ChunkSize {
+		return o, fmt.Errorf("chunk overlap %d must be smaller than chunk size %d", o.Overlap, o.ChunkSize)
+	}
+	if o.MinChunkLength < 0 || o.MinChunkLength >= o.ChunkSize {
+		return o, fmt.Errorf("min chunk length %d must be between 0 and chunk size %d", o.MinChunkLength, o.ChunkSize)
+	}
+	switch o.Strategy {
+	case "":
+		o.Strategy = ChunkStrategyFixed
+	case ChunkStrategyFixed, ChunkStrategyMarkdown, ChunkStrategySentence:
+	default:
+		return o, fmt.Errorf("unknown chunk strategy: %s", o.Strategy)
+	}
+	return o, nil
+}
+
+// chunkText 按配置的策略将文本切块，短于 MinChunkLength 的块会被合并或丢弃
+func chunkText(text string, opts ChunkOptions) []textChunk {
+	if opts.Strategy == ChunkStrategyMarkdown {
+		return mergeShortChunks(splitMarkdown(text, opts), opts.MinChunkLength)
+	}
+	split := splitText
+	if opts.Strategy == ChunkStrategySentence {
+		split = splitBySentence
+	}
+	var chunks []textChunk
+	for _, c := range split(text, opts) {
+		chunks = append(chunks, textChunk{Content: c})
+	}
+	return mergeShortChunks(chunks, opts.MinChunkLength)
+}
+
+// 合并文档块时，前一块末尾与短块开头相同的部分达到该字符数才视为切块重叠，更短的相同部分可能只是巧合
+const minMergeOverlapRunes = 3
+
+// mergeShortChunks 把短于 minLength 个字符的块并入同一章节（Heading 相同）的前一块，没有前一块时并入后一块；
+// 同一章节中没有可以合并的块时丢弃，但不会丢弃所有块。合并后的块可能略长于 ChunkSize
+func mergeShortChunks(chunks []textChunk, minLength int) []textChunk {
+	if minLength <= 0 || len(chunks) <= 1 {
+		return chunks
+	}
+	merged := make([]textChunk, 0, len(chunks))
+	var carry string // 等待并入后一块的短块
+	for i, chunk := range chunks {
+		if carry != "" {
+			chunk.Content = joinChunks(carry, chunk.Content)
+			carry = ""
+		}
+		if utf8.RuneCountInString(chunk.Content) >= minLength {
+			merged = append(merged, chunk)
+			continue
+		}
+		switch {
+		case len(merged) > 0 && merged[len(merged)-1].Heading == chunk.Heading:
+			prev := &merged[len(merged)-1]
+			prev.Content = joinChunks(prev.Content, chunk.Content)
+		case i+1 < len(chunks) && chunks[i+1].Heading == chunk.Heading:
+			carry = chunk.Content
+		case len(merged) == 0 && i == len(chunks)-1:
+			// 所有块都太短，保留最后一块（其中已经并入了同一章节前面的短块），避免整个文档都不被索引
+			merged = append(merged, chunk)
+		}
+	}
+	return merged
+}
+
+// joinChunks 把 next 接在 prev 后面，去掉两者之间因为切块重叠而重复的部分
+func joinChunks(prev, next string) string {
+	for k := min(len(prev), len(next)); k > 0; k-- {
+		if k < len(next) && !utf8.RuneStart(next[k]) {
+			continue
+		}
+		if strings.HasSuffix(prev, next[:k]) {
+			if utf8.RuneCountInString(next[:k]) >= minMergeOverlapRunes {
+				return prev + next[k:]
+			}
+			break
+		}
+	}
+	return prev + "\n" + next
+}
+
+// splitText 按固定窗口切分文本，相邻块之间保留 Overlap 个字符的重叠
+func splitText(text string, opts ChunkOptions) []string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil
+	}
+
+	step := opts.ChunkSize - opts.Overlap
+	var chunks []string
+	for start := 0; start < len(runes); start += step {
+		end := start + opts.ChunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunk := strings.TrimSpace(string(runes[start:end]))
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+	}
+	return chunks
+}
+
+// splitMarkdown 按标题（# / ## / ###）将 Markdown 切分为若干章节，
+// 章节过长时再按固定窗口切分，并在每一块前加上所属的标题路径，
+// 这样向量化时每一块都带有章节上下文
+func splitMarkdown(text string, opts ChunkOptions) []textChunk {
+	var (
+		chunks  []textChunk
+		path    []string // 当前所在的标题路径
+		body    strings.Builder
+		inFence bool
+	)
+
+	flush := func() {
+		var parts []string
+		for _, p := range path {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		heading := strings.Join(parts, " > ")
+		for _, c := range splitText(body.String(), opts) {
+			content := c
+			if heading != "" {
+				content = heading + "\n" + c
+			}
+			chunks = append(chunks, textChunk{Content: content, Heading: heading})
+		}
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		// 代码块中的 # 不是标题
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if m := markdownHeadingRe.FindStringSubmatch(line); m != nil {
+				flush()
+				level := len(m[1])
+				for len(path) < level-1 {
+					path = append(path, "")
+				}
+				path = append(path[:level-1], m[2])
+				continue
+			}
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	flush()
+	return chunks
+}
+
+// splitBySentence 按句子边界切分文本：把完整的句子依次装入文档块，加上下一句会超过 ChunkSize 时开始新的一块
+// 新的一块以上一块末尾的若干整句开头作为重叠，这些句子的总字符数不超过 Overlap；
+// 超过 ChunkSize 的长句单独成块，按固定窗口切分
+func splitBySentence(text string, opts ChunkOptions) []string {
+	var (
+		chunks  []string
+		current []string // 当前块中的句子（开头可能是上一块的重叠句子）
+		size    int      // 当前块的字符数
+		fresh   int      // 当前块中不属于重叠部分的句子数
+	)
+	flush := func() {
+		if fresh > 0 {
+			if chunk := strings.TrimSpace(strings.Join(current, "")); chunk != "" {
+				chunks = append(chunks, chunk)
+			}
+		}
+		current, size = overlapSentences(current, opts.Overlap)
+		fresh = 0
+	}
+
+	for _, sentence := range splitSentenceSegments(text) {
+		n := utf8.RuneCountInString(sentence)
+		if n > opts.ChunkSize {
+			flush()
+			chunks = append(chunks, splitText(sentence, opts)...)
+			current, size = nil, 0
+			continue
+		}
+		if size+n > opts.ChunkSize {
+			flush()
+			// 重叠部分加上这一句仍然放不下时放弃重叠
+			if size+n > opts.ChunkSize {
+				current, size = nil, 0
+			}
+		}
+		current = append(current, sentence)
+		size += n
+		fresh++
+	}
+	flush()
+	return chunks
+}
+
+// overlapSentences 取 sentences 末尾总字符数不超过 limit 的若干整句
+func overlapSentences(sentences []string, limit int) ([]string, int) {
+	size := 0
+	start := len(sentences)
+	for start > 0 {
+		n := utf8.RuneCountInString(sentences[start-1])
+		if size+n > limit {
+			break
+		}
+		size += n
+		start--
+	}
+	return append([]string(nil), sentences[start:]...), size
+}
+
+// 中文句末标点任何时候都是句子边界；英文的 .!? 后面必须是空白或文本结尾，避免在 3.14、e.g 中间切开
+const (
+	cjkSentenceEnds   = "。！？"
+	latinSentenceEnds = ".!?"
+	// 句末标点之后属于同一句的右引号和右括号
+	sentenceClosers = "\"'”’）)」』】》"
+)
+
+// splitSentenceSegments 把文本切分为句子，每个句子包含其后的空白，拼接起来等于原文
+// 空行（段落之间）也是句子边界
+func splitSentenceSegments(text string) []string {
+	runes := []rune(text)
+	var segments []string
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		end := -1
+		switch {
+		case strings.ContainsRune(cjkSentenceEnds, r):
+			end = skipRunes(runes, i+1, cjkSentenceEnds+latinSentenceEnds+sentenceClosers)
+		case strings.ContainsRune(latinSentenceEnds, r):
+			j := skipRunes(runes, i+1, latinSentenceEnds+sentenceClosers)
+			if j == len(runes) || unicode.IsSpace(runes[j]) {
+				end = j
+			}
+		case r == '\n':
+			if j := skipRunes(runes, i+1, " \t\r"); j < len(runes) && runes[j] == '\n' {
+				end = j
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		// 句子之后的空白归入这一句
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		segments = append(segments, string(runes[start:end]))
+		start = end
+		i = end - 1
+	}
+	if start < len(runes) {
+		segments = append(segments, string(runes[start:]))
+	}
+	return segments
+}
+
+// skipRunes 从 i 开始跳过属于 set 的字符，返回第一个不属于 set 的位置
+func skipRunes(runes []rune, i int, set string) int {
+	for i < len(runes) && strings.ContainsRune(set, runes[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,110 @@
+package rag
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeShortChunks(t *testing.T) {
+	c := func(heading, content string) textChunk { return textChunk{Content: content, Heading: heading} }
+
+	tests := []struct {
+		name      string
+		chunks    []textChunk
+		minLength int
+		want      []textChunk
+	}{
+		{"disabled", []textChunk{c("", "a"), c("", "b")}, 0, []textChunk{c("", "a"), c("", "b")}},
+		{"all long enough", []textChunk{c("", "hello"), c("", "world")}, 5, []textChunk{c("", "hello"), c("", "world")}},
+		{"tiny final chunk", []textChunk{c("", "first chunk"), c("", "second chunk"), c("", "12")}, 5,
+			[]textChunk{c("", "first chunk"), c("", "second chunk\n12")}},
+		{"tiny first chunk", []textChunk{c("", "p1"), c("", "first chunk")}, 5, []textChunk{c("", "p1\nfirst chunk")}},
+		{"consecutive short chunks", []textChunk{c("", "ab"), c("", "cd"), c("", "long enough")}, 6,
+			[]textChunk{c("", "ab\ncd\nlong enough")}},
+		{"stays in its section", []textChunk{c("A", "section a text"), c("B", "b1"), c("B", "section b text")}, 5,
+			[]textChunk{c("A", "section a text"), c("B", "b1\nsection b text")}},
+		{"dropped when section has no other chunk", []textChunk{c("A", "section a text"), c("B", "b1"), c("C", "section c text")}, 5,
+			[]textChunk{c("A", "section a text"), c("C", "section c text")}},
+		{"keeps the last chunk when all are short", []textChunk{c("", "a"), c("", "b")}, 5, []textChunk{c("", "a\nb")}},
+		{"single short chunk kept", []textChunk{c("", "a")}, 5, []textChunk{c("", "a")}},
+		{"counts runes not bytes", []textChunk{c("", "中文内容很长"), c("", "短文")}, 5, []textChunk{c("", "中文内容很长\n短文")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeShortChunks(tt.chunks, tt.minLength)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeShortChunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinChunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+		want       string
+	}{
+		{"no overlap", "hello", "world", "hello\nworld"},
+		{"overlap removed", "the quick brown", "brown fox", "the quick brown fox"},
+		{"short overlap kept", "abc xy", "xy z", "abc xy\nxy z"},
+		{"unicode overlap", "知识库的切块", "的切块重叠", "知识库的切块重叠"},
+		{"overlap shorter than three runes kept", "知识库的切块", "切块重叠", "知识库的切块\n切块重叠"},
+		{"next inside prev", "abcdef", "def", "abcdef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinChunks(tt.prev, tt.next); got != tt.want {
+				t.Errorf("joinChunks(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkTextMinChunkLength(t *testing.T) {
+	text := strings.Repeat("a", 10) + strings.Repeat("b", 10) + "cc"
+
+	tests := []struct {
+		name      string
+		minLength int
+		want      []string
+	}{
+		{"tiny final chunk kept without limit", 0, []string{strings.Repeat("a", 10), strings.Repeat("b", 10), "cc"}},
+		{"tiny final chunk merged", 5, []string{strings.Repeat("a", 10), strings.Repeat("b", 10) + "\ncc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ChunkOptions{ChunkSize: 10, Overlap: 0, Strategy: ChunkStrategyFixed, MinChunkLength: tt.minLength}.withDefaults()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, chunk := range chunkText(text, opts) {
+				got = append(got, chunk.Content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunkText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkOptionsMinChunkLengthValidation(t *testing.T) {
+	tests := []struct {
+		minLength int
+		valid     bool
+	}{
+		{0, true},
+		{50, true},
+		{99, true},
+		{100, false},
+		{-1, false},
+	}
+	for _, tt := range tests {
+		_, err := ChunkOptions{ChunkSize: 100, Overlap: 10, MinChunkLength: tt.minLength}.withDefaults()
+		if (err == nil) != tt.valid {
+			t.Errorf("MinChunkLength %d: err = %v, want valid %v", tt.minLength, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package rag
+
+import (
+	"errors"
+	"io"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// closeEmbedder 关闭向量生成器，没有需要释放的资源（没有实现 io.Closer）时什么都不做
+// 重试、缓存、指标等包装层都实现了 Close，会一直关闭到最内层的向量生成器
+func closeEmbedder(e embedding.Embedder) error {
+	if c, ok := e.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// closeResources 关闭向量生成器和存储中需要释放的资源
+func closeResources(e embedding.Embedder, store VectorStore) error {
+	err := closeEmbedder(e)
+	if c, ok := store.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// Close 释放索引器持有的向量生成器等资源，可以重复调用，之后不能再使用该索引器
+// 全局的 Redis 连接（redis.Rdb）由所有索引器和查询器共享，这里不会关闭，服务退出时调用 redis.CloseRedis
+func (r *RAGIndexer) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = closeResources(r.embedding, r.store)
+	})
+	return r.closeErr
+}
+
+// Close 释放查询器持有的向量生成器、检索器等资源，可以重复调用，之后不能再使用该查询器
+// 全局的 Redis 连接（redis.Rdb）由所有索引器和查询器共享，这里不会关闭，服务退出时调用 redis.CloseRedis
+func (r *RAGQuery) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = closeResources(r.embedding, r.store)
+		for _, pq := range r.public {
+			r.closeErr = errors.Join(r.closeErr, pq.Close())
+		}
+	})
+	return r.closeErr
+}
+
+// Close 关闭内层的向量生成器
+func (e *RetryEmbedder) Close() error {
+	return closeEmbedder(e.embedder)
+}
+
+// Close 关闭内层的向量生成器，缓存使用全局 Redis 连接，不需要关闭
+func (c *CachedEmbedder) Close() error {
+	return closeEmbedder(c.embedder)
+}
+
+func (e *instrumentedEmbedder) Close() error {
+	return closeEmbedder(e.embedder)
+}
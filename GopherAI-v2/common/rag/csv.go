@@ -0,0 +1,175 @@
+package rag
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// CSVOptions 按行索引 CSV / TSV 文件的配置，零值表示：
+// 第一行为表头，逗号分隔（.tsv 文件为制表符），除元数据列外的所有列都作为向量化的内容
+type CSVOptions struct {
+	// Delimiter 分隔符，0 表示按扩展名选择（.tsv 为制表符，其它为逗号）
+	Delimiter rune
+	// NoHeader 文件没有表头行，此时列名依次为 column1、column2……
+	NoHeader bool
+	// ContentColumns 参与向量化的列，为空表示除 MetadataColumns 以外的所有列
+	ContentColumns []string
+	// MetadataColumns 作为元数据保存的列（字段名即列名），不参与向量化
+	MetadataColumns []string
+	// ContentTemplate 生成文档内容的模板（text/template 语法，以列名取值，如 "问题：{{.问题}} 答案：{{.答案}}"），
+	// 为空时每个内容列输出一行 "列名: 值"
+	ContentTemplate string
+}
+
+// isTabularFile 判断文件是否按行索引（.csv / .tsv）
+func isTabularFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// buildCSVDocuments 将 CSV / TSV 的每一行作为一个独立文档（不再切块），空行会被跳过
+// 元数据中 row 为该行在文件中的行号（从 1 开始，包含表头行）
+func buildCSVDocuments(filePath string, opts CSVOptions) ([]*schema.Document, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	// 转换为 UTF-8：中文 Excel 导出的 CSV 通常是 GBK 编码，UTF-8 导出时带有 BOM
+	text, err := decodeText(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(filePath), err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = opts.Delimiter
+	if reader.Comma == 0 {
+		reader.Comma = ','
+		if strings.ToLower(filepath.Ext(filePath)) == ".tsv" {
+			reader.Comma = '\t'
+		}
+	}
+	// TSV 一般不使用引号转义，字段中单独出现的引号按普通字符处理
+	reader.LazyQuotes = reader.Comma == '\t'
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse csv: %w", err)
+	}
+	if len(records) == 0 {
+		return []*schema.Document{}, nil
+	}
+
+	var header []string
+	firstRow := 0
+	if opts.NoHeader {
+		header = defaultColumnNames(maxColumns(records))
+	} else {
+		header = make([]string, len(records[0]))
+		for i, name := range records[0] {
+			header[i] = strings.TrimSpace(name)
+		}
+		firstRow = 1
+	}
+
+	for _, col := range slices.Concat(opts.ContentColumns, opts.MetadataColumns) {
+		if !slices.Contains(header, col) {
+			return nil, fmt.Errorf("column %q not found in csv header", col)
+		}
+	}
+	contentColumns := opts.ContentColumns
+	if len(contentColumns) == 0 {
+		for _, col := range header {
+			if col != "" && !slices.Contains(opts.MetadataColumns, col) {
+				contentColumns = append(contentColumns, col)
+			}
+		}
+	}
+
+	var tmpl *template.Template
+	if opts.ContentTemplate != "" {
+		tmpl, err = template.New("csv_row").Option("missingkey=zero").Parse(opts.ContentTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv content template: %w", err)
+		}
+	}
+
+	var docs []*schema.Document
+	for rowIdx := firstRow; rowIdx < len(records); rowIdx++ {
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(records[rowIdx]) {
+				row[col] = strings.TrimSpace(records[rowIdx][i])
+			}
+		}
+
+		content, err := rowContent(row, contentColumns, tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render csv row %d: %w", rowIdx+1, err)
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		i := len(docs)
+		metadata := map[string]any{
+			"source":       filePath,
+			"chunk_index":  i,
+			"row":          rowIdx + 1,
+			"content_hash": contentHash(content),
+		}
+		for _, col := range opts.MetadataColumns {
+			metadata[col] = row[col]
+		}
+		docs = append(docs, &schema.Document{
+			Content:  content,
+			MetaData: metadata,
+		})
+	}
+	return docs, nil
+}
+
+// rowContent 生成一行的文档内容
+func rowContent(row map[string]string, columns []string, tmpl *template.Template) (string, error) {
+	if tmpl != nil {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, row); err != nil {
+			return "", err
+		}
+		return sb.String(), nil
+	}
+	lines := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if v := row[col]; v != "" {
+			lines = append(lines, col+": "+v)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func maxColumns(records [][]string) int {
+	n := 0
+	for _, r := range records {
+		n = max(n, len(r))
+	}
+	return n
+}
+
+func defaultColumnNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "column" + strconv.Itoa(i+1)
+	}
+	return names
+}
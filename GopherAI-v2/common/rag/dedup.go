@@ -0,0 +1,57 @@
+package rag
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// chunkLocation 重复文档块在原文中的一个位置
+type chunkLocation struct {
+	ChunkIndex any `json:"chunk_index"`
+	Page       any `json:"page,omitempty"`
+}
+
+// dedupDocuments 去掉内容相同的文档块（忽略空白差异），只保留第一次出现的块
+// 出现多次的块在元数据中记录 occurrences（出现次数）和 locations（所有出现位置的 JSON 数组，
+// 例如 [{"chunk_index":3,"page":2},{"chunk_index":17,"page":5}]）
+func dedupDocuments(docs []*schema.Document) []*schema.Document {
+	type group struct {
+		doc       *schema.Document
+		locations []chunkLocation
+	}
+	groups := make(map[string]*group, len(docs))
+	kept := make([]*schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		key := contentHash(normalizeWhitespace(doc.Content))
+		loc := chunkLocation{ChunkIndex: doc.MetaData["chunk_index"], Page: doc.MetaData["page"]}
+		if g, ok := groups[key]; ok {
+			g.locations = append(g.locations, loc)
+			continue
+		}
+		groups[key] = &group{doc: doc, locations: []chunkLocation{loc}}
+		kept = append(kept, doc)
+	}
+
+	for _, g := range groups {
+		if len(g.locations) < 2 {
+			continue
+		}
+		locations, err := json.Marshal(g.locations)
+		if err != nil {
+			continue
+		}
+		g.doc.MetaData["occurrences"] = len(g.locations)
+		g.doc.MetaData["locations"] = string(locations)
+	}
+	if removed := len(docs) - len(kept); removed > 0 {
+		logger.Info("rag duplicate chunks removed", "removed", removed, "kept", len(kept))
+	}
+	return kept
+}
+
+// normalizeWhitespace 把连续的空白字符合并为一个空格，并去掉首尾空白
+func normalizeWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,59 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestDedupDocuments(t *testing.T) {
+	SetLogger(nil)
+
+	tests := []struct {
+		name      string
+		contents  []string
+		wantKept  []int          // 保留的块在输入中的下标
+		wantOccur map[int]string // 保留的块下标 -> locations，未列出的块不应有 occurrences
+	}{
+		{"no duplicates", []string{"a", "b", "c"}, []int{0, 1, 2}, nil},
+		{"exact duplicate", []string{"a", "b", "a"}, []int{0, 1}, map[int]string{0: `[{"chunk_index":0,"page":1},{"chunk_index":2,"page":3}]`}},
+		{"whitespace differences", []string{"hello  world", "x", " hello\nworld "}, []int{0, 1}, map[int]string{0: `[{"chunk_index":0,"page":1},{"chunk_index":2,"page":3}]`}},
+		{"three copies", []string{"a", "a", "b", "a"}, []int{0, 2}, map[int]string{0: `[{"chunk_index":0,"page":1},{"chunk_index":1,"page":2},{"chunk_index":3,"page":4}]`}},
+		{"case sensitive", []string{"Hello", "hello"}, []int{0, 1}, nil},
+		{"empty", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := make([]*schema.Document, len(tt.contents))
+			for i, c := range tt.contents {
+				docs[i] = &schema.Document{Content: c, MetaData: map[string]any{"chunk_index": i, "page": i + 1}}
+			}
+			input := append([]*schema.Document(nil), docs...)
+
+			kept := dedupDocuments(docs)
+			if len(kept) != len(tt.wantKept) {
+				t.Fatalf("kept %d chunks, want %d", len(kept), len(tt.wantKept))
+			}
+			for i, idx := range tt.wantKept {
+				doc := kept[i]
+				if doc != input[idx] {
+					t.Errorf("kept[%d] = %q, want chunk %d", i, doc.Content, idx)
+				}
+				want, dup := tt.wantOccur[idx]
+				if !dup {
+					if _, ok := doc.MetaData["occurrences"]; ok {
+						t.Errorf("chunk %d has occurrences %v", idx, doc.MetaData["occurrences"])
+					}
+					continue
+				}
+				if got := doc.MetaData["occurrences"]; got != strings.Count(want, "{") {
+					t.Errorf("chunk %d occurrences = %v, want %d", idx, got, strings.Count(want, "{"))
+				}
+				if got := doc.MetaData["locations"]; got != want {
+					t.Errorf("chunk %d locations = %v, want %s", idx, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 降级：重排序模型和对话模型都是检索之外的可选环节，它们的服务不可用时检索本身仍然可以工作。
+// 配置项 modelFailureMode 为 degrade（默认）时：
+//   - 重排序失败：记录警告，返回向量检索的顺序（降级的结果不写入检索结果缓存）
+//   - 问答时对话模型失败：返回检索到的参考文档和 *GenerationUnavailableError，调用方仍然可以展示引用来源
+//
+// 配置为 fail 时与之前一样直接返回错误。ctx 被取消或超时不属于模型不可用，总是直接返回。
+
+// ErrGenerationUnavailable 对话模型不可用，没有生成回答，可通过 errors.Is 判断；
+// 同时返回的参考文档仍然有效
+var ErrGenerationUnavailable = errors.New("answer generation unavailable")
+
+// GenerationUnavailableError 对话模型不可用的原因
+type GenerationUnavailableError struct {
+	Err error
+}
+
+func (e *GenerationUnavailableError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrGenerationUnavailable, e.Err)
+}
+
+func (e *GenerationUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, ErrGenerationUnavailable) 成立
+func (e *GenerationUnavailableError) Is(target error) bool {
+	return target == ErrGenerationUnavailable
+}
+
+// degradeOnModelFailure 模型调用失败时是否降级；ctx 已取消或超时时不降级
+func degradeOnModelFailure(ctx context.Context) bool {
+	return ctx.Err() == nil && config.GetConfig().RagModelConfig.RagModelFailureMode != "fail"
+}
+
+// generationFailed 处理问答时对话模型的错误：降级时返回参考文档和 *GenerationUnavailableError，否则原样返回错误
+func generationFailed(ctx context.Context, err error, docs []*schema.Document) ([]*schema.Document, error) {
+	if !degradeOnModelFailure(ctx) {
+		return nil, err
+	}
+	logger.Warn("rag chat model unavailable, returning retrieved documents only", "documents", len(docs), "error", err)
+	return docs, &GenerationUnavailableError{Err: err}
+}
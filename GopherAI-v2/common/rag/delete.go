@@ -0,0 +1,154 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"context"
+	"errors"
+	"fmt"
+
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// ErrDocumentNotFound 知识库中没有匹配的文档块，可通过 errors.Is 判断
+var ErrDocumentNotFound = errors.New("no matching document found")
+
+// docKeyPrefix 知识库第 gen 代文档块 key 的公共前缀（与 NewRAGIndexer 中 DocumentToHashes 生成的 key 一致）
+func docKeyPrefix(username, filename string, gen int) string {
+	return redisPkg.GenerationKeyPrefix(username, filename, gen) + docKeySuffix(filename, "")
+}
+
+// currentDocKeyPrefix 知识库当前这一代文档块 key 的公共前缀，重建索引后文档块位于新的前缀下
+func currentDocKeyPrefix(ctx context.Context, username, filename string) (string, error) {
+	gen, err := redisPkg.IndexGeneration(ctx, username, filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read index generation: %w", err)
+	}
+	return docKeyPrefix(username, filename, gen), nil
+}
+
+// configuredMemoryStore 配置使用内存存储时返回知识库对应的内存存储（知识库不存在时为空存储），
+// ok 为 false 表示使用 Redis 存储
+func configuredMemoryStore(username, filename string) (*MemoryVectorStore, bool, error) {
+	backend, err := vectorStoreBackend()
+	if err != nil || backend != VectorStoreMemory {
+		return nil, false, err
+	}
+	if store, ok := lookupMemoryStore(redisPkg.GenerateIndexName(username, filename), nil); ok {
+		return store, true, nil
+	}
+	return NewMemoryVectorStore(nil), true, nil
+}
+
+// DeleteDocument 从知识库中删除一个文档块，不影响索引和其它文档块
+// 返回删除的 key 数量，文档块不存在时返回 ErrDocumentNotFound
+func DeleteDocument(ctx context.Context, username, filename, docID string) (int, error) {
+	if docID == "" {
+		return 0, fmt.Errorf("document id is required")
+	}
+	if store, ok, err := configuredMemoryStore(username, filename); err != nil {
+		return 0, err
+	} else if ok {
+		n, _ := store.Delete(ctx, docID)
+		if n == 0 {
+			return 0, fmt.Errorf("document %s: %w", docID, ErrDocumentNotFound)
+		}
+		invalidateResultCache(ctx, redisPkg.GenerateIndexName(username, filename))
+		return n, nil
+	}
+	client, err := redisPkg.IndexClient(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	prefix, err := currentDocKeyPrefix(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	key := prefix + docID
+	// 先读出文档块的来源文件，删除后归还该文件的配额用量
+	source, _ := client.HGet(ctx, key, "metadata").Result()
+	n, err := client.Del(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete document: %w", err)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("document %s: %w", docID, ErrDocumentNotFound)
+	}
+	indexName := redisPkg.GenerateIndexName(username, filename)
+	invalidateResultCache(ctx, indexName)
+	if source != "" {
+		releaseChunks(ctx, username, indexName, source, n)
+	}
+	return int(n), nil
+}
+
+// DeleteDocumentsBySource 删除知识库中来源（索引时的 source，即文件路径）为 source 的所有文档块
+// 返回删除的 key 数量，没有匹配的文档块时返回 ErrDocumentNotFound
+func DeleteDocumentsBySource(ctx context.Context, username, filename, source string) (int, error) {
+	if source == "" {
+		return 0, fmt.Errorf("source is required")
+	}
+	if store, ok, err := configuredMemoryStore(username, filename); err != nil {
+		return 0, err
+	} else if ok {
+		n := store.deleteBySource(source)
+		if n == 0 {
+			return 0, fmt.Errorf("source %s: %w", source, ErrDocumentNotFound)
+		}
+		invalidateResultCache(ctx, redisPkg.GenerateIndexName(username, filename))
+		return n, nil
+	}
+	client, err := redisPkg.IndexClient(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+
+	prefix, err := currentDocKeyPrefix(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	var cursor uint64
+	pattern := escapeGlob(prefix) + "*"
+	for {
+		batch, next, err := client.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan documents: %w", err)
+		}
+		keys = append(keys, batch...)
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	// source 存在 metadata 字段中
+	var matched []string
+	if len(keys) > 0 {
+		pipe := client.Pipeline()
+		cmds := make([]*redisCli.StringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.HGet(ctx, key, "metadata")
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redisCli.Nil {
+			return 0, fmt.Errorf("failed to read document sources: %w", err)
+		}
+		for i, key := range keys {
+			if cmds[i].Val() == source {
+				matched = append(matched, key)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return 0, fmt.Errorf("source %s: %w", source, ErrDocumentNotFound)
+	}
+
+	n, err := client.Del(ctx, matched...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete documents: %w", err)
+	}
+	indexName := redisPkg.GenerateIndexName(username, filename)
+	invalidateResultCache(ctx, indexName)
+	releaseChunks(ctx, username, indexName, source, n)
+	return int(n), nil
+}
@@ -0,0 +1,145 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// 向量降维：配置 reducedDimension 后，新建的知识库只保存向量的前 reducedDimension 维（截断后重新归一化），
+// 索引也按降维后的维度创建，问题向量按同样的方式截断。只有按 Matryoshka（MRL）方式训练的模型，
+// 截断后的前几维仍然是有效的向量；其它模型截断后检索质量会明显下降。
+//
+// 已知支持截断的模型（按模型名前缀匹配，不区分大小写）：
+//   - OpenAI text-embedding-3-small（1536 维）、text-embedding-3-large（3072 维）：任意更小的维度，
+//     与接口的 dimensions 参数效果相同
+//   - 火山方舟 doubao-embedding：512 / 1024 / 2048
+//   - Jina jina-embeddings-v3（1024 维）：32 / 64 / 128 / 256 / 512 / 768
+//   - Ollama nomic-embed-text（v1.5，768 维）：64 / 128 / 256 / 512
+//   - Ollama mxbai-embed-large（1024 维）：任意更小的维度
+//
+// 列表中的模型选择了不支持的维度时拒绝建立知识库；不在列表中的模型只记录警告，由使用者自己确认。
+// 每个知识库的维度以索引为准：修改 reducedDimension 只影响之后新建或重建的知识库，已有的知识库继续按原来的维度写入和检索。
+
+// matryoshkaModels 支持截断的模型（名称前缀 -> 支持的维度，nil 表示任意小于原始维度的维度）
+var matryoshkaModels = []struct {
+	prefix     string
+	dimensions []int
+}{
+	{"text-embedding-3-", nil},
+	{"doubao-embedding", []int{512, 1024, 2048}},
+	{"jina-embeddings-v3", []int{32, 64, 128, 256, 512, 768}},
+	{"nomic-embed-text", []int{64, 128, 256, 512}},
+	{"mxbai-embed-large", nil},
+}
+
+// ErrDimensionUnsupported 向量模型不支持截断到配置的维度，可通过 errors.Is 判断
+var ErrDimensionUnsupported = errors.New("embedding model does not support the reduced dimension")
+
+// checkReducedDimension 校验模型是否支持截断到 dimension 维，未知的模型只记录警告
+func checkReducedDimension(model string, dimension int) error {
+	name := strings.ToLower(model)
+	for _, m := range matryoshkaModels {
+		if !strings.HasPrefix(name, m.prefix) {
+			continue
+		}
+		if m.dimensions != nil && !slices.Contains(m.dimensions, dimension) {
+			return fmt.Errorf("%w: %s supports %v, got %d", ErrDimensionUnsupported, model, m.dimensions, dimension)
+		}
+		return nil
+	}
+	logger.Warn("rag embedding model not known to support truncation, retrieval quality may drop",
+		"model", model, "reduced_dimension", dimension)
+	return nil
+}
+
+// newIndexDimension 新建知识库时向量的维度：配置了 reducedDimension 时为降维后的维度，否则为模型的原始维度
+func newIndexDimension() int {
+	conf := config.GetConfig().RagModelConfig
+	if conf.RagReducedDimension > 0 {
+		return conf.RagReducedDimension
+	}
+	return conf.RagDimension
+}
+
+// indexDimension 返回知识库索引的向量维度，知识库不存在或使用内存存储时返回新建知识库的维度
+func indexDimension(ctx context.Context, username, filename string) (int, error) {
+	backend, err := vectorStoreBackend()
+	if err != nil {
+		return 0, err
+	}
+	if backend == VectorStoreMemory {
+		return newIndexDimension(), nil
+	}
+	info, err := redisPkg.GetRedisIndexInfo(ctx, username, filename)
+	if errors.Is(err, redisPkg.ErrIndexNotFound) {
+		return newIndexDimension(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read index dimension: %w", err)
+	}
+	if dim := vectorDimension(info["attributes"]); dim > 0 {
+		return dim, nil
+	}
+	return config.GetConfig().RagModelConfig.RagDimension, nil
+}
+
+// truncatedEmbedder 把向量截断到前 dimensions 维并重新归一化（L2 范数为 1）
+// 模型输出的维度正好等于 dimensions 时原样返回，小于 dimensions 时返回错误
+type truncatedEmbedder struct {
+	embedder   embedding.Embedder
+	dimensions int
+}
+
+// withDimensions dimensions 为 0 时不做截断
+func withDimensions(embedder embedding.Embedder, dimensions int) embedding.Embedder {
+	if dimensions <= 0 {
+		return embedder
+	}
+	return &truncatedEmbedder{embedder: embedder, dimensions: dimensions}
+}
+
+func (e *truncatedEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors, err := e.embedder.EmbedStrings(ctx, texts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i, vec := range vectors {
+		if len(vec) < e.dimensions {
+			return nil, fmt.Errorf("%w: model outputs %d dimensions, cannot reduce to %d", ErrEmbeddingFailed, len(vec), e.dimensions)
+		}
+		if len(vec) > e.dimensions {
+			vectors[i] = normalizeVector(vec[:e.dimensions])
+		}
+	}
+	return vectors, nil
+}
+
+func (e *truncatedEmbedder) Close() error {
+	return closeEmbedder(e.embedder)
+}
+
+// normalizeVector 返回 L2 范数为 1 的新向量，零向量原样返回
+func normalizeVector(vec []float64) []float64 {
+	var sum float64
+	for _, v := range vec {
+		sum += v * v
+	}
+	out := make([]float64, len(vec))
+	if sum == 0 {
+		copy(out, vec)
+		return out
+	}
+	norm := math.Sqrt(sum)
+	for i, v := range vec {
+		out[i] = v / norm
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 向量距离度量的选择（配置项 distanceMetric，只在新建知识库时生效）：
+//   - COSINE（默认）：适用于所有向量模型，向量是否归一化都不影响结果，不确定时使用它
+//   - IP：火山方舟（ark）、OpenAI 兼容接口的向量模型输出的向量已归一化，内积与余弦相似度等价且计算更快
+//   - L2：只适合按欧氏距离训练的向量模型；Ollama 上的部分模型输出未归一化的向量，使用 IP 会得到错误的排序
+//
+// 不论哪种度量，MetaData["distance"] 都是 Redis 返回的原始距离（越小越相似），
+// MetaData["score"] 是换算后的相似度（越大越相似），方便调用方用同一种方式理解检索结果。
+// 注意 RetrieveOptions.MaxDistance 比较的是原始距离，不同度量下的取值范围不同。
+
+// 不同版本的 RediSearch / 客户端返回向量距离的字段名不同：KNN 查询中用 AS 指定别名时为别名（本项目为 distance），
+// 没有别名时为 __<向量字段>_score（例如 __vector_score）；按顺序取第一个存在的字段
+var vectorScoreFields = []string{"distance", "__vector_score", "vector_score"}
+
+// isVectorScoreField 字段是否为向量距离，这些字段由 decodeDistance 统一处理，不作为普通元数据
+func isVectorScoreField(field string) bool {
+	return (strings.HasPrefix(field, "__") && strings.HasSuffix(field, "_score")) || slices.Contains(vectorScoreFields, field)
+}
+
+// decodeDistance 从检索结果的字段中找到向量距离并解析为 float64，ok 为 false 表示没有距离（例如关键词检索的结果）
+// 较新的版本在 RESP3 下可能返回科学计数法（1.19209e-07）或 inf，都能解析；NaN 视为错误
+func decodeDistance(fields map[string]string) (float64, bool, error) {
+	field, val, ok := "", "", false
+	for _, f := range vectorScoreFields {
+		if val, ok = fields[f]; ok {
+			field = f
+			break
+		}
+	}
+	if !ok {
+		for f, v := range fields {
+			if isVectorScoreField(f) {
+				field, val, ok = f, v, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	distance, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil || math.IsNaN(distance) {
+		return 0, false, fmt.Errorf("invalid %s %q", field, val)
+	}
+	return distance, true, nil
+}
+
+// normalizeDistance 按距离度量修正浮点误差：COSINE 的距离在 0 到 2 之间，L2 的距离不小于 0，
+// 完全相同的向量可能得到 -1e-7 这样的值；IP 的距离（1 - 内积）在向量未归一化时可以为负，保持不变
+func normalizeDistance(metric string, distance float64) float64 {
+	switch metric {
+	case redisPkg.DistanceL2:
+		return math.Max(distance, 0)
+	case redisPkg.DistanceIP:
+		return distance
+	default:
+		return min(max(distance, 0), 2)
+	}
+}
+
+// similarityScore 把 Redis 返回的向量距离换算为相似度
+//   - COSINE：距离为 1 - 余弦相似度，相似度为 1 - 距离（-1 到 1）
+//   - IP：距离为 1 - 内积，相似度为 1 - 距离（即内积）
+//   - L2：距离为欧氏距离的平方，相似度为 1 / (1 + 距离)（0 到 1）
+func similarityScore(metric string, distance float64) float64 {
+	if metric == redisPkg.DistanceL2 {
+		return 1 / (1 + distance)
+	}
+	return 1 - distance
+}
+
+// withSimilarityScore 包装文档转换函数，按距离度量修正向量距离，并为带有向量距离的文档加上 score 字段
+func withSimilarityScore(metric string, convert func(context.Context, redisCli.Document) (*schema.Document, error)) func(context.Context, redisCli.Document) (*schema.Document, error) {
+	return func(ctx context.Context, doc redisCli.Document) (*schema.Document, error) {
+		resp, err := convert(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		if distance, ok := resp.MetaData["distance"].(float64); ok {
+			distance = normalizeDistance(metric, distance)
+			resp.MetaData["distance"] = distance
+			resp.MetaData["score"] = similarityScore(metric, distance)
+		}
+		return resp, nil
+	}
+}
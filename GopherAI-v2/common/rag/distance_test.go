@@ -0,0 +1,192 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+func distanceDocs(distances ...any) []*schema.Document {
+	docs := make([]*schema.Document, len(distances))
+	for i, d := range distances {
+		docs[i] = &schema.Document{ID: string(rune('a' + i)), MetaData: map[string]any{}}
+		if d != nil {
+			docs[i].MetaData["distance"] = d
+		}
+	}
+	return docs
+}
+
+func TestFilterByDistance(t *testing.T) {
+	tests := []struct {
+		name        string
+		docs        []*schema.Document
+		maxDistance float64
+		want        []string
+	}{
+		{"keeps close", distanceDocs(0.1, 0.2), 0.5, []string{"a", "b"}},
+		{"drops far", distanceDocs(0.1, 0.6, 0.3), 0.5, []string{"a", "c"}},
+		{"inclusive boundary", distanceDocs(0.5), 0.5, []string{"a"}},
+		{"drops missing distance", distanceDocs(nil, 0.1), 0.5, []string{"b"}},
+		{"drops non-numeric distance", distanceDocs("0.1"), 0.5, nil},
+		{"empty", nil, 0.5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterByDistance(tt.docs, tt.maxDistance)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d docs, want %v", len(got), tt.want)
+			}
+			for i, doc := range got {
+				if doc.ID != tt.want[i] {
+					t.Errorf("doc %d = %s, want %s", i, doc.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithSimilarityScore(t *testing.T) {
+	tests := []struct {
+		name         string
+		metric       string
+		distance     string
+		wantDistance float64
+		wantScore    float64
+	}{
+		{"cosine", redisPkg.DistanceCosine, "0.25", 0.25, 0.75},
+		{"cosine rounding below zero", redisPkg.DistanceCosine, "-1.19209e-07", 0, 1},
+		{"cosine above two", redisPkg.DistanceCosine, "2.0000001", 2, -1},
+		{"default metric", "", "0.4", 0.4, 0.6},
+		{"ip keeps negative", redisPkg.DistanceIP, "-0.5", -0.5, 1.5},
+		{"l2", redisPkg.DistanceL2, "1", 1, 0.5},
+		{"l2 rounding below zero", redisPkg.DistanceL2, "-1e-7", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			convert := withSimilarityScore(tt.metric, convertDocument)
+			doc, err := convert(context.Background(), redisCli.Document{ID: "k", Fields: map[string]string{"distance": tt.distance}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.MetaData["distance"].(float64); math.Abs(got-tt.wantDistance) > 1e-9 {
+				t.Errorf("distance = %v, want %v", got, tt.wantDistance)
+			}
+			if got := doc.MetaData["score"].(float64); math.Abs(got-tt.wantScore) > 1e-9 {
+				t.Errorf("score = %v, want %v", got, tt.wantScore)
+			}
+		})
+	}
+
+	// 关键词检索的结果没有向量距离，不应该加上 score
+	doc, err := withSimilarityScore(redisPkg.DistanceCosine, convertDocument)(context.Background(), redisCli.Document{ID: "k", Fields: map[string]string{"content": "text"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.MetaData["score"]; ok {
+		t.Errorf("keyword result got score %v", doc.MetaData["score"])
+	}
+}
+
+// 不同版本的 RediSearch 返回的向量检索结果（FT.SEARCH ... KNN，RESP2）中的字段
+func TestConvertDocumentRediSearchPayloads(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       map[string]string
+		wantDistance float64
+		wantOK       bool
+		wantErr      bool
+	}{
+		// 2.4：KNN 查询中用 AS distance 指定别名（本项目的写法）
+		{"2.4 with alias", map[string]string{"content": "a", "metadata": "a.md", "distance": "0.0857143402100"}, 0.0857143402100, true, false},
+		// 2.2 / 2.4：没有别名时为 __<向量字段>_score
+		{"2.2 default score field", map[string]string{"content": "a", "metadata": "a.md", "__vector_score": "0.25"}, 0.25, true, false},
+		// 2.8：完全相同的向量返回科学计数法表示的浮点误差
+		{"2.8 scientific notation", map[string]string{"content": "a", "distance": "1.19209289551e-07"}, 1.19209289551e-07, true, false},
+		{"2.8 negative rounding error", map[string]string{"content": "a", "distance": "-1.19209e-07"}, -1.19209e-07, true, false},
+		// 8.x（Redis 8 内置的查询引擎）：向量字段不叫 vector 时为 __<字段名>_score
+		{"8.x custom vector field", map[string]string{"content": "a", "__embedding_score": "0.4"}, 0.4, true, false},
+		{"surrounding whitespace", map[string]string{"distance": " 0.5 "}, 0.5, true, false},
+		{"l2 overflow", map[string]string{"distance": "inf"}, math.Inf(1), true, false},
+		{"alias preferred", map[string]string{"distance": "0.1", "__vector_score": "0.9"}, 0.1, true, false},
+		// 关键词检索的结果没有向量距离
+		{"keyword result", map[string]string{"content": "a", "metadata": "a.md"}, 0, false, false},
+		{"nan", map[string]string{"distance": "nan"}, 0, false, true},
+		{"not a number", map[string]string{"distance": "close"}, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := convertDocument(context.Background(), redisCli.Document{ID: "rag_docs:alice:a.md:1", Fields: tt.fields})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", doc.MetaData)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			distance, ok := doc.MetaData["distance"].(float64)
+			if ok != tt.wantOK || distance != tt.wantDistance {
+				t.Errorf("distance = %v (present %v), want %v (present %v)", doc.MetaData["distance"], ok, tt.wantDistance, tt.wantOK)
+			}
+			// 原始的得分字段不作为普通元数据返回
+			for field := range tt.fields {
+				if isVectorScoreField(field) && field != "distance" {
+					if _, ok := doc.MetaData[field]; ok {
+						t.Errorf("score field %s leaked into metadata", field)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseSearchReply(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     any
+		wantIDs   []string
+		wantScore []float64
+		wantErr   bool
+	}{
+		{"keyword results with scores", []any{int64(2),
+			"rag_docs:alice:a.md:1", "1.5", []any{"content", "first", "chunk_index", "0"},
+			"rag_docs:alice:a.md:2", "0.75", []any{"content", "second", "chunk_index", "1"},
+		}, []string{"rag_docs:alice:a.md:1", "rag_docs:alice:a.md:2"}, []float64{1.5, 0.75}, false},
+		{"no results", []any{int64(0)}, nil, nil, false},
+		{"total larger than page", []any{int64(10), "k1", "2", []any{"content", "x"}}, []string{"k1"}, []float64{2}, false},
+		{"unexpected type", "OK", nil, nil, true},
+		{"empty", []any{}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := parseSearchReply(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(docs) != len(tt.wantIDs) {
+				t.Fatalf("got %d docs, want %d", len(docs), len(tt.wantIDs))
+			}
+			for i, doc := range docs {
+				if doc.ID != tt.wantIDs[i] || doc.Score == nil || *doc.Score != tt.wantScore[i] {
+					t.Errorf("doc %d = %s (score %v), want %s (score %v)", i, doc.ID, doc.Score, tt.wantIDs[i], tt.wantScore[i])
+				}
+				converted, err := convertDocument(context.Background(), doc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if converted.MetaData["keyword_score"] != tt.wantScore[i] {
+					t.Errorf("keyword_score = %v, want %v", converted.MetaData["keyword_score"], tt.wantScore[i])
+				}
+				if _, ok := converted.MetaData["distance"]; ok {
+					t.Errorf("keyword result has distance %v", converted.MetaData["distance"])
+				}
+			}
+		})
+	}
+}
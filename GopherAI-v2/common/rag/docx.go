@@ -0,0 +1,128 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 与 PDF 一样，这里只依赖标准库解析 .docx：
+// .docx 是一个 zip 包，正文在 word/document.xml 中，按段落（<w:p>）顺序提取 <w:t> 中的文本，
+// 图片、图形（<w:drawing> / <w:pict>）会被跳过。
+// 加密的 .docx 实际是 OLE 复合文档而不是 zip 包，无法解析。
+
+// ErrEncryptedDocument 文档已加密（设置了打开密码），无法提取文本
+var ErrEncryptedDocument = errors.New("document is password-protected")
+
+// OLE 复合文档的文件头，加密的 Office 文档使用这种格式
+var oleHeader = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// extractDocxText 提取 .docx 正文，段落之间以换行分隔
+func extractDocxText(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, len(oleHeader))
+	if _, err := io.ReadFull(f, header); err == nil && bytes.Equal(header, oleHeader) {
+		return "", ErrEncryptedDocument
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return "", fmt.Errorf("invalid docx file: %w", err)
+	}
+
+	var doc *zip.File
+	for _, zf := range zr.File {
+		if zf.Name == "word/document.xml" {
+			doc = zf
+			break
+		}
+	}
+	if doc == nil {
+		return "", fmt.Errorf("invalid docx file: word/document.xml not found")
+	}
+	rc, err := doc.Open()
+	if err != nil {
+		return "", fmt.Errorf("invalid docx file: %w", err)
+	}
+	defer rc.Close()
+
+	paragraphs, err := parseDocxParagraphs(rc)
+	if err != nil {
+		return "", fmt.Errorf("invalid docx file: %w", err)
+	}
+	text := strings.Join(paragraphs, "\n")
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("docx file contains no text")
+	}
+	return text, nil
+}
+
+// parseDocxParagraphs 按顺序读取 document.xml 中每个段落的文本（表格中的段落同样按顺序输出）
+func parseDocxParagraphs(r io.Reader) ([]string, error) {
+	dec := xml.NewDecoder(r)
+	var paragraphs []string
+	var current strings.Builder
+	inText := false
+	skipDepth := 0 // 大于 0 时表示位于图片等需要跳过的元素中
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			switch t.Name.Local {
+			case "drawing", "pict", "object":
+				skipDepth = 1
+			case "p":
+				current.Reset()
+			case "t":
+				inText = true
+			case "tab":
+				current.WriteString("\t")
+			case "br", "cr":
+				current.WriteString("\n")
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraphs = append(paragraphs, current.String())
+				current.Reset()
+			}
+		case xml.CharData:
+			if inText && skipDepth == 0 {
+				current.Write(t)
+			}
+		}
+	}
+	return paragraphs, nil
+}
@@ -0,0 +1,56 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// 未配置 maxEmbeddingInputs 时单次向量模型请求最多包含的文本数
+// 各家服务的上限不同（Ark、OpenAI 都不低于这个值），超过上限时请求会直接报错
+const defaultMaxEmbeddingInputs = 64
+
+// batchedEmbedder 把文本拆成每批不超过 maxInputs 条分别请求向量模型，结果按原顺序拼接
+// 与写入 Redis 的批大小（IndexOptions.BatchSize）无关，写入批再大也不会超过向量模型的上限
+type batchedEmbedder struct {
+	embedder  embedding.Embedder
+	maxInputs int
+}
+
+// withInputLimit 按配置 maxEmbeddingInputs 限制单次请求的文本数，0 表示使用默认值 64
+func withInputLimit(embedder embedding.Embedder) embedding.Embedder {
+	maxInputs := config.GetConfig().RagModelConfig.RagMaxEmbeddingInputs
+	if maxInputs <= 0 {
+		maxInputs = defaultMaxEmbeddingInputs
+	}
+	return &batchedEmbedder{embedder: embedder, maxInputs: maxInputs}
+}
+
+// EmbedStrings 实现 embedding.Embedder，向量模型返回的错误都包装为 ErrEmbeddingFailed
+// 每一批返回的向量数必须与文本数相同，否则返回错误（向量和文本错位会把错误的向量写入知识库）
+func (e *batchedEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += e.maxInputs {
+		batch := texts[start:min(start+e.maxInputs, len(texts))]
+		batchVectors, err := e.embedder.EmbedStrings(ctx, batch, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEmbeddingFailed, err)
+		}
+		if len(batchVectors) != len(batch) {
+			return nil, fmt.Errorf("%w: embedder returned %d vectors for %d texts", ErrEmbeddingFailed, len(batchVectors), len(batch))
+		}
+		for i, vec := range batchVectors {
+			if len(vec) == 0 {
+				return nil, fmt.Errorf("%w: embedder returned an empty vector for text %d", ErrEmbeddingFailed, start+i)
+			}
+		}
+		vectors = append(vectors, batchVectors...)
+	}
+	return vectors, nil
+}
+
+func (e *batchedEmbedder) Close() error {
+	return closeEmbedder(e.embedder)
+}
@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// recordingEmbedder 记录每次请求的文本数，向量的第一维是文本在所有请求中的序号；
+// short 为 true 时少返回一个向量
+type recordingEmbedder struct {
+	batches []int
+	next    int
+	short   bool
+}
+
+func (e *recordingEmbedder) EmbedStrings(_ context.Context, texts []string, _ ...embedding.Option) ([][]float64, error) {
+	e.batches = append(e.batches, len(texts))
+	vectors := make([][]float64, len(texts))
+	for i := range vectors {
+		vectors[i] = []float64{float64(e.next)}
+		e.next++
+	}
+	if e.short {
+		vectors = vectors[:len(vectors)-1]
+	}
+	return vectors, nil
+}
+
+func TestWithInputLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxInputs   int
+		texts       int
+		wantBatches []int
+	}{
+		{"default limit", 0, 130, []int{64, 64, 2}},
+		{"under limit", 10, 3, []int{3}},
+		{"exact multiple", 2, 6, []int{2, 2, 2}},
+		{"remainder", 4, 10, []int{4, 4, 2}},
+		{"one per request", 1, 3, []int{1, 1, 1}},
+		{"negative uses default", -1, 65, []int{64, 1}},
+		{"no texts", 8, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{}
+			conf.RagModelConfig.RagMaxEmbeddingInputs = tt.maxInputs
+			config.SetConfig(conf)
+
+			inner := &recordingEmbedder{}
+			texts := make([]string, tt.texts)
+			for i := range texts {
+				texts[i] = fmt.Sprintf("text %d", i)
+			}
+			vectors, err := withInputLimit(inner).EmbedStrings(context.Background(), texts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(inner.batches, tt.wantBatches) {
+				t.Errorf("batches = %v, want %v", inner.batches, tt.wantBatches)
+			}
+			if len(vectors) != tt.texts {
+				t.Fatalf("got %d vectors, want %d", len(vectors), tt.texts)
+			}
+			for i, vec := range vectors {
+				if vec[0] != float64(i) {
+					t.Fatalf("vector %d belongs to text %v", i, vec[0])
+				}
+			}
+		})
+	}
+}
+
+func TestWithInputLimitVectorCountMismatch(t *testing.T) {
+	conf := &config.Config{}
+	conf.RagModelConfig.RagMaxEmbeddingInputs = 2
+	config.SetConfig(conf)
+
+	_, err := withInputLimit(&recordingEmbedder{short: true}).EmbedStrings(context.Background(), []string{"a", "b", "c"})
+	if !errors.Is(err, ErrEmbeddingFailed) {
+		t.Errorf("err = %v, want ErrEmbeddingFailed", err)
+	}
+}
@@ -0,0 +1,173 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 默认向量缓存时间
+const defaultEmbeddingCacheTTL = 24 * time.Hour
+
+// CacheStats 向量缓存命中统计
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// cacheCounters 缓存命中计数器，多个 CachedEmbedder 可以共享同一个计数器
+type cacheCounters struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// 进程内所有 RAG 索引器 / 查询器共享的计数器
+var sharedCacheCounters = &cacheCounters{}
+
+// CachedEmbedder 带 Redis 缓存的向量生成器
+// 以 sha256(文本) 为 key 缓存向量，相同文本（重复的问题、未变化的文档块）不再重复调用向量模型
+type CachedEmbedder struct {
+	embedder embedding.Embedder
+	model    string // 不同模型生成的向量不能混用，缓存 key 中包含模型名
+	ttl      time.Duration
+	counters *cacheCounters
+}
+
+// NewCachedEmbedder 用 Redis 缓存包装一个向量生成器，ttl <= 0 时使用默认值 24 小时
+func NewCachedEmbedder(embedder embedding.Embedder, model string, ttl time.Duration) *CachedEmbedder {
+	return newCachedEmbedder(embedder, model, ttl, &cacheCounters{})
+}
+
+func newCachedEmbedder(embedder embedding.Embedder, model string, ttl time.Duration, counters *cacheCounters) *CachedEmbedder {
+	if ttl <= 0 {
+		ttl = defaultEmbeddingCacheTTL
+	}
+	return &CachedEmbedder{
+		embedder: embedder,
+		model:    model,
+		ttl:      ttl,
+		counters: counters,
+	}
+}
+
+// withEmbeddingCache 按配置为 RAG 使用的向量生成器加上缓存
+func withEmbeddingCache(embedder embedding.Embedder, model string) *CachedEmbedder {
+	ttl := time.Duration(config.GetConfig().RagModelConfig.RagEmbeddingCacheTTL) * time.Second
+	return newCachedEmbedder(embedder, model, ttl, sharedCacheCounters)
+}
+
+// EmbeddingCacheStats 返回进程内 RAG 向量缓存的命中统计
+func EmbeddingCacheStats() CacheStats {
+	return sharedCacheCounters.stats()
+}
+
+// Stats 返回缓存命中统计
+func (c *CachedEmbedder) Stats() CacheStats {
+	return c.counters.stats()
+}
+
+func (c *cacheCounters) stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// skipEmbeddingCacheKey ctx 中带有该标记时不读写向量缓存
+type skipEmbeddingCacheKey struct{}
+
+// withoutEmbeddingCache 返回跳过向量缓存的 ctx，向量化时一定会调用向量模型（用于预热）
+func withoutEmbeddingCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipEmbeddingCacheKey{}, true)
+}
+
+// EmbedStrings 实现 embedding.Embedder
+// 先批量查缓存，只对未命中的文本调用向量模型，再把结果写回缓存
+// 缓存读写失败只记录日志，不影响向量化本身
+func (c *CachedEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	if skip, _ := ctx.Value(skipEmbeddingCacheKey{}).(bool); skip || len(texts) == 0 {
+		return c.embedder.EmbedStrings(ctx, texts, opts...)
+	}
+
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = redisPkg.GenerateEmbeddingCacheKey(c.model, text)
+	}
+
+	// 用 pipeline 逐个 GET 而不是 MGET：集群模式下这些 key 分布在不同的 slot 上
+	vectors := make([][]float64, len(texts))
+	getPipe := redisPkg.Rdb.Pipeline()
+	gets := make([]*redisCli.StringCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = getPipe.Get(ctx, key)
+	}
+	if _, err := getPipe.Exec(ctx); err != nil && err != redisCli.Nil {
+		logger.Warn("embedding cache lookup failed", "error", err)
+	}
+	for i, cmd := range gets {
+		if s, err := cmd.Result(); err == nil {
+			if vec, ok := decodeVector(s); ok {
+				vectors[i] = vec
+			}
+		}
+	}
+
+	var missTexts []string
+	var missIdx []int
+	for i, vec := range vectors {
+		if vec == nil {
+			missTexts = append(missTexts, texts[i])
+			missIdx = append(missIdx, i)
+		}
+	}
+	c.counters.hits.Add(int64(len(texts) - len(missTexts)))
+	c.counters.misses.Add(int64(len(missTexts)))
+	if len(missTexts) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := c.embedder.EmbedStrings(ctx, missTexts, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(missTexts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(embedded), len(missTexts))
+	}
+
+	pipe := redisPkg.Rdb.Pipeline()
+	for j, i := range missIdx {
+		vectors[i] = embedded[j]
+		pipe.Set(ctx, keys[i], encodeVector(embedded[j]), c.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("embedding cache store failed", "error", err)
+	}
+	return vectors, nil
+}
+
+// encodeVector 将向量编码为 float32 小端字节序（与 Redis 向量索引中的存储精度一致）
+func encodeVector(vec []float64) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return buf
+}
+
+// decodeVector 解码 encodeVector 生成的字节串
+func decodeVector(s string) ([]float64, bool) {
+	if len(s) == 0 || len(s)%4 != 0 {
+		return nil, false
+	}
+	b := []byte(s)
+	vec := make([]float64, len(b)/4)
+	for i := range vec {
+		vec[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:])))
+	}
+	return vec, true
+}
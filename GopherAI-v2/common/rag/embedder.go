@@ -0,0 +1,212 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	embeddingArk "github.com/cloudwego/eino-ext/components/embedding/ark"
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+// 支持的向量模型服务
+const (
+	EmbeddingProviderArk    = "ark"    // 火山方舟（默认）
+	EmbeddingProviderOpenAI = "openai" // OpenAI 及兼容 /embeddings 接口的服务
+	EmbeddingProviderOllama = "ollama" // 本地 Ollama
+)
+
+// Ollama 默认监听地址
+const defaultOllamaBaseURL = "http://localhost:11434"
+
+// ErrAPIKeyMissing 没有配置模型服务的 API Key，可通过 errors.Is 判断
+var ErrAPIKeyMissing = errors.New("api key is not configured: set ragModelConfig.apiKey or the OPENAI_API_KEY environment variable")
+
+// ErrEmbeddingFailed 调用向量模型失败（服务报错、返回的向量数与文本数不符等），可通过 errors.Is 判断，
+// 原始错误仍然可以通过 errors.Is / errors.As 取到
+var ErrEmbeddingFailed = errors.New("embedding failed")
+
+// CheckAPIKeys 启动时检查模型服务的 API Key 是否已配置，避免第一次上传文件或提问时才在模型客户端内部报错
+// 对话模型总是需要 API Key，未单独配置 embeddingApiKey 时向量模型也使用它
+func CheckAPIKeys() error {
+	if config.GetConfig().RagModelConfig.APIKey() == "" {
+		return fmt.Errorf("ragModelConfig.apiKey: %w", ErrAPIKeyMissing)
+	}
+	return nil
+}
+
+// EmbedderConfig 创建向量生成器所需的配置
+type EmbedderConfig struct {
+	Provider string // ark / openai / ollama，为空时使用 ark
+	BaseURL  string
+	APIKey   string
+	Model    string
+	// Dimensions 大于 0 时把向量截断到这个维度并重新归一化（见 dimension.go），0 表示使用模型原始维度
+	Dimensions int
+}
+
+// EmbedderFactory 根据配置创建某一种服务的向量生成器
+type EmbedderFactory func(ctx context.Context, cfg EmbedderConfig) (embedding.Embedder, error)
+
+var embedderFactories = map[string]EmbedderFactory{
+	EmbeddingProviderArk:    newArkEmbedder,
+	EmbeddingProviderOpenAI: newOpenAIEmbedder,
+	EmbeddingProviderOllama: newOllamaEmbedder,
+}
+
+// NewEmbedder 按 Provider 创建向量生成器，缺少必填配置或 Provider 未知时返回错误
+func NewEmbedder(ctx context.Context, cfg EmbedderConfig) (embedding.Embedder, error) {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		provider = EmbeddingProviderArk
+	}
+	factory, ok := embedderFactories[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q: must be one of %s, %s, %s",
+			cfg.Provider, EmbeddingProviderArk, EmbeddingProviderOpenAI, EmbeddingProviderOllama)
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("embedding provider %s: model is required", provider)
+	}
+	return factory(ctx, cfg)
+}
+
+// embedderConfigFromConfig 从 ragModelConfig 读取向量模型配置
+// embeddingBaseUrl 未配置时使用 baseUrl
+func embedderConfigFromConfig(model string) EmbedderConfig {
+	conf := config.GetConfig().RagModelConfig
+	baseURL := conf.RagEmbeddingBaseUrl
+	if baseURL == "" {
+		baseURL = conf.RagBaseUrl
+	}
+	return EmbedderConfig{
+		Provider: conf.RagEmbeddingProvider,
+		BaseURL:  baseURL,
+		APIKey:   conf.EmbeddingAPIKey(),
+		Model:    model,
+	}
+}
+
+func newArkEmbedder(ctx context.Context, cfg EmbedderConfig) (embedding.Embedder, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("embedding provider ark: %w", ErrAPIKeyMissing)
+	}
+	return embeddingArk.NewEmbedder(ctx, &embeddingArk.EmbeddingConfig{
+		BaseURL: cfg.BaseURL,
+		APIKey:  cfg.APIKey,
+		Model:   cfg.Model,
+	})
+}
+
+func newOpenAIEmbedder(ctx context.Context, cfg EmbedderConfig) (embedding.Embedder, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("embedding provider openai: base url is required")
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("embedding provider openai: %w", ErrAPIKeyMissing)
+	}
+	return &httpEmbedder{cfg: cfg, path: "/embeddings", decode: decodeOpenAIEmbeddings}, nil
+}
+
+func newOllamaEmbedder(ctx context.Context, cfg EmbedderConfig) (embedding.Embedder, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultOllamaBaseURL
+	}
+	return &httpEmbedder{cfg: cfg, path: "/api/embed", decode: decodeOllamaEmbeddings}, nil
+}
+
+// httpEmbedder 通过 HTTP 接口调用向量模型
+// OpenAI（/embeddings）和 Ollama（/api/embed）的请求体格式相同，只有响应格式不同
+type httpEmbedder struct {
+	cfg    EmbedderConfig
+	path   string
+	decode func(body []byte, n int) ([][]float64, error)
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbedStrings 实现 embedding.Embedder
+func (e *httpEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	if len(texts) == 0 {
+		return [][]float64{}, nil
+	}
+	bodyBytes, err := json.Marshal(embedRequest{Model: e.cfg.Model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimRight(e.cfg.BaseURL, "/") + e.path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embedding service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// 错误信息中带上状态码，便于重试逻辑识别 429 / 503 等临时错误
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding service returned status %d: %s", resp.StatusCode, respBody)
+	}
+	return e.decode(respBody, len(texts))
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+func decodeOpenAIEmbeddings(body []byte, n int) ([][]float64, error) {
+	var result openAIEmbeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(result.Data) != n {
+		return nil, fmt.Errorf("embedding service returned %d vectors for %d texts", len(result.Data), n)
+	}
+	// 按 index 排序，保证向量与输入文本一一对应
+	sort.Slice(result.Data, func(i, j int) bool { return result.Data[i].Index < result.Data[j].Index })
+	vectors := make([][]float64, n)
+	for i, d := range result.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
+
+type ollamaEmbeddingResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+func decodeOllamaEmbeddings(body []byte, n int) ([][]float64, error) {
+	var result ollamaEmbeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+	if len(result.Embeddings) != n {
+		return nil, fmt.Errorf("embedding service returned %d vectors for %d texts", len(result.Embeddings), n)
+	}
+	return result.Embeddings, nil
+}
@@ -0,0 +1,101 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	textUnicode "golang.org/x/text/encoding/unicode"
+)
+
+// ErrBinaryFile 文件看起来是二进制文件而不是文本，可通过 errors.Is 判断
+var ErrBinaryFile = errors.New("file appears to be binary, not text")
+
+// 检测二进制文件时检查的字节数
+const binarySniffLen = 8 << 10
+
+// 非 ASCII 字符中至少有这个比例符合某种编码的常用字符，才认为文件是这种编码
+const encodingConfidence = 0.8
+
+// decodeText 把文本文件的内容转换为 UTF-8
+// 依次尝试：BOM（UTF-8 / UTF-16）→ 合法的 UTF-8 → GB18030（兼容 GBK、GB2312）→ Windows-1252（兼容 Latin-1）；
+// 都无法确定时按 UTF-8 处理，非法的字节替换为 U+FFFD；明显是二进制的文件返回 ErrBinaryFile
+func decodeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return strings.ToValidUTF8(string(data[3:]), "\uFFFD"), nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		// UseBOM：按 BOM 判断字节序并去掉 BOM
+		text, err := textUnicode.UTF16(textUnicode.LittleEndian, textUnicode.UseBOM).NewDecoder().Bytes(data)
+		if err == nil {
+			return string(text), nil
+		}
+	}
+
+	if looksBinary(data) {
+		return "", ErrBinaryFile
+	}
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	if text, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data); err == nil && looksLike(string(text), isChineseRune) {
+		return string(text), nil
+	}
+	if text, err := charmap.Windows1252.NewDecoder().Bytes(data); err == nil && looksLike(string(text), isLatinRune) {
+		return string(text), nil
+	}
+	return strings.ToValidUTF8(string(data), "\uFFFD"), nil
+}
+
+// looksBinary 文件开头含有 NUL 字节，或控制字符（制表、换行等排版字符除外）超过 10% 时认为是二进制文件
+func looksBinary(data []byte) bool {
+	sample := data[:min(len(data), binarySniffLen)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, b := range sample {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1B {
+			control++
+		}
+	}
+	return control*10 > len(sample)
+}
+
+// looksLike 判断文本中的非 ASCII 字符是否大多符合 match；解码出 U+FFFD 说明编码不对
+func looksLike(text string, match func(rune) bool) bool {
+	var nonASCII, matched int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		if r == utf8.RuneError {
+			return false
+		}
+		nonASCII++
+		if match(r) {
+			matched++
+		}
+	}
+	return nonASCII > 0 && float64(matched) >= encodingConfidence*float64(nonASCII)
+}
+
+// isChineseRune 汉字、中文标点和全角字符
+func isChineseRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		(r >= 0x3000 && r <= 0x303F) || // 中文标点
+		(r >= 0xFF00 && r <= 0xFFEF) || // 全角字符
+		(r >= 0x2010 && r <= 0x2027) // 引号、破折号、省略号
+}
+
+// isLatinRune 带重音的拉丁字母和 Windows-1252 中常见的标点
+func isLatinRune(r rune) bool {
+	return unicode.Is(unicode.Latin, r) ||
+		(r >= 0x00A0 && r <= 0x00BF) || // 不换行空格、货币符号、« » 等
+		(r >= 0x2010 && r <= 0x2027) || // 弯引号、破折号、省略号
+		r == 0x20AC // €
+}
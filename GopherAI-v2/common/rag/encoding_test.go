@@ -0,0 +1,60 @@
+package rag
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr error
+	}{
+		{"ascii", []byte("hello"), "hello", nil},
+		{"utf-8", []byte("中文 café"), "中文 café", nil},
+		{"utf-8 bom", []byte("\xEF\xBB\xBF中文"), "中文", nil},
+		{"utf-16le bom", []byte{0xFF, 0xFE, 0x2D, 0x4E, 0x87, 0x65}, "中文", nil},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0x4E, 0x2D, 0x65, 0x87}, "中文", nil},
+		// "中文测试，好的。" 的 GBK 编码
+		{"gbk", []byte("\xD6\xD0\xCE\xC4\xB2\xE2\xCA\xD4\xA3\xAC\xBA\xC3\xB5\xC4\xA1\xA3"), "中文测试，好的。", nil},
+		{"gbk mixed with ascii", []byte("Go \xD3\xEF\xD1\xD4 1.24"), "Go 语言 1.24", nil},
+		// "café – naïve €" 的 Windows-1252 编码
+		{"windows-1252", []byte("caf\xE9 \x96 na\xEFve \x80"), "café – naïve €", nil},
+		{"nul byte", []byte("text\x00more"), "", ErrBinaryFile},
+		{"control characters", []byte("\x01\x02\x03\x04abc"), "", ErrBinaryFile},
+		{"empty", nil, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeText(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeText err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractSegmentsGBKFile(t *testing.T) {
+	segments, err := extractSegments("testdata/gbk.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 {
+		t.Fatalf("got %d segments, want 1", len(segments))
+	}
+	text := segments[0].Text
+	for _, want := range []string{"# 产品说明", "使用 GBK 编码保存的中文文档", "转换为 UTF-8。"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("decoded text %q does not contain %q", text, want)
+		}
+	}
+	if strings.ContainsRune(text, '\uFFFD') {
+		t.Errorf("decoded text contains replacement characters: %q", text)
+	}
+}
@@ -0,0 +1,35 @@
+package rag
+
+import "GopherAI/config"
+
+// IndexEstimate 索引一个文件的预估结果
+type IndexEstimate struct {
+	Chunks        int     `json:"chunks"`         // 文档块数（即向量数）
+	Tokens        int     `json:"tokens"`         // 需要向量化的 token 总数（粗略估算）
+	EstimatedCost float64 `json:"estimated_cost"` // 预估费用，按 embeddingPricePerMillionTokens 计算，未配置价格时为 0
+}
+
+// EstimateIndex 按默认配置预估索引文件会产生的文档块数、token 数和费用
+func EstimateIndex(filePath string) (*IndexEstimate, error) {
+	return EstimateIndexWithOptions(filePath, IndexOptions{})
+}
+
+// EstimateIndexWithOptions 按给定的切块配置预估索引文件的开销
+// 只在本地解析和切块，不调用向量模型，也不访问 Redis
+func EstimateIndexWithOptions(filePath string, opts IndexOptions) (*IndexEstimate, error) {
+	docs, err := loadDocuments(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Dedup {
+		docs = dedupDocuments(docs)
+	}
+
+	estimate := &IndexEstimate{Chunks: len(docs)}
+	for _, doc := range docs {
+		estimate.Tokens += estimateTokens(doc.Content)
+	}
+	price := config.GetConfig().RagModelConfig.RagEmbeddingPricePerMillionTokens
+	estimate.EstimatedCost = float64(estimate.Tokens) / 1e6 * price
+	return estimate, nil
+}
@@ -0,0 +1,117 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 多路检索最多生成的改写问题数
+const maxQueryVariants = 5
+
+// expandPromptText 让对话模型把简短的问题改写为更适合检索的问题
+const expandPromptText = `你是一个检索助手。请把下面的用户问题改写成 %d 个更完整、包含更多关键词的检索问题，用于在知识库中检索相关文档。
+每行输出一个问题，不要编号，不要回答问题，也不要添加任何解释。
+
+用户问题：%s`
+
+// expandQueries 调用对话模型改写问题，返回原问题和改写后的问题（已去重）
+// 改写失败时只返回原问题，不影响检索
+func (r *RAGQuery) expandQueries(ctx context.Context, query string) []string {
+	n := r.queryVariants
+	if n <= 0 {
+		n = 1
+	}
+	if n > maxQueryVariants {
+		n = maxQueryVariants
+	}
+
+	queries := []string{query}
+	llm, err := newChatModel(ctx, r.chat)
+	if err != nil {
+		logger.Warn("failed to expand query, using the original one", "error", err)
+		return queries
+	}
+	resp, err := llm.Generate(ctx, []*schema.Message{
+		schema.UserMessage(fmt.Sprintf(expandPromptText, n, query)),
+	})
+	if err != nil {
+		logger.Warn("failed to expand query, using the original one", "error", err)
+		return queries
+	}
+
+	seen := map[string]bool{query: true}
+	for _, line := range strings.Split(resp.Content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•0123456789.、)） "))
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		queries = append(queries, line)
+		if len(queries) > n {
+			break
+		}
+	}
+	return queries
+}
+
+// multiSearch 对每个问题分别检索，合并结果
+func (r *RAGQuery) multiSearch(ctx context.Context, queries []string, opts RetrieveOptions) ([]*schema.Document, error) {
+	results := make([][]*schema.Document, 0, len(queries))
+	for _, q := range queries {
+		docs, err := r.search(ctx, q, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, docs)
+	}
+	return mergeByDistance(r.candidateLimit(), results...), nil
+}
+
+// mergeByDistance 合并多路检索结果：按文档 ID 去重，同一文档保留距离最小的一份，
+// 按距离从小到大取前 topK 个；没有距离信息的文档（关键词检索）排在后面，保持首次出现的顺序
+func mergeByDistance(topK int, results ...[]*schema.Document) []*schema.Document {
+	best := make(map[string]*schema.Document)
+	var order []string
+	for _, docs := range results {
+		for _, doc := range docs {
+			existing, ok := best[doc.ID]
+			if !ok {
+				best[doc.ID] = doc
+				order = append(order, doc.ID)
+				continue
+			}
+			if d, ok := docDistance(doc); ok {
+				if prev, ok := docDistance(existing); !ok || d < prev {
+					best[doc.ID] = doc
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		di, oki := docDistance(best[order[i]])
+		dj, okj := docDistance(best[order[j]])
+		if oki != okj {
+			return oki
+		}
+		return oki && di < dj
+	})
+	if len(order) > topK {
+		order = order[:topK]
+	}
+
+	merged := make([]*schema.Document, 0, len(order))
+	for _, id := range order {
+		merged = append(merged, best[id])
+	}
+	return merged
+}
+
+func docDistance(doc *schema.Document) (float64, bool) {
+	d, ok := doc.MetaData["distance"].(float64)
+	return d, ok
+}
@@ -0,0 +1,261 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// ExportFormat ExportIndexToFile 的输出格式
+type ExportFormat string
+
+const (
+	// ExportJSONL 每行一个文档块：{"id": ..., "content": ..., "metadata": {...}}
+	ExportJSONL ExportFormat = "jsonl"
+	// ExportText 按来源拼接文档块的原文，相邻块之间的重叠部分去掉，每个来源前有一行 "==== 来源 ===="
+	ExportText ExportFormat = "text"
+)
+
+// ExportIndex 导出知识库中的所有文档块（原文和元数据，不含向量），按来源和 chunk_index 排序
+// 文档块很多时使用 ExportIndexFunc 逐个处理，避免一次性读入内存
+func ExportIndex(ctx context.Context, username, filename string) ([]*schema.Document, error) {
+	var docs []*schema.Document
+	_, err := ExportIndexFunc(ctx, username, filename, func(doc *schema.Document) error {
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// ExportIndexFunc 按来源和 chunk_index 的顺序逐个把文档块传给 fn，返回导出的文档块数
+// 先读出所有文档块的来源和序号排序，再按顺序分批读取内容，内存中同时只有一批文档块
+// fn 返回错误时停止导出并返回该错误；知识库不存在时返回 ErrIndexNotFound
+func ExportIndexFunc(ctx context.Context, username, filename string, fn func(*schema.Document) error) (int, error) {
+	if store, ok, err := configuredMemoryStore(username, filename); err != nil {
+		return 0, err
+	} else if ok {
+		return store.export(fn)
+	}
+
+	indexName := redisPkg.GenerateIndexName(username, filename)
+	resolved, err := redisPkg.ResolveIndexName(ctx, username, filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve index name: %w", err)
+	}
+	exists, err := redisPkg.IndexExists(ctx, resolved)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check index: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("%s: %w", indexName, ErrIndexNotFound)
+	}
+
+	client, err := redisPkg.IndexClient(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	prefix, err := currentDocKeyPrefix(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	keys, err := scanKeys(ctx, client, escapeGlob(prefix)+"*")
+	if err != nil {
+		return 0, err
+	}
+	if err := sortChunkKeys(ctx, client, keys); err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	for start := 0; start < len(keys); start += defaultBatchSize {
+		if err := ctx.Err(); err != nil {
+			return exported, err
+		}
+		batch := keys[start:min(start+defaultBatchSize, len(keys))]
+
+		pipe := client.Pipeline()
+		cmds := make([]*redisCli.MapStringStringCmd, len(batch))
+		for i, key := range batch {
+			cmds[i] = pipe.HGetAll(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return exported, fmt.Errorf("failed to read chunks: %w", err)
+		}
+		for i, cmd := range cmds {
+			fields := cmd.Val()
+			// 扫描之后被删除的文档块
+			if len(fields) == 0 {
+				continue
+			}
+			delete(fields, "vector")
+			doc, err := convertDocument(ctx, redisCli.Document{ID: batch[i], Fields: fields})
+			if err != nil {
+				return exported, err
+			}
+			if err := fn(doc); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+	}
+	return exported, nil
+}
+
+// sortChunkKeys 读出每个文档块的来源和 chunk_index，把 keys 按来源、序号排序
+func sortChunkKeys(ctx context.Context, client *redisCli.Client, keys []string) error {
+	type position struct {
+		source string
+		index  int
+	}
+	positions := make(map[string]position, len(keys))
+	for start := 0; start < len(keys); start += defaultBatchSize {
+		batch := keys[start:min(start+defaultBatchSize, len(keys))]
+		pipe := client.Pipeline()
+		cmds := make([]*redisCli.SliceCmd, len(batch))
+		for i, key := range batch {
+			cmds[i] = pipe.HMGet(ctx, key, "metadata", "chunk_index")
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to read chunk positions: %w", err)
+		}
+		for i, cmd := range cmds {
+			vals := cmd.Val()
+			source, _ := vals[0].(string)
+			index, _ := vals[1].(string)
+			positions[batch[i]] = position{source: source, index: metaInt(index)}
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := positions[keys[i]], positions[keys[j]]
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		if a.index != b.index {
+			return a.index < b.index
+		}
+		return keys[i] < keys[j]
+	})
+	return nil
+}
+
+// export 按来源和 chunk_index 的顺序把文档块的副本传给 fn
+func (s *MemoryVectorStore) export(fn func(*schema.Document) error) (int, error) {
+	s.data.mu.RLock()
+	docs := make([]*schema.Document, 0, len(s.data.docs))
+	for _, entry := range s.data.docs {
+		docs = append(docs, &schema.Document{ID: entry.doc.ID, Content: entry.doc.Content, MetaData: maps.Clone(entry.doc.MetaData)})
+	}
+	s.data.mu.RUnlock()
+
+	sort.Slice(docs, func(i, j int) bool {
+		a, _ := docs[i].MetaData["source"].(string)
+		b, _ := docs[j].MetaData["source"].(string)
+		if a != b {
+			return a < b
+		}
+		ai, bi := metaInt(docs[i].MetaData["chunk_index"]), metaInt(docs[j].MetaData["chunk_index"])
+		if ai != bi {
+			return ai < bi
+		}
+		return docs[i].ID < docs[j].ID
+	})
+	for i, doc := range docs {
+		if err := fn(doc); err != nil {
+			return i, err
+		}
+	}
+	return len(docs), nil
+}
+
+// ExportIndexToFile 把知识库导出到 path，返回导出的文档块数；format 为空时使用 ExportJSONL
+// 先写入同目录下的临时文件，全部写完后再重命名，导出失败时不会留下不完整的文件
+func ExportIndexToFile(ctx context.Context, username, filename, path string, format ExportFormat) (n int, err error) {
+	var write func(w *bufio.Writer, doc *schema.Document) error
+	switch format {
+	case ExportJSONL, "":
+		write = writeJSONLine
+	case ExportText:
+		write = newTextExporter()
+	default:
+		return 0, fmt.Errorf("%w: unknown export format %q", ErrInvalidOptions, format)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	n, err = ExportIndexFunc(ctx, username, filename, func(doc *schema.Document) error {
+		return write(w, doc)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = w.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+	return n, nil
+}
+
+// exportRecord JSONL 格式中的一行
+type exportRecord struct {
+	ID       string         `json:"id"`
+	Content  string         `json:"content"`
+	MetaData map[string]any `json:"metadata"`
+}
+
+func writeJSONLine(w *bufio.Writer, doc *schema.Document) error {
+	line, err := json.Marshal(exportRecord{ID: doc.ID, Content: doc.Content, MetaData: doc.MetaData})
+	if err != nil {
+		return fmt.Errorf("failed to encode chunk %s: %w", doc.ID, err)
+	}
+	w.Write(line)
+	return w.WriteByte('\n')
+}
+
+// newTextExporter 返回按来源拼接原文的写入函数，只记住上一个文档块用于去掉重叠部分
+func newTextExporter() func(w *bufio.Writer, doc *schema.Document) error {
+	var source, prev string
+	first := true
+	return func(w *bufio.Writer, doc *schema.Document) error {
+		docSource, _ := doc.MetaData["source"].(string)
+		if first || docSource != source {
+			if !first {
+				w.WriteString("\n\n")
+			}
+			w.WriteString("==== " + docSource + " ====\n")
+			_, err := w.WriteString(doc.Content)
+			source, prev, first = docSource, doc.Content, false
+			return err
+		}
+		joined := joinChunks(prev, doc.Content)
+		_, err := w.WriteString(joined[len(prev):])
+		prev = doc.Content
+		return err
+	}
+}
@@ -0,0 +1,62 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// textSegment 从文件中提取出的一段文本
+// 对于 PDF，每一页是一个 segment，Page 为页码（从 1 开始）；其它文件只有一个 segment，Page 为 0
+type textSegment struct {
+	Text string
+	Page int
+}
+
+// extractText 根据文件扩展名提取文件中的纯文本
+// .pdf、.docx 解析为文本，.txt/.md 等其它文件按检测到的编码转换为 UTF-8 后读取
+func extractText(filePath string) (string, error) {
+	segments, err := extractSegments(filePath)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		texts = append(texts, seg.Text)
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// extractSegments 提取文件文本，并尽可能保留页码信息
+func extractSegments(filePath string) ([]textSegment, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".pdf":
+		pages, err := extractPDFPages(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pdf: %w", err)
+		}
+		segments := make([]textSegment, 0, len(pages))
+		for i, page := range pages {
+			segments = append(segments, textSegment{Text: page, Page: i + 1})
+		}
+		return segments, nil
+	case ".docx":
+		text, err := extractDocxText(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse docx: %w", err)
+		}
+		return []textSegment{{Text: text}}, nil
+	default:
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		// GBK、Latin-1 等编码的文件先转换为 UTF-8，避免把乱码写入知识库
+		text, err := decodeText(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(filePath), err)
+		}
+		return []textSegment{{Text: text}}, nil
+	}
+}
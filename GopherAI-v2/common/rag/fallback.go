@@ -0,0 +1,226 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/cloudwego/eino/components/embedding"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 不同向量模型生成的向量处于不同的向量空间，即使维度相同也不能互相比较：
+// 同一个知识库中的文档块和检索时的问题必须由同一个向量模型向量化。
+// 因此备用向量模型只在新建知识库、且主向量模型重试后仍连接不上时启用，并记录在索引上；
+// 之后这个知识库的写入和检索都固定使用记录的模型，不会在两个模型之间来回切换。
+
+// ErrEmbedderMismatch 知识库是用另一个向量模型建立的，当前配置中没有这个模型，可通过 errors.Is 判断
+var ErrEmbedderMismatch = errors.New("index was built with a different embedding model")
+
+// embedderChoice 一个可用的向量模型配置
+type embedderChoice struct {
+	id  string // provider:model，记录在索引上
+	cfg EmbedderConfig
+}
+
+func newEmbedderChoice(cfg EmbedderConfig) embedderChoice {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		provider = EmbeddingProviderArk
+	}
+	return embedderChoice{id: provider + ":" + cfg.Model, cfg: cfg}
+}
+
+// fallbackEmbedderConfig 返回配置的备用向量模型，未配置时 ok 为 false
+func fallbackEmbedderConfig() (cfg EmbedderConfig, ok bool) {
+	conf := config.GetConfig().RagModelConfig
+	if conf.RagFallbackEmbeddingModel == "" {
+		return EmbedderConfig{}, false
+	}
+	return EmbedderConfig{
+		Provider: conf.RagFallbackEmbeddingProvider,
+		BaseURL:  conf.RagFallbackEmbeddingBaseUrl,
+		APIKey:   conf.EmbeddingAPIKey(),
+		Model:    conf.RagFallbackEmbeddingModel,
+	}, true
+}
+
+// selectEmbedder 为知识库选择向量模型，返回加好重试、缓存和指标的向量生成器以及模型标识
+//   - 知识库已记录向量模型时只使用该模型，配置中没有该模型（且不是主模型所在服务的其它模型）时返回 ErrEmbedderMismatch
+//   - 没有记录时使用主向量模型；建立索引时主模型重试后仍不可用、且配置了备用模型，则改用备用模型
+//
+// 建立索引时会校验所选模型的维度，检索时只校验备用模型的维度
+// dimension 为知识库索引的维度，小于 ragModelConfig.dimension 时向量截断到这个维度（见 dimension.go）
+func selectEmbedder(ctx context.Context, indexName, model string, dimension int, operation string) (embedding.Embedder, string, error) {
+	primary := newEmbedderChoice(embedderConfigFromConfig(model))
+	choices := []embedderChoice{primary}
+	if cfg, ok := fallbackEmbedderConfig(); ok {
+		choices = append(choices, newEmbedderChoice(cfg))
+	}
+	if dimension < config.GetConfig().RagModelConfig.RagDimension {
+		for i := range choices {
+			choices[i].cfg.Dimensions = dimension
+		}
+		primary = choices[0]
+	}
+
+	recorded, err := indexEmbedder(ctx, indexName)
+	if err != nil {
+		return nil, "", err
+	}
+	if recorded != "" {
+		for i, choice := range choices {
+			if choice.id == recorded {
+				embedder, err := buildEmbedder(ctx, choice, indexName, operation)
+				if err != nil {
+					return nil, "", err
+				}
+				if i > 0 || operation == OperationIndex {
+					if err := validateDimension(ctx, embedder, choice.cfg.Model, dimension); err != nil {
+						return nil, "", err
+					}
+				}
+				return embedder, choice.id, nil
+			}
+		}
+		// 用 RebuildIndex 换过模型的知识库：同一家服务的其它模型沿用主模型的地址和密钥，
+		// 配置切换到新模型之前也可以继续写入和检索；维度以索引为准，不再与配置比较
+		if provider, recordedModel, ok := strings.Cut(recorded, ":"); ok && provider+":"+primary.cfg.Model == primary.id {
+			cfg := primary.cfg
+			cfg.Model = recordedModel
+			embedder, err := buildEmbedder(ctx, newEmbedderChoice(cfg), indexName, operation)
+			if err != nil {
+				return nil, "", err
+			}
+			return embedder, recorded, nil
+		}
+		return nil, "", fmt.Errorf("%s uses %s: %w", indexName, recorded, ErrEmbedderMismatch)
+	}
+
+	if operation == OperationIndex && primary.cfg.Dimensions > 0 {
+		if err := checkReducedDimension(primary.cfg.Model, primary.cfg.Dimensions); err != nil {
+			return nil, "", err
+		}
+	}
+	embedder, err := buildEmbedder(ctx, primary, indexName, operation)
+	if err != nil {
+		return nil, "", err
+	}
+	if operation != OperationIndex {
+		return embedder, primary.id, nil
+	}
+	err = validateDimension(ctx, embedder, primary.cfg.Model, dimension)
+	if err == nil {
+		return embedder, primary.id, nil
+	}
+	if len(choices) < 2 || !isUnavailableError(ctx, err) {
+		return nil, "", err
+	}
+
+	fallback := choices[1]
+	logger.Warn("primary embedding service unavailable, using fallback embedder for new index",
+		"index", indexName, "primary", primary.id, "fallback", fallback.id, "error", err)
+	if fallback.cfg.Dimensions > 0 {
+		if err := checkReducedDimension(fallback.cfg.Model, fallback.cfg.Dimensions); err != nil {
+			return nil, "", fmt.Errorf("fallback embedder: %w", err)
+		}
+	}
+	embedder, err = buildEmbedder(ctx, fallback, indexName, operation)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create fallback embedder: %w", err)
+	}
+	if err := validateDimension(ctx, embedder, fallback.cfg.Model, dimension); err != nil {
+		return nil, "", fmt.Errorf("fallback embedder: %w", err)
+	}
+	return embedder, fallback.id, nil
+}
+
+// buildEmbedder 创建向量生成器：临时错误自动重试，有 Redis 时加一层缓存，再记录链路和指标；
+// 最外层查找批量检索时预先计算好的问题向量
+func buildEmbedder(ctx context.Context, choice embedderChoice, indexName, operation string) (embedding.Embedder, error) {
+	base, err := NewEmbedder(ctx, choice.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedder: %w", err)
+	}
+	// 每一批单独重试，一批失败不需要重新请求已经成功的批
+	embedder := withDimensions(withInputLimit(withRetry(base)), choice.cfg.Dimensions)
+	if redisPkg.Rdb != nil {
+		// 缓存 key 中包含模型名，不同模型的向量不会混用；截断后的向量按模型名加维度单独缓存
+		cacheModel := choice.cfg.Model
+		if choice.cfg.Dimensions > 0 {
+			cacheModel = fmt.Sprintf("%s@%d", cacheModel, choice.cfg.Dimensions)
+		}
+		embedder = withEmbeddingCache(embedder, cacheModel)
+	}
+	return withPrecomputed(withInstrumentation(embedder, indexName, operation)), nil
+}
+
+// isUnavailableError 判断向量模型服务是否连接不上（连接被拒绝、DNS 失败、超时、网关错误）
+// 鉴权失败、参数错误等不属于服务不可用，换用备用模型也解决不了
+func isUnavailableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"connection refused", "no such host", "timeout", "502", "503", "504",
+		"bad gateway", "service unavailable", "gateway timeout"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// 使用内存存储时记录的索引向量模型（索引名 -> 模型标识）
+var memoryIndexEmbedders sync.Map
+
+// indexEmbedder 读取知识库建立时使用的向量模型，没有记录（新知识库或旧版本建立的知识库）时返回空字符串
+func indexEmbedder(ctx context.Context, indexName string) (string, error) {
+	if redisPkg.Rdb == nil {
+		if id, ok := memoryIndexEmbedders.Load(indexName); ok {
+			return id.(string), nil
+		}
+		return "", nil
+	}
+	id, err := redisPkg.Rdb.Get(ctx, redisPkg.GenerateIndexEmbedder(indexName)).Result()
+	if err == redisCli.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read index embedder: %w", err)
+	}
+	return id, nil
+}
+
+// recordIndexEmbedder 记录知识库使用的向量模型
+func recordIndexEmbedder(ctx context.Context, indexName, id string) error {
+	if redisPkg.Rdb == nil {
+		memoryIndexEmbedders.Store(indexName, id)
+		return nil
+	}
+	if err := redisPkg.Rdb.Set(ctx, redisPkg.GenerateIndexEmbedder(indexName), id, 0).Err(); err != nil {
+		return fmt.Errorf("failed to record index embedder: %w", err)
+	}
+	return nil
+}
+
+// forgetIndexEmbedder 删除知识库时一并删除向量模型记录
+func forgetIndexEmbedder(ctx context.Context, indexName string) error {
+	if redisPkg.Rdb == nil {
+		memoryIndexEmbedders.Delete(indexName)
+		return nil
+	}
+	return redisPkg.Rdb.Del(ctx, redisPkg.GenerateIndexEmbedder(indexName)).Err()
+}
@@ -0,0 +1,98 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 过滤字段别名：索引时文档来源（source）存在 Redis 的 metadata 字段中
+var filterFieldAlias = map[string]string{
+	"source": "metadata",
+}
+
+// buildFilter 根据索引结构将 字段 -> 值 的过滤条件转换为 RediSearch 过滤语句
+// 过滤的字段不在索引结构中时返回错误
+func (r *RAGQuery) buildFilter(ctx context.Context, filter map[string]string) (string, error) {
+	if len(filter) == 0 {
+		return "", nil
+	}
+	fieldTypes, err := redisPkg.GetIndexSchema(ctx, r.client, r.indexName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get index schema: %w", err)
+	}
+	return buildFilterQuery(fieldTypes, filter)
+}
+
+// buildFilterQuery 生成过滤语句，多个条件之间为“且”的关系
+// TAG 字段精确匹配，TEXT 字段按短语匹配（检索后会再做一次精确比较），NUMERIC 字段匹配单个数值
+func buildFilterQuery(fieldTypes map[string]string, filter map[string]string) (string, error) {
+	keys := make([]string, 0, len(filter))
+	for k := range filter {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	clauses := make([]string, 0, len(keys))
+	for _, key := range keys {
+		field := key
+		if alias, ok := filterFieldAlias[key]; ok {
+			field = alias
+		}
+		value := filter[key]
+
+		switch fieldTypes[field] {
+		case "TAG":
+			clauses = append(clauses, fmt.Sprintf("@%s:{%s}", field, escapeTagValue(value)))
+		case "TEXT":
+			clauses = append(clauses, fmt.Sprintf("@%s:\"%s\"", field, strings.ReplaceAll(value, "\"", "\\\"")))
+		case "NUMERIC":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid numeric filter value %q for field %s", value, key)
+			}
+			clauses = append(clauses, fmt.Sprintf("@%s:[%v %v]", field, n, n))
+		case "":
+			return "", fmt.Errorf("filter field %s is not part of the index schema", key)
+		default:
+			return "", fmt.Errorf("filter field %s of type %s is not supported", key, fieldTypes[field])
+		}
+	}
+	return strings.Join(clauses, " "), nil
+}
+
+// escapeTagValue 转义 TAG 值中的标点和空格
+func escapeTagValue(value string) string {
+	var sb strings.Builder
+	for _, c := range value {
+		if c == ' ' || strings.ContainsRune(",.<>{}[]\"':;!@#$%^&*()-+=~|/\\?", c) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// filterByMetadata 保留元数据与过滤条件完全一致的文档
+// TEXT 字段在 Redis 中是分词匹配，这里再做一次精确比较
+func filterByMetadata(docs []*schema.Document, filter map[string]string) []*schema.Document {
+	filtered := make([]*schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		matched := true
+		for k, v := range filter {
+			if val, ok := doc.MetaData[k]; ok && fmt.Sprint(val) != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			filtered = append(filtered, doc)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,107 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 健康检查默认超时时间
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// ErrUnhealthy 至少有一个依赖不可用
+var ErrUnhealthy = errors.New("rag dependencies unhealthy")
+
+// 依赖状态
+const (
+	HealthStatusOK    = "ok"
+	HealthStatusError = "error"
+)
+
+// DependencyStatus 单个依赖的检查结果
+type DependencyStatus struct {
+	Status    string `json:"status"`          // ok / error
+	LatencyMs int64  `json:"latency_ms"`      // 检查耗时（毫秒）
+	Error     string `json:"error,omitempty"` // 失败原因
+}
+
+// HealthStatus RAG 依赖的健康检查结果
+type HealthStatus struct {
+	Healthy   bool             `json:"healthy"`
+	Redis     DependencyStatus `json:"redis"`
+	Embedding DependencyStatus `json:"embedding"`
+}
+
+// HealthCheck 检查 Redis 和向量模型服务是否可用，两项检查并行执行
+// 超时时间由 ragModelConfig.healthCheckTimeout（毫秒）配置，默认 2 秒，避免探针卡住负载均衡
+// 任意依赖不可用时返回完整的检查结果和 ErrUnhealthy，调用方可据此返回 503
+func HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	timeout := defaultHealthCheckTimeout
+	if ms := config.GetConfig().RagModelConfig.RagHealthCheckTimeout; ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	status := &HealthStatus{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		status.Redis = checkDependency(func() error {
+			if redisPkg.Rdb == nil {
+				return fmt.Errorf("redis is not initialized")
+			}
+			return redisPkg.Rdb.Ping(ctx).Err()
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		status.Embedding = checkDependency(func() error {
+			return probeEmbedding(ctx)
+		})
+	}()
+	wg.Wait()
+
+	status.Healthy = status.Redis.Status == HealthStatusOK && status.Embedding.Status == HealthStatusOK
+	if !status.Healthy {
+		return status, ErrUnhealthy
+	}
+	return status, nil
+}
+
+// checkDependency 执行一项检查并记录耗时
+func checkDependency(check func() error) DependencyStatus {
+	start := time.Now()
+	err := check()
+	result := DependencyStatus{
+		Status:    HealthStatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = HealthStatusError
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// probeEmbedding 向量化一段很短的文本，确认向量模型服务可用
+// 直接调用向量模型，不经过缓存和重试
+func probeEmbedding(ctx context.Context) error {
+	embedder, err := NewEmbedder(ctx, embedderConfigFromConfig(config.GetConfig().RagModelConfig.RagEmbeddingModel))
+	if err != nil {
+		return fmt.Errorf("failed to create embedder: %w", err)
+	}
+	vectors, err := embedder.EmbedStrings(ctx, []string{"ping"})
+	if err != nil {
+		return err
+	}
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		return fmt.Errorf("embedder returned an empty vector")
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package rag
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Highlight 文档块中与问题匹配的一段文本，Start / End 为 Content 中的字符（Unicode 码点）偏移，
+// 区间左闭右开，前端可以直接按字符截取后高亮显示
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// 检索结果缓存用 gob 编码元数据，元数据中的 []Highlight 需要注册
+func init() {
+	gob.Register([]Highlight{})
+}
+
+// FT.SEARCH HIGHLIGHT 使用的标记，规范化后的文档块中不会出现控制字符，不会与正文冲突
+const (
+	highlightOpen  = "\x02"
+	highlightClose = "\x03"
+)
+
+// highlightArgs 关键词检索时让 Redis 用标记包裹 content 中命中的词
+func highlightArgs() []interface{} {
+	return []interface{}{"HIGHLIGHT", "FIELDS", 1, "content", "TAGS", highlightOpen, highlightClose}
+}
+
+// extractHighlights 去掉 Redis 插入的高亮标记，返回原文和命中的区间
+func extractHighlights(marked string) (string, []Highlight) {
+	if !strings.Contains(marked, highlightOpen) {
+		return marked, nil
+	}
+	var (
+		sb         strings.Builder
+		highlights []Highlight
+		pos        int
+		start      = -1
+	)
+	for _, r := range marked {
+		switch string(r) {
+		case highlightOpen:
+			start = pos
+		case highlightClose:
+			if start >= 0 && pos > start {
+				highlights = append(highlights, Highlight{Start: start, End: pos})
+			}
+			start = -1
+		default:
+			sb.WriteRune(r)
+			pos++
+		}
+	}
+	return sb.String(), highlights
+}
+
+// 句子结束的标点
+const sentenceEnds = "。！？；.!?;\n"
+
+// splitSentences 按句末标点把文本切分为句子，返回每个句子的区间（字符偏移），首尾空白不计入句子
+func splitSentences(text string) []Highlight {
+	runes := []rune(text)
+	var spans []Highlight
+	flush := func(s, e int) {
+		for s < e && unicode.IsSpace(runes[s]) {
+			s++
+		}
+		for e > s && unicode.IsSpace(runes[e-1]) {
+			e--
+		}
+		if e > s {
+			spans = append(spans, Highlight{Start: s, End: e})
+		}
+	}
+	start := 0
+	for i, r := range runes {
+		if strings.ContainsRune(sentenceEnds, r) {
+			flush(start, i+1)
+			start = i + 1
+		}
+	}
+	flush(start, len(runes))
+	return spans
+}
+
+// highlightSentences 为没有关键词高亮的文档找出与问题语义最接近的句子
+// 把问题和所有文档的句子一起向量化（一次调用），每个文档取余弦相似度最高的一句；只有一句话的文档不需要高亮
+func (r *RAGQuery) highlightSentences(ctx context.Context, query string, docs []*schema.Document) error {
+	type candidate struct {
+		doc  *schema.Document
+		span Highlight
+	}
+	texts := []string{query}
+	var candidates []candidate
+	for _, doc := range docs {
+		if _, ok := doc.MetaData["highlights"]; ok {
+			continue
+		}
+		spans := splitSentences(doc.Content)
+		if len(spans) < 2 {
+			continue
+		}
+		runes := []rune(doc.Content)
+		for _, span := range spans {
+			texts = append(texts, string(runes[span.Start:span.End]))
+			candidates = append(candidates, candidate{doc: doc, span: span})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	vectors, err := r.embedding.EmbedStrings(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed sentences for highlight: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+
+	best := make(map[*schema.Document]float64)
+	for i, c := range candidates {
+		sim := cosineSimilarity(vectors[0], vectors[i+1])
+		if prev, ok := best[c.doc]; ok && sim <= prev {
+			continue
+		}
+		best[c.doc] = sim
+		c.doc.MetaData["highlights"] = []Highlight{c.span}
+	}
+	return nil
+}
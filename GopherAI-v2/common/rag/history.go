@@ -0,0 +1,182 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// 默认保留的最近对话轮数
+	defaultHistoryTurns = 10
+	// 默认对话历史的 token 预算（粗略估算，见 estimateTokens）
+	defaultHistoryTokens = 2000
+	// 会话在最后一次提问后保留的时间
+	defaultHistoryTTL = 24 * time.Hour
+)
+
+// Turn 一轮对话：用户的问题和模型的回答
+type Turn struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// HistoryOptions 多轮对话配置，零值表示使用默认配置
+type HistoryOptions struct {
+	MaxTurns          int           // 最多保留的对话轮数，默认 10
+	MaxTokensEstimate int           // 放入提示词的对话历史最多占用的 token 数（estimateTokens 粗略估算），默认 2000
+	TTL               time.Duration // 会话过期时间，默认 24 小时
+}
+
+func (o HistoryOptions) withDefaults() HistoryOptions {
+	if o.MaxTurns <= 0 {
+		o.MaxTurns = defaultHistoryTurns
+	}
+	if o.MaxTokensEstimate <= 0 {
+		o.MaxTokensEstimate = defaultHistoryTokens
+	}
+	if o.TTL <= 0 {
+		o.TTL = defaultHistoryTTL
+	}
+	return o
+}
+
+// rewritePromptText 把追问改写为独立问题的提示词
+const rewritePromptText = `根据以下对话历史，把用户的最新问题改写成一个不依赖上下文、可以单独理解的完整问题。
+只输出改写后的问题，不要回答问题，也不要添加任何解释。如果最新问题本身已经完整，原样输出。
+
+对话历史：
+%s
+最新问题：%s`
+
+// AnswerWithHistory 多轮对话问答
+// 先结合会话历史把当前问题改写为独立问题（如“第二个呢？”），用改写后的问题检索和回答，
+// 再把本轮对话追加到会话历史中（保存在 Redis，按 sessionID 区分）
+// 返回完整回答以及用到的参考文档；对话模型不可用且配置为降级时不改写问题，
+// 返回空回答、参考文档和 *GenerationUnavailableError，本轮不写入会话历史
+func (r *RAGQuery) AnswerWithHistory(ctx context.Context, sessionID, query string) (string, []*schema.Document, error) {
+	if sessionID == "" {
+		return "", nil, fmt.Errorf("session id is required")
+	}
+	opts := r.history.withDefaults()
+
+	history, err := loadHistory(ctx, sessionID, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	llm, llmErr := newChatModel(ctx, r.chat)
+	if llmErr != nil && !degradeOnModelFailure(ctx) {
+		return "", nil, llmErr
+	}
+
+	standalone := query
+	if llmErr == nil {
+		standalone = rewriteQuery(ctx, llm, history, query)
+	}
+	docs, err := r.RetrieveDocuments(ctx, standalone, RetrieveOptions{})
+	if err != nil {
+		return "", nil, err
+	}
+
+	messages := make([]*schema.Message, 0, 2*len(history)+1)
+	for _, turn := range history {
+		messages = append(messages, schema.UserMessage(turn.User), schema.AssistantMessage(turn.Assistant, nil))
+	}
+	prompt, docs := r.buildPrompt(standalone, docs)
+	messages = append(messages, schema.UserMessage(prompt))
+
+	if llmErr != nil {
+		docs, err = generationFailed(ctx, llmErr, docs)
+		return "", docs, err
+	}
+	resp, err := llm.Generate(ctx, messages)
+	if err != nil {
+		docs, err = generationFailed(ctx, fmt.Errorf("failed to generate answer: %w", err), docs)
+		return "", docs, err
+	}
+
+	// 保存失败不影响本次回答
+	if err := saveTurn(ctx, sessionID, Turn{User: query, Assistant: resp.Content}, opts); err != nil {
+		logger.Warn("failed to save rag history", "session_id", sessionID, "error", err)
+	}
+	return resp.Content, docs, nil
+}
+
+// rewriteQuery 结合对话历史把问题改写为独立问题，没有历史或改写失败时返回原问题
+func rewriteQuery(ctx context.Context, llm model.ToolCallingChatModel, history []Turn, query string) string {
+	if len(history) == 0 {
+		return query
+	}
+	var sb strings.Builder
+	for _, turn := range history {
+		sb.WriteString("用户：" + turn.User + "\n")
+		sb.WriteString("助手：" + turn.Assistant + "\n")
+	}
+	resp, err := llm.Generate(ctx, []*schema.Message{
+		schema.UserMessage(fmt.Sprintf(rewritePromptText, sb.String(), query)),
+	})
+	if err != nil {
+		logger.Warn("failed to rewrite query, using the original one", "error", err)
+		return query
+	}
+	rewritten := strings.TrimSpace(resp.Content)
+	if rewritten == "" {
+		return query
+	}
+	return rewritten
+}
+
+// loadHistory 读取会话历史，只返回 token 预算内最近的若干轮
+func loadHistory(ctx context.Context, sessionID string, opts HistoryOptions) ([]Turn, error) {
+	items, err := redisPkg.Rdb.LRange(ctx, redisPkg.GenerateRAGHistory(sessionID), int64(-opts.MaxTurns), -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rag history: %w", err)
+	}
+	turns := make([]Turn, 0, len(items))
+	for _, item := range items {
+		var turn Turn
+		if err := json.Unmarshal([]byte(item), &turn); err != nil {
+			continue
+		}
+		turns = append(turns, turn)
+	}
+	return trimHistory(turns, opts.MaxTokensEstimate), nil
+}
+
+// saveTurn 追加一轮对话，只保留最近 MaxTurns 轮，并刷新会话过期时间
+func saveTurn(ctx context.Context, sessionID string, turn Turn, opts HistoryOptions) error {
+	data, err := json.Marshal(turn)
+	if err != nil {
+		return err
+	}
+	key := redisPkg.GenerateRAGHistory(sessionID)
+	pipe := redisPkg.Rdb.TxPipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.LTrim(ctx, key, int64(-opts.MaxTurns), -1)
+	pipe.Expire(ctx, key, opts.TTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// trimHistory 从最近一轮往前保留，直到超出 token 预算
+func trimHistory(turns []Turn, maxTokens int) []Turn {
+	used := 0
+	start := len(turns)
+	for start > 0 {
+		t := turns[start-1]
+		cost := estimateTokens(t.User) + estimateTokens(t.Assistant)
+		if used+cost > maxTokens {
+			break
+		}
+		used += cost
+		start--
+	}
+	return turns[start:]
+}
@@ -0,0 +1,84 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// 可以被索引的文件扩展名（与上传时允许的文件类型一致）
+var indexableExtensions = map[string]bool{
+	".md": true, ".txt": true, ".pdf": true, ".docx": true, ".csv": true, ".tsv": true,
+}
+
+// FileIndexResult 批量索引中单个文件的结果
+type FileIndexResult struct {
+	Path   string
+	Chunks int   // 存储的文档块数
+	Err    error // nil 表示索引成功
+}
+
+// IndexFiles 把多个文件索引到同一个知识库中，每个文档块的 source 元数据为各自的文件路径
+// 单个文件失败不影响其它文件，返回每个文件的结果和合并后的错误（全部成功时为 nil）；
+// 每个文件开始前检查 ctx，取消后不再处理剩余文件，返回的结果只包含已处理的文件
+// opts 对每个文件生效，Progress 按单个文件回调，FileProgress 额外带上当前文件的路径
+func (r *RAGIndexer) IndexFiles(ctx context.Context, paths []string, opts IndexOptions) ([]FileIndexResult, error) {
+	results := make([]FileIndexResult, 0, len(paths))
+	var errs []error
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		chunks, err := r.IndexFile(ctx, path, withFileProgress(opts, path))
+		results = append(results, FileIndexResult{Path: path, Chunks: chunks, Err: err})
+		if err != nil {
+			logger.Warn("index file failed", "key_prefix", r.keyPrefix, "file", filepath.Base(path), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// withFileProgress 把 opts.FileProgress 合并到单个文件的 Progress 中
+func withFileProgress(opts IndexOptions, path string) IndexOptions {
+	if opts.FileProgress == nil {
+		return opts
+	}
+	progress, fileProgress := opts.Progress, opts.FileProgress
+	opts.Progress = func(done, total int) {
+		if progress != nil {
+			progress(done, total)
+		}
+		fileProgress(path, done, total)
+	}
+	return opts
+}
+
+// IndexDir 递归索引目录下所有支持的文件（.md、.txt、.pdf、.docx、.csv、.tsv），
+// 其它类型的文件和以 . 开头的隐藏文件 / 目录会被跳过；结果与 IndexFiles 相同
+func (r *RAGIndexer) IndexDir(ctx context.Context, dir string, opts IndexOptions) ([]FileIndexResult, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && indexableExtensions[strings.ToLower(filepath.Ext(path))] {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return r.IndexFiles(ctx, paths, opts)
+}
@@ -0,0 +1,333 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// JSONIndexOptions 按记录索引 JSON 文件的配置
+// 路径用 "." 分隔逐层取值，例如 "product.name"；数组可以用下标，例如 "tags.0"
+type JSONIndexOptions struct {
+	// RecordsPath 记录数组所在的路径，为空时文件顶层为数组则每个元素是一条记录，顶层为对象则整个对象是一条记录
+	RecordsPath string
+	// ContentPaths 参与向量化的路径，每个路径输出一行 "路径: 值"；为空表示除 MetadataPaths 以外的所有叶子字段
+	ContentPaths []string
+	// MetadataPaths 作为元数据保存的路径，不参与向量化；字段名为路径中的 "." 换成 "_"（例如 product_category），
+	// 这些字段会加入索引结构（TAG），检索时可以通过 RetrieveOptions.Filter 按字段精确过滤
+	MetadataPaths []string
+	// Progress 每批文档块存储完成后回调一次
+	Progress ProgressFunc
+	// MaxConcurrency 最多同时向量化的批次数，0 表示使用默认值 4
+	MaxConcurrency int
+}
+
+// ErrInvalidJSON JSON 文件格式错误，或者结构与 JSONIndexOptions 不符，可通过 errors.Is 判断
+var ErrInvalidJSON = errors.New("invalid json document")
+
+// 元数据字段名只能包含字母、数字和下划线（RediSearch 查询语法中其它字符需要转义）
+var jsonFieldNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 索引时已经使用的字段，JSON 元数据不能与之同名
+var reservedFields = []string{
+	"content", "vector", "metadata", "source", "chunk_index", "content_hash",
+	"page", "heading", "lang", "title", "record", "distance", "keyword_score", "locations",
+}
+
+// IndexJSON 把 JSON 文件中的每一条记录作为一个独立文档（不再切块）存入知识库，返回实际存储的文档块数
+// 元数据中 record 为记录的序号（从 0 开始）；内容为空的记录会被跳过
+// JSON 格式错误、记录不是对象、路径在所有记录中都不存在时返回 ErrInvalidJSON（错误信息中带有位置或路径）；
+// 没有任何记录有内容时返回 ErrEmptyDocument
+func (r *RAGIndexer) IndexJSON(ctx context.Context, filePath string, opts JSONIndexOptions) (stored int, err error) {
+	ctx, span := startSpan(ctx, "rag.IndexJSON", attribute.String("rag.file", filepath.Base(filePath)))
+	defer func() { endSpan(span, err) }()
+
+	if opts.MaxConcurrency == 0 {
+		opts.MaxConcurrency = defaultMaxConcurrency
+	}
+	if opts.MaxConcurrency < 1 {
+		return 0, fmt.Errorf("%w: max concurrency %d must be >= 1", ErrInvalidOptions, opts.MaxConcurrency)
+	}
+	fields, err := jsonMetadataFields(opts.MetadataPaths)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	docs, err := buildJSONDocuments(filePath, data, opts, fields)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", filepath.Base(filePath), err)
+	}
+	docs = linkChunks(tagTitles(tagLanguages(normalizeDocuments(docs, NormalizeOptions{})), fallbackTitle(filePath)))
+	span.SetAttributes(attribute.Int("rag.chunks", len(docs)))
+	if len(docs) == 0 {
+		return 0, fmt.Errorf("%s: %w", filepath.Base(filePath), ErrEmptyDocument)
+	}
+
+	quota, err := r.reserveQuota(ctx, filePath, int64(len(data)), int64(len(docs)))
+	if err != nil {
+		return 0, err
+	}
+	// 元数据字段加入索引结构后才能过滤；内存存储直接比较元数据，不需要
+	if r.client != nil && len(fields) > 0 {
+		if err := redisPkg.AddIndexTagFields(ctx, r.client, r.indexName, fields); err != nil {
+			quota.release(context.WithoutCancel(ctx))
+			return 0, err
+		}
+	}
+
+	stored, err = r.storeBatches(ctx, docs, opts.MaxConcurrency, opts.Progress)
+	quota.commit(context.WithoutCancel(ctx), int64(len(data)), int64(stored))
+	return stored, err
+}
+
+// jsonMetadataFields 校验元数据路径并返回对应的字段名
+func jsonMetadataFields(paths []string) ([]string, error) {
+	fields := make([]string, len(paths))
+	for i, path := range paths {
+		field := jsonFieldName(path)
+		if !jsonFieldNameRe.MatchString(field) {
+			return nil, fmt.Errorf("%w: metadata path %q must contain only letters, digits, underscores and dots", ErrInvalidOptions, path)
+		}
+		if slices.Contains(reservedFields, field) {
+			return nil, fmt.Errorf("%w: metadata field %q is reserved", ErrInvalidOptions, field)
+		}
+		if slices.Contains(fields[:i], field) {
+			return nil, fmt.Errorf("%w: duplicate metadata field %q", ErrInvalidOptions, field)
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+// jsonFieldName 元数据路径对应的字段名
+func jsonFieldName(path string) string {
+	return strings.ReplaceAll(path, ".", "_")
+}
+
+// buildJSONDocuments 解析 JSON 并把每一条记录转换为文档，fields 为 opts.MetadataPaths 对应的字段名
+func buildJSONDocuments(filePath string, data []byte, opts JSONIndexOptions, fields []string) ([]*schema.Document, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root any
+	if err := dec.Decode(&root); err != nil {
+		return nil, jsonSyntaxError(data, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: unexpected data after the top-level value", ErrInvalidJSON)
+	}
+
+	records, err := jsonRecords(root, opts.RecordsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 路径在所有记录中都不存在，多半是写错了
+	found := make(map[string]bool)
+	var docs []*schema.Document
+	for recIdx, rec := range records {
+		obj, ok := rec.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: record %d is a %s, not an object", ErrInvalidJSON, recIdx, jsonTypeName(rec))
+		}
+
+		contentPaths := opts.ContentPaths
+		if len(contentPaths) == 0 {
+			for _, path := range jsonLeafPaths(obj, "") {
+				if !slices.Contains(opts.MetadataPaths, path) {
+					contentPaths = append(contentPaths, path)
+				}
+			}
+		}
+		lines := make([]string, 0, len(contentPaths))
+		for _, path := range contentPaths {
+			v, ok := lookupJSONPath(obj, path)
+			if !ok {
+				continue
+			}
+			found[path] = true
+			if s := formatJSONValue(v); s != "" {
+				lines = append(lines, path+": "+s)
+			}
+		}
+		content := strings.Join(lines, "\n")
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		i := len(docs)
+		metadata := map[string]any{
+			"source":       filePath,
+			"chunk_index":  i,
+			"record":       recIdx,
+			"content_hash": contentHash(content),
+		}
+		for j, path := range opts.MetadataPaths {
+			if v, ok := lookupJSONPath(obj, path); ok {
+				found[path] = true
+				metadata[fields[j]] = formatJSONValue(v)
+			}
+		}
+		docs = append(docs, &schema.Document{
+			Content:  content,
+			MetaData: metadata,
+		})
+	}
+
+	if len(records) > 0 {
+		for _, path := range slices.Concat(opts.ContentPaths, opts.MetadataPaths) {
+			if !found[path] {
+				return nil, fmt.Errorf("%w: path %q not found in any record", ErrInvalidJSON, path)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// jsonRecords 取出记录列表：recordsPath 指向的值（或顶层值）为数组时每个元素是一条记录，为对象时是一条记录
+func jsonRecords(root any, recordsPath string) ([]any, error) {
+	value := root
+	if recordsPath != "" {
+		obj, ok := root.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: records path %q requires a top-level object, got %s", ErrInvalidJSON, recordsPath, jsonTypeName(root))
+		}
+		if value, ok = lookupJSONPath(obj, recordsPath); !ok {
+			return nil, fmt.Errorf("%w: records path %q not found", ErrInvalidJSON, recordsPath)
+		}
+	}
+	switch v := value.(type) {
+	case []any:
+		return v, nil
+	case map[string]any:
+		return []any{v}, nil
+	default:
+		return nil, fmt.Errorf("%w: expected an array or object of records, got %s", ErrInvalidJSON, jsonTypeName(value))
+	}
+}
+
+// lookupJSONPath 按 "." 分隔的路径逐层取值，遇到数组时路径中的这一段必须是下标
+func lookupJSONPath(obj map[string]any, path string) (any, bool) {
+	var cur any = obj
+	for _, part := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// jsonLeafPaths 按字段名排序列出对象中所有非对象字段的路径（数组整体作为一个字段）
+func jsonLeafPaths(obj map[string]any, prefix string) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var paths []string
+	for _, k := range keys {
+		path := prefix + k
+		if child, ok := obj[k].(map[string]any); ok {
+			paths = append(paths, jsonLeafPaths(child, path+".")...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// formatJSONValue 把 JSON 值转换为文本：标量直接输出，数组用 ", " 连接各元素，对象输出紧凑的 JSON，null 为空字符串
+func formatJSONValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(val)
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	case []any:
+		parts := make([]string, 0, len(val))
+		for _, item := range val {
+			if s := formatJSONValue(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	}
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonSyntaxError 把解析错误转换为带行号和列号的 ErrInvalidJSON
+func jsonSyntaxError(data []byte, err error) error {
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - (bytes.LastIndexByte(before, '\n') + 1)
+	return fmt.Errorf("%w: line %d, column %d: %v", ErrInvalidJSON, line, col, err)
+}
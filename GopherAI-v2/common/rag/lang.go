@@ -0,0 +1,97 @@
+package rag
+
+import (
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 文档块和问题的语言，索引时写入元数据 lang，检索时可以按语言过滤（RetrieveOptions.Language）
+const (
+	LanguageAuto     = "auto" // 文本太短或混合多种语言，无法可靠判断
+	LanguageChinese  = "zh"
+	LanguageEnglish  = "en"
+	LanguageJapanese = "ja"
+	LanguageKorean   = "ko"
+	LanguageRussian  = "ru"
+)
+
+const (
+	// 少于该数量的字 / 词时不判断语言
+	minLanguageUnits = 2
+	// 占比最高的文字达到该比例才认为是主要语言
+	dominantScriptRatio = 0.6
+	// 拉丁字母中带变音符号的字母超过该比例时不视为英文（可能是法语、德语等）
+	maxEnglishAccentRatio = 0.02
+)
+
+// DetectLanguage 按文字（书写系统）判断文本的主要语言，无法可靠判断时返回 LanguageAuto
+// 汉字、假名、谚文按字计数，拉丁字母和西里尔字母按词计数，避免英文单词的字母数压过中文：
+//   - 汉字和假名占多数时，假名占其中五分之一以上为日文，否则为中文
+//   - 拉丁字母占多数且几乎没有变音符号时为英文
+func DetectLanguage(text string) string {
+	var (
+		han, kana, hangul      int
+		latinWords, cyrillic   int
+		latinLetters, accented int
+		inLatin, inCyrillic    bool
+	)
+	for _, r := range text {
+		isLatin := unicode.Is(unicode.Latin, r)
+		isCyrillic := unicode.Is(unicode.Cyrillic, r)
+		if isLatin && !inLatin {
+			latinWords++
+		}
+		if isCyrillic && !inCyrillic {
+			cyrillic++
+		}
+		inLatin, inCyrillic = isLatin, isCyrillic
+		switch {
+		case isLatin:
+			latinLetters++
+			if r > unicode.MaxASCII {
+				accented++
+			}
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		}
+	}
+
+	cjk := han + kana
+	total := cjk + hangul + latinWords + cyrillic
+	if total < minLanguageUnits {
+		return LanguageAuto
+	}
+	dominant := max(cjk, hangul, latinWords, cyrillic)
+	if float64(dominant) < dominantScriptRatio*float64(total) {
+		return LanguageAuto
+	}
+	switch dominant {
+	case cjk:
+		if kana*5 >= cjk {
+			return LanguageJapanese
+		}
+		return LanguageChinese
+	case hangul:
+		return LanguageKorean
+	case cyrillic:
+		return LanguageRussian
+	default:
+		if float64(accented) > maxEnglishAccentRatio*float64(latinLetters) {
+			return LanguageAuto
+		}
+		return LanguageEnglish
+	}
+}
+
+// tagLanguages 在每个文档块的元数据中记录其语言（lang）
+func tagLanguages(docs []*schema.Document) []*schema.Document {
+	for _, doc := range docs {
+		doc.MetaData["lang"] = DetectLanguage(doc.Content)
+	}
+	return docs
+}
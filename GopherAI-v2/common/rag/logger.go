@@ -0,0 +1,18 @@
+package rag
+
+import (
+	"io"
+	"log/slog"
+)
+
+// logger rag 包使用的日志记录器，默认使用 slog.Default()
+var logger = slog.Default()
+
+// SetLogger 替换 rag 包使用的日志记录器，传入 nil 时不输出任何日志（便于测试）
+// 应在初始化阶段调用，不要与索引、检索并发调用
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	logger = l
+}
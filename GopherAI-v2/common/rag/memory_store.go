@@ -0,0 +1,205 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+)
+
+// MemoryVectorStore 进程内存中的向量存储，检索时与所有文档块逐一计算余弦相似度
+// 不依赖 Redis，适合单元测试、本地开发和文档量很小的部署；进程重启后数据丢失
+type MemoryVectorStore struct {
+	embedder embedding.Embedder
+	data     *memoryIndex
+}
+
+// memoryIndex 一个知识库的数据，NewRAGIndexer 和 NewRAGQuery 通过索引名共享同一份数据
+type memoryIndex struct {
+	mu        sync.RWMutex
+	keyPrefix string
+	docs      map[string]*memoryEntry // 文档块 ID -> 文档块
+}
+
+type memoryEntry struct {
+	doc    *schema.Document
+	vector []float64
+}
+
+// NewMemoryVectorStore 创建一个空的内存向量存储，embedder 用于向量化文档块和问题
+func NewMemoryVectorStore(embedder embedding.Embedder) *MemoryVectorStore {
+	return &MemoryVectorStore{embedder: embedder, data: newMemoryIndex("")}
+}
+
+func newMemoryIndex(keyPrefix string) *memoryIndex {
+	return &memoryIndex{keyPrefix: keyPrefix, docs: map[string]*memoryEntry{}}
+}
+
+// 配置使用内存存储时，进程内所有知识库的数据（索引名 -> 数据）
+var (
+	memoryIndexesMu sync.Mutex
+	memoryIndexes   = map[string]*memoryIndex{}
+)
+
+// memoryStoreFor 返回索引名对应的内存存储，不存在时创建
+func memoryStoreFor(indexName, keyPrefix string, embedder embedding.Embedder) *MemoryVectorStore {
+	memoryIndexesMu.Lock()
+	defer memoryIndexesMu.Unlock()
+	data, ok := memoryIndexes[indexName]
+	if !ok {
+		data = newMemoryIndex(keyPrefix)
+		memoryIndexes[indexName] = data
+	}
+	return &MemoryVectorStore{embedder: embedder, data: data}
+}
+
+// lookupMemoryStore 返回已存在的内存存储
+func lookupMemoryStore(indexName string, embedder embedding.Embedder) (*MemoryVectorStore, bool) {
+	memoryIndexesMu.Lock()
+	defer memoryIndexesMu.Unlock()
+	data, ok := memoryIndexes[indexName]
+	if !ok {
+		return nil, false
+	}
+	return &MemoryVectorStore{embedder: embedder, data: data}, true
+}
+
+// dropMemoryStore 删除索引名对应的内存存储
+func dropMemoryStore(indexName string) {
+	memoryIndexesMu.Lock()
+	delete(memoryIndexes, indexName)
+	memoryIndexesMu.Unlock()
+}
+
+func (s *MemoryVectorStore) Store(ctx context.Context, docs []*schema.Document) ([]string, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.Content
+	}
+	vectors, err := s.embedder.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed documents: %w", err)
+	}
+	if len(vectors) != len(docs) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d documents", len(vectors), len(docs))
+	}
+
+	ids := make([]string, len(docs))
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	for i, doc := range docs {
+		stored := &schema.Document{
+			ID:       s.data.keyPrefix + doc.ID,
+			Content:  doc.Content,
+			MetaData: maps.Clone(doc.MetaData),
+		}
+		s.data.docs[doc.ID] = &memoryEntry{doc: stored, vector: vectors[i]}
+		ids[i] = doc.ID
+	}
+	return ids, nil
+}
+
+func (s *MemoryVectorStore) Retrieve(ctx context.Context, query string, topK int, filter map[string]string) ([]*schema.Document, error) {
+	vectors, err := s.embedder.EmbedStrings(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for 1 query", len(vectors))
+	}
+
+	type scored struct {
+		doc      *schema.Document
+		distance float64
+	}
+	s.data.mu.RLock()
+	results := make([]scored, 0, len(s.data.docs))
+	for _, entry := range s.data.docs {
+		if !matchesFilter(entry.doc, filter) {
+			continue
+		}
+		results = append(results, scored{doc: entry.doc, distance: 1 - cosineSimilarity(vectors[0], entry.vector)})
+	}
+	s.data.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].distance != results[j].distance {
+			return results[i].distance < results[j].distance
+		}
+		return results[i].doc.ID < results[j].doc.ID
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+
+	// 返回副本，调用方修改元数据不影响存储的数据
+	docs := make([]*schema.Document, len(results))
+	for i, r := range results {
+		metadata := maps.Clone(r.doc.MetaData)
+		if metadata == nil {
+			metadata = map[string]any{}
+		}
+		metadata["distance"] = r.distance
+		metadata["score"] = similarityScore(redisPkg.DistanceCosine, r.distance)
+		docs[i] = &schema.Document{ID: r.doc.ID, Content: r.doc.Content, MetaData: metadata}
+	}
+	return docs, nil
+}
+
+func (s *MemoryVectorStore) Get(_ context.Context, ids ...string) ([]*schema.Document, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	docs := make([]*schema.Document, 0, len(ids))
+	for _, id := range ids {
+		if entry, ok := s.data.docs[id]; ok {
+			docs = append(docs, &schema.Document{ID: entry.doc.ID, Content: entry.doc.Content, MetaData: maps.Clone(entry.doc.MetaData)})
+		}
+	}
+	return docs, nil
+}
+
+func (s *MemoryVectorStore) Delete(_ context.Context, ids ...string) (int, error) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	n := 0
+	for _, id := range ids {
+		if _, ok := s.data.docs[id]; ok {
+			delete(s.data.docs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// deleteBySource 删除来源为 source 的所有文档块，返回删除的数量
+func (s *MemoryVectorStore) deleteBySource(source string) int {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	n := 0
+	for id, entry := range s.data.docs {
+		if src, _ := entry.doc.MetaData["source"].(string); src == source {
+			delete(s.data.docs, id)
+			n++
+		}
+	}
+	return n
+}
+
+// matchesFilter 判断文档块的元数据是否与所有过滤条件完全匹配（字段不存在视为不匹配）
+func matchesFilter(doc *schema.Document, filter map[string]string) bool {
+	for k, v := range filter {
+		val, ok := doc.MetaData[k]
+		if !ok || fmt.Sprint(val) != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package rag
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 指标中 operation 标签的取值
+const (
+	OperationIndex = "index" // 建立索引（向量化文档块、写入 Redis）
+	OperationQuery = "query" // 检索（向量化问题、查询 Redis）
+)
+
+// 耗时直方图的分桶（秒）
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// 指标的标签：知识库索引名 + 操作
+var metricLabelNames = []string{"index", "operation"}
+
+// metricLabels 指标标签：知识库索引名 + 操作
+type metricLabels struct {
+	index     string
+	operation string
+}
+
+func (l metricLabels) values() []string {
+	return []string{l.index, l.operation}
+}
+
+// ragMetrics RAG 相关指标，进程内所有索引器 / 查询器共享
+type ragMetrics struct {
+	registerer        prometheus.Registerer
+	collectors        []prometheus.Collector
+	embeddingRequests *prometheus.CounterVec
+	embeddingErrors   *prometheus.CounterVec
+	embeddingDuration *prometheus.HistogramVec
+	retrievalErrors   *prometheus.CounterVec
+	retrievalDuration *prometheus.HistogramVec
+	documentsIndexed  *prometheus.CounterVec
+}
+
+// newRAGMetrics 创建 RAG 指标并注册到 reg，reg 为 nil 时只创建不注册
+func newRAGMetrics(reg prometheus.Registerer) *ragMetrics {
+	f := promauto.With(reg)
+	m := &ragMetrics{
+		registerer: reg,
+		embeddingRequests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "rag_embedding_requests_total",
+			Help: "Number of embedding calls.",
+		}, metricLabelNames),
+		embeddingErrors: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "rag_embedding_errors_total",
+			Help: "Number of failed embedding calls.",
+		}, metricLabelNames),
+		embeddingDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rag_embedding_duration_seconds",
+			Help:    "Latency of embedding calls in seconds.",
+			Buckets: latencyBuckets,
+		}, metricLabelNames),
+		retrievalErrors: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "rag_retrieval_errors_total",
+			Help: "Number of failed retrievals.",
+		}, metricLabelNames),
+		retrievalDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rag_retrieval_duration_seconds",
+			Help:    "Latency of document retrieval in seconds.",
+			Buckets: latencyBuckets,
+		}, metricLabelNames),
+		documentsIndexed: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "rag_documents_indexed_total",
+			Help: "Number of document chunks stored in the index.",
+		}, metricLabelNames),
+	}
+	// 缓存在进程内共享，不区分知识库，采集时读取
+	cacheHits := f.NewCounterFunc(prometheus.CounterOpts{
+		Name: "rag_embedding_cache_hits_total",
+		Help: "Number of embedding cache hits.",
+	}, func() float64 { return float64(EmbeddingCacheStats().Hits) })
+	cacheMisses := f.NewCounterFunc(prometheus.CounterOpts{
+		Name: "rag_embedding_cache_misses_total",
+		Help: "Number of embedding cache misses.",
+	}, func() float64 { return float64(EmbeddingCacheStats().Misses) })
+	m.collectors = []prometheus.Collector{
+		m.embeddingRequests, m.embeddingErrors, m.embeddingDuration,
+		m.retrievalErrors, m.retrievalDuration, m.documentsIndexed,
+		cacheHits, cacheMisses,
+	}
+	return m
+}
+
+// unregister 从注册时使用的 Registerer 中注销全部指标
+func (m *ragMetrics) unregister() {
+	if m.registerer == nil {
+		return
+	}
+	for _, c := range m.collectors {
+		m.registerer.Unregister(c)
+	}
+}
+
+var (
+	metricsMu sync.RWMutex
+	// metrics 默认注册到 prometheus.DefaultRegisterer，用 promhttp.Handler() 即可采集
+	metrics = newRAGMetrics(prometheus.DefaultRegisterer)
+)
+
+// SetMetricsRegisterer 把 RAG 指标改为注册到 reg（例如单独的 prometheus.NewRegistry()），
+// 原来的 Registerer 中的指标会被注销，已经累计的数值不会带到新的 Registerer；reg 为 nil 时不注册指标
+// 应在初始化阶段调用
+func SetMetricsRegisterer(reg prometheus.Registerer) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics.unregister()
+	metrics = newRAGMetrics(reg)
+}
+
+// currentMetrics 返回当前使用的指标
+func currentMetrics() *ragMetrics {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+// observeEmbedding 记录一次向量化调用
+func observeEmbedding(labels metricLabels, start time.Time, err error) {
+	m := currentMetrics()
+	m.embeddingRequests.WithLabelValues(labels.values()...).Inc()
+	m.embeddingDuration.WithLabelValues(labels.values()...).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.embeddingErrors.WithLabelValues(labels.values()...).Inc()
+	}
+}
+
+// observeRetrieval 记录一次检索
+func observeRetrieval(index string, start time.Time, err error) {
+	m := currentMetrics()
+	labels := metricLabels{index: index, operation: OperationQuery}
+	m.retrievalDuration.WithLabelValues(labels.values()...).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.retrievalErrors.WithLabelValues(labels.values()...).Inc()
+	}
+}
+
+// observeIndexed 记录存储到索引中的文档块数
+func observeIndexed(index string, n int) {
+	labels := metricLabels{index: index, operation: OperationIndex}
+	currentMetrics().documentsIndexed.WithLabelValues(labels.values()...).Add(float64(n))
+}
@@ -0,0 +1,100 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	// 开启 MMR 时默认先检索的候选文档数
+	defaultMMRCandidates = 20
+	// 默认的相关性权重
+	defaultMMRLambda = 0.5
+)
+
+// candidateLimit 每次检索取回的文档数：开启 MMR 时先多取一些候选文档，再从中挑选 TopK 个
+func (r *RAGQuery) candidateLimit() int {
+	if r.mmr && r.mmrCandidates > r.topK {
+		return r.mmrCandidates
+	}
+	return r.topK
+}
+
+// selectMMR 使用最大边际相关性（Maximal Marginal Relevance）从候选文档中挑选 topK 个：
+// 每一步选择 lambda*与问题的相似度 - (1-lambda)*与已选文档的最大相似度 最高的文档，
+// lambda 越大越看重相关性，越小越看重多样性
+// 候选文档的向量通过向量生成器重新获取（带缓存，索引时已向量化过的文档块会直接命中缓存）
+func (r *RAGQuery) selectMMR(ctx context.Context, query string, docs []*schema.Document) ([]*schema.Document, error) {
+	if len(docs) <= r.topK {
+		return docs, nil
+	}
+
+	texts := make([]string, 0, len(docs)+1)
+	texts = append(texts, query)
+	for _, doc := range docs {
+		texts = append(texts, doc.Content)
+	}
+	vectors, err := r.embedding.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed candidates for mmr: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	queryVec, docVecs := vectors[0], vectors[1:]
+
+	relevance := make([]float64, len(docs))
+	for i, vec := range docVecs {
+		relevance[i] = cosineSimilarity(queryVec, vec)
+	}
+
+	lambda := r.mmrLambda
+	selected := make([]int, 0, r.topK)
+	picked := make([]bool, len(docs))
+	for len(selected) < r.topK {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range docs {
+			if picked[i] {
+				continue
+			}
+			redundancy := 0.0
+			for _, j := range selected {
+				if sim := cosineSimilarity(docVecs[i], docVecs[j]); sim > redundancy {
+					redundancy = sim
+				}
+			}
+			score := lambda*relevance[i] - (1-lambda)*redundancy
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		selected = append(selected, best)
+	}
+
+	result := make([]*schema.Document, 0, len(selected))
+	for _, i := range selected {
+		result = append(result, docs[i])
+	}
+	return result, nil
+}
+
+// cosineSimilarity 计算两个向量的余弦相似度，任一向量为零向量时返回 0
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,115 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// neighborRef 某个检索结果需要拼接的相邻文档块
+type neighborRef struct {
+	hit    int    // 检索结果的下标
+	before bool   // true 为前一块，false 为后一块
+	id     string // 相邻文档块的 ID
+}
+
+// attachNeighbors 把每个检索结果在同一来源中的前一块和后一块（元数据 prev_id / next_id，见 linkChunks）拼接到结果的内容中，
+// 相邻块之间因为切块重叠而重复的部分会被去掉，元数据 neighbors 中记录拼接进来的文档块 ID。
+// 已经作为单独结果返回的块不再拼接；两个结果共用同一个相邻块时只拼接到排名靠前的结果中。
+// 没有 prev_id / next_id 的旧数据按 chunk_index 推算相邻块的 ID（见 legacyChunkID）；
+// 两者都没有的结果和已经被删除的相邻块直接跳过
+func (r *RAGQuery) attachNeighbors(ctx context.Context, docs []*schema.Document) error {
+	claimed := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		claimed[chunkID(doc)] = true
+	}
+
+	var refs []neighborRef
+	for i, doc := range docs {
+		prev, next := neighborIDs(doc)
+		for _, n := range []struct {
+			id     string
+			before bool
+		}{{prev, true}, {next, false}} {
+			if n.id == "" || claimed[n.id] {
+				continue
+			}
+			claimed[n.id] = true
+			refs = append(refs, neighborRef{hit: i, before: n.before, id: n.id})
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		ids[i] = ref.id
+	}
+	neighbors, err := r.store.Get(ctx, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch neighboring chunks: %w", err)
+	}
+	byID := make(map[string]*schema.Document, len(neighbors))
+	for _, n := range neighbors {
+		byID[chunkID(n)] = n
+	}
+
+	for _, ref := range refs {
+		n, ok := byID[ref.id]
+		if !ok {
+			continue
+		}
+		doc := docs[ref.hit]
+		if ref.before {
+			stitched := joinChunks(n.Content, doc.Content)
+			shiftHighlights(doc, utf8.RuneCountInString(stitched)-utf8.RuneCountInString(doc.Content))
+			doc.Content = stitched
+		} else {
+			doc.Content = joinChunks(doc.Content, n.Content)
+		}
+		ids, _ := doc.MetaData["neighbors"].([]string)
+		doc.MetaData["neighbors"] = append(ids, n.ID)
+	}
+	return nil
+}
+
+// neighborIDs 返回文档块在同一来源中前一块和后一块的 ID，没有时为空
+func neighborIDs(doc *schema.Document) (prev, next string) {
+	prev, _ = doc.MetaData["prev_id"].(string)
+	next, _ = doc.MetaData["next_id"].(string)
+	if prev != "" || next != "" {
+		return prev, next
+	}
+	// 旧数据的 ID 按序号生成，没有记录相邻块
+	source, _ := doc.MetaData["source"].(string)
+	idx := metaInt(doc.MetaData["chunk_index"])
+	if source == "" || idx < 0 {
+		return "", ""
+	}
+	if idx > 0 {
+		prev = legacyChunkID(source, idx-1)
+	}
+	return prev, legacyChunkID(source, idx+1)
+}
+
+// chunkID 返回文档块的 ID；检索结果的 ID 是完整的 Redis key，取最后一段
+func chunkID(doc *schema.Document) string {
+	return doc.ID[strings.LastIndexByte(doc.ID, ':')+1:]
+}
+
+// shiftHighlights 内容前面拼接了 n 个字符后，把关键词高亮的区间整体后移
+func shiftHighlights(doc *schema.Document, n int) {
+	highlights, ok := doc.MetaData["highlights"].([]Highlight)
+	if !ok || n == 0 {
+		return
+	}
+	shifted := make([]Highlight, len(highlights))
+	for i, h := range highlights {
+		shifted[i] = Highlight{Start: h.Start + n, End: h.End + n}
+	}
+	doc.MetaData["highlights"] = shifted
+}
@@ -0,0 +1,99 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/cloudwego/eino/schema"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeOptions 文本规范化配置，零值表示启用全部规范化步骤
+// 文档块在向量化之前、问题在检索之前都会做同样的规范化，两边的文本才能对得上；
+// 关闭某一步时，建立索引和检索应使用相同的配置
+type NormalizeOptions struct {
+	Disable          bool // 完全不做规范化，保留原文
+	KeepControlChars bool // 保留控制字符（默认删除 NUL 等不可见字符，换页符转换为换行）
+	KeepWhitespace   bool // 保留原有空白（默认合并连续的空格、去掉行尾空白、最多保留一个空行）
+	SkipNFC          bool // 不做 Unicode NFC 规范化（默认把组合字符合并为预组合形式）
+}
+
+// normalizeText 按配置规范化一段文本
+func normalizeText(text string, opts NormalizeOptions) string {
+	if opts.Disable {
+		return text
+	}
+	if !opts.KeepControlChars {
+		text = stripControlChars(text)
+	}
+	if !opts.SkipNFC {
+		text = norm.NFC.String(text)
+	}
+	if !opts.KeepWhitespace {
+		text = collapseWhitespace(text)
+	}
+	return text
+}
+
+// stripControlChars 删除控制字符和零宽字符，保留换行和制表符；换页符、\r\n、\r 统一转换为换行
+func stripControlChars(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\n', '\t':
+			return r
+		case '\r', '\f', '\v', '\u2028', '\u2029':
+			return '\n'
+		case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+			return -1
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// collapseWhitespace 合并行内连续的空白为一个空格，去掉行首行尾的空白，
+// 连续的空行合并为一个空行（保留段落分隔）
+func collapseWhitespace(text string) string {
+	lines := strings.Split(text, "\n")
+	var sb strings.Builder
+	blank := 0
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank++
+			continue
+		}
+		if sb.Len() > 0 {
+			if blank > 0 {
+				sb.WriteString("\n\n")
+			} else {
+				sb.WriteByte('\n')
+			}
+		}
+		blank = 0
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// normalizeDocuments 规范化文档块的内容并更新内容哈希，规范化后为空的文档块会被丢弃
+func normalizeDocuments(docs []*schema.Document, opts NormalizeOptions) []*schema.Document {
+	if opts.Disable {
+		return docs
+	}
+	kept := docs[:0]
+	for _, doc := range docs {
+		doc.Content = normalizeText(doc.Content, opts)
+		if doc.Content == "" {
+			continue
+		}
+		if doc.MetaData != nil {
+			doc.MetaData["content_hash"] = contentHash(doc.Content)
+		}
+		kept = append(kept, doc)
+	}
+	return kept
+}
@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDF 文本提取使用 github.com/ledongthuc/pdf，支持：
+// - 交叉引用流与压缩对象流（/ObjStm）
+// - FlateDecode 等常见过滤器压缩的内容流
+// - 按页树顺序逐页提取文本
+// - 字体的 ToUnicode 映射（中文 PDF 大多依赖它，包括 Type0 / CID 字体）
+// 扫描件（纯图片）以及加密的 PDF 无法提取文本。
+
+// extractPDFPages 按页提取 PDF 中的文本，返回值下标 i 对应第 i+1 页
+func extractPDFPages(filePath string) (pages []string, err error) {
+	// 解析器遇到损坏的文件时会 panic，转换为错误返回
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("invalid pdf file %s: %v", filePath, r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(filePath)
+	if err != nil {
+		if strings.Contains(err.Error(), "encrypt") {
+			return nil, fmt.Errorf("encrypted pdf is not supported: %s", filePath)
+		}
+		return nil, fmt.Errorf("invalid pdf file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	n := reader.NumPage()
+	if n == 0 {
+		return nil, fmt.Errorf("no pages found in pdf: %s", filePath)
+	}
+
+	pages = make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			pages = append(pages, "")
+			continue
+		}
+		// 不同页面的同名字体资源可能指向不同的字体，每页单独解析字体（传 nil）
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text from page %d: %w", i, err)
+		}
+		pages = append(pages, strings.TrimSpace(text))
+	}
+	return pages, nil
+}
@@ -0,0 +1,220 @@
+package rag
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pdfFixture 在测试中拼装最小可用的 PDF，避免把二进制文件提交到仓库
+type pdfFixture struct {
+	objects []string // 下标 i 对应对象号 i+1
+}
+
+func (p *pdfFixture) add(body string) int {
+	p.objects = append(p.objects, body)
+	return len(p.objects)
+}
+
+func streamObject(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(data string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.String()
+}
+
+// bytes 使用传统的 xref 表输出
+func (p *pdfFixture) bytes(root int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(p.objects))
+	for i, obj := range p.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.objects)+1, root, xref)
+	return buf.Bytes()
+}
+
+// bytesWithObjStm 把 inStream 中的对象放进压缩对象流（/ObjStm），并用交叉引用流代替 xref 表
+func (p *pdfFixture) bytesWithObjStm(root int, inStream map[int]bool) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+
+	var header, body strings.Builder
+	var packed []int
+	for i, obj := range p.objects {
+		if !inStream[i+1] {
+			continue
+		}
+		fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+		body.WriteString(obj + "\n")
+		packed = append(packed, i+1)
+	}
+	objStmNum := len(p.objects) + 1
+	xrefNum := len(p.objects) + 2
+
+	type entry struct{ typ, f2, f3 int }
+	entries := make([]entry, xrefNum+1)
+	entries[0] = entry{0, 0, 0xffff}
+	for idx, num := range packed {
+		entries[num] = entry{2, objStmNum, idx}
+	}
+	for i, obj := range p.objects {
+		if inStream[i+1] {
+			continue
+		}
+		entries[i+1] = entry{1, buf.Len(), 0}
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	data := header.String() + body.String()
+	entries[objStmNum] = entry{1, buf.Len(), 0}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", objStmNum, streamObject(
+		fmt.Sprintf("/Type /ObjStm /N %d /First %d /Filter /FlateDecode", len(packed), header.Len()), deflate(data)))
+
+	xref := buf.Len()
+	entries[xrefNum] = entry{1, xref, 0}
+	var table bytes.Buffer
+	for _, e := range entries {
+		table.Write([]byte{byte(e.typ), byte(e.f2 >> 24), byte(e.f2 >> 16), byte(e.f2 >> 8), byte(e.f2), byte(e.f3 >> 8), byte(e.f3)})
+	}
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", xrefNum, streamObject(
+		fmt.Sprintf("/Type /XRef /Size %d /W [1 4 2] /Root %d 0 R", xrefNum+1, root), table.String()))
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// simplePDF 生成每页一段文本的 PDF，内容流可选 FlateDecode 压缩
+func simplePDF(compress bool, texts ...string) *pdfFixture {
+	p := &pdfFixture{}
+	p.add("<< /Type /Catalog /Pages 2 0 R >>")
+	p.add("") // 页树在页面对象生成后回填
+	font := p.add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	var kids []string
+	for _, text := range texts {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+		var stream int
+		if compress {
+			stream = p.add(streamObject("/Filter /FlateDecode", deflate(content)))
+		} else {
+			stream = p.add(streamObject("", content))
+		}
+		page := p.add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", font, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	p.objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+	return p
+}
+
+// type0PDF 生成使用 Identity-H 编码的 Type0 字体的 PDF，文本只能通过 ToUnicode 还原
+func type0PDF() *pdfFixture {
+	p := &pdfFixture{}
+	p.add("<< /Type /Catalog /Pages 2 0 R >>")
+	p.add("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	p.add("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>")
+	p.add("<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H /DescendantFonts [6 0 R] /ToUnicode 7 0 R >>")
+	p.add(streamObject("", "BT /F1 12 Tf 72 720 Td <0001000200030004> Tj ET"))
+	p.add("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /SimSun /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /DW 1000 >>")
+	cmap := strings.Join([]string{
+		"/CIDInit /ProcSet findresource begin",
+		"12 dict begin",
+		"begincmap",
+		"/CMapName /Adobe-Identity-UCS def",
+		"/CMapType 2 def",
+		"1 begincodespacerange",
+		"<0000> <FFFF>",
+		"endcodespacerange",
+		"3 beginbfchar",
+		"<0001> <4E2D>",
+		"<0002> <6587>",
+		"<0004> <8BD5>",
+		"endbfchar",
+		"1 beginbfrange",
+		"<0003> <0003> <6D4B>",
+		"endbfrange",
+		"endcmap",
+		"CMapName currentdict /CMap defineresource pop",
+		"end",
+		"end",
+	}, "\n")
+	p.add(streamObject("", cmap))
+	return p
+}
+
+func writePDF(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.pdf")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractPDFPages(t *testing.T) {
+	objStm := simplePDF(true, "Packed page")
+
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"uncompressed stream", simplePDF(false, "Hello PDF").bytes(1), []string{"Hello PDF"}},
+		{"flate stream", simplePDF(true, "Compressed text").bytes(1), []string{"Compressed text"}},
+		{"multiple pages", simplePDF(true, "first", "second", "third").bytes(1), []string{"first", "second", "third"}},
+		// 目录、页树、字体、页面放进对象流，内容流本身不能放进对象流
+		{"object stream", objStm.bytesWithObjStm(1, map[int]bool{1: true, 2: true, 3: true, 5: true}), []string{"Packed page"}},
+		{"type0 to unicode", type0PDF().bytes(1), []string{"中文测试"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := extractPDFPages(writePDF(t, tt.data))
+			if err != nil {
+				t.Fatalf("extractPDFPages: %v", err)
+			}
+			if len(pages) != len(tt.want) {
+				t.Fatalf("got %d pages %q, want %d", len(pages), pages, len(tt.want))
+			}
+			for i := range tt.want {
+				if pages[i] != tt.want[i] {
+					t.Errorf("page %d = %q, want %q", i+1, pages[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractPDFPagesInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a pdf", []byte("plain text, not a pdf")},
+		{"truncated", simplePDF(false, "Hello").bytes(1)[:40]},
+		{"empty page tree", (&pdfFixture{objects: []string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [] /Count 0 >>",
+		}}).bytes(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := extractPDFPages(writePDF(t, tt.data)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
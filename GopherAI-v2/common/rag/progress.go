@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// 命令行工具的索引进度：每批文档块向量化并存储后回调一次 ProgressFunc（done / total 为文档块数），
+// 调用方不需要了解分批和并发的细节。下面的辅助函数把进度逐行写成普通文本，不依赖终端，
+// 输出重定向到文件或管道时同样可用；传入 nil 时不回调进度。例如：
+//
+//	stored, err := rag.IndexLocalFile(ctx, "admin", "docs/manual.md", rag.IndexOptions{
+//		Progress: rag.WriteProgress(os.Stderr), // embedded 30/120 chunks
+//	})
+
+// FileProgressFunc 批量索引（IndexFiles / IndexDir）的进度回调，path 为当前文件，done / total 为这个文件的文档块数
+type FileProgressFunc func(path string, done, total int)
+
+// WriteProgress 返回把进度写入 w 的 ProgressFunc，每次回调输出一行 "embedded 30/120 chunks"
+// w 为 nil 时返回 nil；写入失败时忽略错误，不影响索引
+func WriteProgress(w io.Writer) ProgressFunc {
+	if w == nil {
+		return nil
+	}
+	return func(done, total int) {
+		fmt.Fprintf(w, "embedded %d/%d chunks\n", done, total)
+	}
+}
+
+// WriteFileProgress 与 WriteProgress 相同，每行前加上文件名，例如 "manual.md: embedded 30/120 chunks"
+func WriteFileProgress(w io.Writer) FileProgressFunc {
+	if w == nil {
+		return nil
+	}
+	return func(path string, done, total int) {
+		fmt.Fprintf(w, "%s: embedded %d/%d chunks\n", filepath.Base(path), done, total)
+	}
+}
+
+// IndexLocalFile 把本地文件索引到用户的知识库（知识库名为文件名），使用配置中的向量模型，返回存储的文档块数
+// 供命令行工具使用：调用前需要先初始化 Redis（使用内存存储时不需要），进度通过 opts.Progress 回调
+func IndexLocalFile(ctx context.Context, username, filePath string, opts IndexOptions) (int, error) {
+	indexer, err := NewRAGIndexer(ctx, username, filepath.Base(filePath), config.GetConfig().RagModelConfig.RagEmbeddingModel, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer indexer.Close()
+	return indexer.IndexFile(ctx, filePath, opts)
+}
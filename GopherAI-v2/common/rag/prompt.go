@@ -0,0 +1,221 @@
+package rag
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// DefaultPromptTemplateText 默认的 RAG 提示词模板
+// 可用的占位符：{{.Context}} 检索到的参考文档，{{.Query}} 用户问题
+const DefaultPromptTemplateText = `基于以下参考文档回答用户的问题。如果文档中没有相关信息，请说明无法找到相关信息。
+
+参考文档：
+{{.Context}}
+
+用户问题：{{.Query}}
+
+请提供准确、完整的回答，并在引用参考文档的内容后用对应的来源标签（如 [来源: manual.pdf 第3块]）注明出处：`
+
+// DefaultEnglishPromptTemplateText 英文问题使用的默认提示词模板
+const DefaultEnglishPromptTemplateText = `Answer the user's question based on the reference documents below. If the documents do not contain the relevant information, say that it could not be found.
+
+Reference documents:
+{{.Context}}
+
+Question: {{.Query}}
+
+Give an accurate and complete answer. After using content from a reference document, cite it with its source label (for example [来源: manual.pdf 第3块]):`
+
+// DefaultPromptTemplate 默认提示词模板，BuildRAGPrompt 使用该模板
+var DefaultPromptTemplate = MustPromptTemplate(DefaultPromptTemplateText)
+
+// DefaultEnglishPromptTemplate 英文问题的默认提示词模板，未指定模板时按问题的语言选用
+var DefaultEnglishPromptTemplate = MustPromptTemplate(DefaultEnglishPromptTemplateText)
+
+// defaultTemplateFor 按问题的语言选择默认模板：英文问题使用英文模板，其它情况（包括无法判断）使用中文模板
+func defaultTemplateFor(query string) *PromptTemplate {
+	if DetectLanguage(query) == LanguageEnglish {
+		return DefaultEnglishPromptTemplate
+	}
+	return DefaultPromptTemplate
+}
+
+// promptData 渲染提示词模板时可用的数据
+type promptData struct {
+	Context string
+	Query   string
+}
+
+// PromptTemplate 可自定义的 RAG 提示词模板（text/template 语法）
+type PromptTemplate struct {
+	tmpl *template.Template
+}
+
+// NewPromptTemplate 解析提示词模板
+// 模板语法错误或使用了 {{.Context}} / {{.Query}} 以外的占位符时返回错误
+func NewPromptTemplate(text string) (*PromptTemplate, error) {
+	tmpl, err := template.New("rag_prompt").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	// 用示例数据试渲染一次，未知的占位符会在这里报错
+	if err := tmpl.Execute(io.Discard, promptData{}); err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return &PromptTemplate{tmpl: tmpl}, nil
+}
+
+// MustPromptTemplate 与 NewPromptTemplate 相同，但解析失败时 panic，适用于包级变量初始化
+func MustPromptTemplate(text string) *PromptTemplate {
+	t, err := NewPromptTemplate(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// BuildRAGPrompt 构建包含检索文档的提示词（使用默认模板，英文问题使用英文模板）
+func BuildRAGPrompt(query string, docs []*schema.Document) string {
+	prompt, err := BuildRAGPromptWithTemplate(nil, query, docs)
+	if err != nil {
+		// 默认模板在初始化时已校验，这里不会出错；兜底返回原始问题
+		return query
+	}
+	return prompt
+}
+
+// BuildRAGPromptWithTemplate 使用指定模板构建包含检索文档的提示词
+// 没有检索到文档时直接返回原始问题；tmpl 为 nil 时按问题的语言使用默认模板
+func BuildRAGPromptWithTemplate(tmpl *PromptTemplate, query string, docs []*schema.Document) (string, error) {
+	if len(docs) == 0 {
+		return query, nil
+	}
+	if tmpl == nil {
+		tmpl = defaultTemplateFor(query)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.tmpl.Execute(&sb, promptData{Context: formatContext(docs), Query: query}); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// PromptOptions 构建提示词的配置，零值表示使用默认模板、不限制参考文档长度
+type PromptOptions struct {
+	Template *PromptTemplate // 提示词模板，为 nil 时按问题的语言使用默认模板
+	// MaxContextTokensEstimate 参考文档最多占用的 token 数，0 表示不限制
+	// token 数由 estimateTokens 按字符粗略估算，不是模型分词器的精确值
+	// 按检索排名依次放入文档，放不下的文档会被截断（剩余预算足够时）或丢弃
+	MaxContextTokensEstimate int
+}
+
+// 剩余预算少于该值时不再截断放入文档，直接丢弃
+const minTruncatedDocTokens = 50
+
+// BuildRAGPromptWithOptions 构建包含检索文档的提示词，同时返回实际放入提示词的文档数
+// 放入的一定是 docs 的前若干个（按检索排名）
+func BuildRAGPromptWithOptions(query string, docs []*schema.Document, opts PromptOptions) (string, int, error) {
+	if len(docs) == 0 {
+		return query, 0, nil
+	}
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = defaultTemplateFor(query)
+	}
+
+	contextText, included := formatContextWithBudget(docs, opts.MaxContextTokensEstimate)
+	if included == 0 {
+		return query, 0, nil
+	}
+	var sb strings.Builder
+	if err := tmpl.tmpl.Execute(&sb, promptData{Context: contextText, Query: query}); err != nil {
+		return "", 0, fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return sb.String(), included, nil
+}
+
+// formatContext 将检索到的文档拼接为参考文档文本
+func formatContext(docs []*schema.Document) string {
+	contextText, _ := formatContextWithBudget(docs, 0)
+	return contextText
+}
+
+// formatContextWithBudget 按顺序拼接文档，直到用完 maxTokens 的预算（0 表示不限制），返回放入的文档数
+// 第一个放不下的文档在剩余预算足够时截断后放入，之后的文档全部丢弃
+func formatContextWithBudget(docs []*schema.Document, maxTokens int) (string, int) {
+	var sb strings.Builder
+	used := 0
+	for i, doc := range docs {
+		prefix := citationLabel(i, doc)
+		// 带有章节信息的文档块（Markdown 切块）标注所属章节；
+		// splitMarkdown 已经把标题路径写在内容开头时不再重复标注
+		if heading, ok := doc.MetaData["heading"].(string); ok && heading != "" && !strings.HasPrefix(doc.Content, heading+"\n") {
+			prefix += fmt.Sprintf("（章节：%s）", heading)
+		}
+		prefix += ": "
+		content := doc.Content
+
+		if maxTokens > 0 {
+			cost := estimateTokens(prefix) + estimateTokens(content)
+			if used+cost > maxTokens {
+				remaining := maxTokens - used - estimateTokens(prefix)
+				if remaining < minTruncatedDocTokens {
+					return sb.String(), i
+				}
+				sb.WriteString(prefix + truncateToTokens(content, remaining) + "…\n\n")
+				return sb.String(), i + 1
+			}
+			used += cost
+		}
+		sb.WriteString(prefix + content + "\n\n")
+	}
+	return sb.String(), len(docs)
+}
+
+// citationLabel 生成文档的来源标签，例如 "[来源: manual.pdf 第2页 第3块]"，
+// 文档有不同于文件名的标题时为 "[来源: 用户手册（manual.pdf） 第2页 第3块]"
+// 没有来源信息时退化为序号标签 "[文档 1]"
+func citationLabel(i int, doc *schema.Document) string {
+	source, _ := doc.MetaData["source"].(string)
+	if source == "" {
+		return fmt.Sprintf("[文档 %d]", i+1)
+	}
+
+	// 有标题且与文件名不同时以标题标注来源，文件名放在括号中
+	label := "[来源: " + filepath.Base(source)
+	if title, _ := doc.MetaData["title"].(string); title != "" && title != filepath.Base(source) {
+		label = fmt.Sprintf("[来源: %s（%s）", title, filepath.Base(source))
+	}
+	if page := metaInt(doc.MetaData["page"]); page > 0 {
+		label += fmt.Sprintf(" 第%d页", page)
+	}
+	// chunk_index 从 0 开始，展示给用户时从 1 开始
+	if idx := metaInt(doc.MetaData["chunk_index"]); idx >= 0 {
+		label += fmt.Sprintf(" 第%d块", idx+1)
+	}
+	return label + "]"
+}
+
+// metaInt 读取整数类型的元数据（索引时为 int，检索回来为 string），不存在或非法时返回 -1
+func metaInt(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,137 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 公开知识库：知识库默认只有所属用户可以检索，被标记为公开后任何用户都可以在查询时选择同时检索它
+// （QueryOptions.RetrieveFromPublic）。哪些知识库是公开的由调用方通过 SetPublicIndexLister 提供
+// （服务中记录在 MySQL 中，见 dao/visibility），rag 包只检索列表中的知识库，其他用户的私有知识库不会被检索到。
+// 公开知识库的子查询器在创建查询器时确定，之后被删除的公开知识库在检索时报错，只记录日志并跳过。
+
+// 同时检索的公开知识库最多的个数，超过时只取列表中的前几个
+const maxPublicIndexes = 10
+
+// IndexRef 一个知识库：所属的用户和文件名
+type IndexRef struct {
+	Owner    string
+	Filename string
+}
+
+// publicIndexLister 返回所有公开的知识库，为 nil 时没有公开知识库
+var publicIndexLister func(ctx context.Context) ([]IndexRef, error)
+
+// SetPublicIndexLister 设置列出公开知识库的函数，传入 nil 时不检索任何公开知识库
+// 返回的列表中只能包含公开的知识库；应在初始化阶段调用，不要与检索并发调用
+func SetPublicIndexLister(fn func(ctx context.Context) ([]IndexRef, error)) {
+	publicIndexLister = fn
+}
+
+// listPublicIndexes 返回除 exclude 以外的公开知识库
+func listPublicIndexes(ctx context.Context, exclude IndexRef) ([]IndexRef, error) {
+	if publicIndexLister == nil {
+		return nil, nil
+	}
+	refs, err := publicIndexLister(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list public indexes: %w", err)
+	}
+	filtered := make([]IndexRef, 0, len(refs))
+	for _, ref := range refs {
+		if ref != exclude && ref.Owner != "" && ref.Filename != "" {
+			filtered = append(filtered, ref)
+		}
+	}
+	if len(filtered) > maxPublicIndexes {
+		logger.Warn("rag too many public indexes", "count", len(filtered), "max", maxPublicIndexes)
+		filtered = filtered[:maxPublicIndexes]
+	}
+	return filtered, nil
+}
+
+// newPublicQueries 为每个公开知识库创建子查询器，无法创建的知识库（例如索引已被删除）只记录日志并跳过
+func newPublicQueries(ctx context.Context, refs []IndexRef, opts QueryOptions) []*RAGQuery {
+	var queries []*RAGQuery
+	for _, ref := range refs {
+		q, err := newIndexQuery(ctx, ref.Owner, ref.Filename, opts)
+		if err != nil {
+			logger.Warn("rag public index unavailable", "owner", ref.Owner, "filename", ref.Filename, "error", err)
+			continue
+		}
+		// 限流只按发起检索的用户计数一次
+		q.username = ""
+		queries = append(queries, q)
+	}
+	return queries
+}
+
+// attachPublicIndexes 把公开知识库（不包括用户自己的 filename）加入检索范围，列出公开知识库失败时只检索自己的知识库
+func (r *RAGQuery) attachPublicIndexes(ctx context.Context, filename string, opts QueryOptions) {
+	refs, err := listPublicIndexes(ctx, IndexRef{Owner: r.owner, Filename: filename})
+	if err != nil {
+		logger.Warn("rag public indexes skipped", "username", r.username, "error", err)
+		return
+	}
+	r.public = newPublicQueries(ctx, refs, opts)
+}
+
+// newPublicOnlyQuery 用户自己没有知识库时，以第一个可用的公开知识库为主查询器，其余的作为子查询器
+// 没有可用的公开知识库时返回 ErrNoUploadedFile
+func newPublicOnlyQuery(ctx context.Context, username string, opts QueryOptions) (*RAGQuery, error) {
+	refs, err := listPublicIndexes(ctx, IndexRef{})
+	if err != nil {
+		return nil, err
+	}
+	queries := newPublicQueries(ctx, refs, opts)
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("user %s: %w", username, ErrNoUploadedFile)
+	}
+	q := queries[0]
+	q.username = username
+	q.public = queries[1:]
+	return q, nil
+}
+
+// ownIndexOnlyKey ctx 中带有该 key 时 RetrieveDocuments 只检索查询器自己的知识库
+type ownIndexOnlyKey struct{}
+
+// retrieveWithPublic 检索自己的知识库和所有公开知识库，各路结果按倒数排名融合后取前 topK 个
+// 自己的知识库检索失败时返回错误，公开知识库检索失败时只记录日志
+func (r *RAGQuery) retrieveWithPublic(ctx context.Context, query string, opts RetrieveOptions) ([]*schema.Document, error) {
+	own, err := r.RetrieveDocuments(context.WithValue(ctx, ownIndexOnlyKey{}, true), query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rankings := make([][]*schema.Document, len(r.public)+1)
+	rankings[0] = own
+	var wg sync.WaitGroup
+	for i, pq := range r.public {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			docs, err := pq.RetrieveDocuments(ctx, query, opts)
+			if err != nil {
+				logger.Warn("rag public index retrieval failed", "index", pq.indexName, "error", err)
+				return
+			}
+			rankings[i+1] = docs
+		}()
+	}
+	wg.Wait()
+	return fuseRRF(r.topK, rankings...), nil
+}
+
+// tagIndexOwner 检索的是其他用户的知识库时，在元数据 index_owner 中记录知识库所属的用户
+func (r *RAGQuery) tagIndexOwner(docs []*schema.Document) {
+	if r.owner == r.username {
+		return
+	}
+	for _, doc := range docs {
+		doc.MetaData["index_owner"] = r.owner
+	}
+}
@@ -0,0 +1,257 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 查询器池：NewRAGQuery 每次都要创建向量生成器和检索器、读取索引结构，聊天时每个问题都重新创建代价很高。
+// 查询器池按 用户名 + 知识库 + 查询配置 复用创建好的查询器，空闲超过 idleTTL 后由后台协程关闭。
+// RAGQuery 创建后只读，同一个查询器可以被多个请求并发使用。
+// 知识库被删除或重建后，池中对应的查询器会被作废，下一次获取时重新创建。
+
+// 默认的查询器空闲时间
+const defaultQueryPoolIdleTTL = 5 * time.Minute
+
+// 清理协程的最短检查间隔
+const minQueryPoolSweepInterval = time.Second
+
+// queryPoolKey 池中查询器的 key，options 为 QueryOptions 序列化后的字符串
+type queryPoolKey struct {
+	username string
+	filename string
+	options  string
+}
+
+// pooledQuery 池中的一个查询器
+type pooledQuery struct {
+	query    *RAGQuery
+	refs     int       // 正在使用该查询器的调用方数量
+	lastUsed time.Time // 最后一次归还的时间
+	stale    bool      // 已从池中移除，最后一个使用者归还后关闭
+}
+
+// QueryPool RAG 查询器池，并发安全
+type QueryPool struct {
+	idleTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[queryPoolKey]*pooledQuery
+	closed  bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewQueryPool 创建查询器池并启动后台清理协程，idleTTL <= 0 时使用默认值 5 分钟
+// 不再使用时调用 Close 停止清理协程并关闭所有查询器
+func NewQueryPool(idleTTL time.Duration) *QueryPool {
+	if idleTTL <= 0 {
+		idleTTL = defaultQueryPoolIdleTTL
+	}
+	p := &QueryPool{
+		idleTTL: idleTTL,
+		entries: make(map[queryPoolKey]*pooledQuery),
+		stop:    make(chan struct{}),
+	}
+	go p.sweepLoop(max(idleTTL/2, minQueryPoolSweepInterval))
+	return p
+}
+
+// Get 获取用户的查询器，池中没有可用的查询器时用 NewRAGQuery 创建
+// 用完后必须调用 release 归还（不要调用查询器的 Close），release 可以重复调用
+func (p *QueryPool) Get(ctx context.Context, username string, opts QueryOptions) (_ *RAGQuery, release func(), err error) {
+	filename, err := userIndexFile(username)
+	if opts.RetrieveFromPublic && errors.Is(err, ErrNoUploadedFile) {
+		// 只检索公开知识库的查询器
+		filename, err = "", nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	optsKey, err := json.Marshal(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := queryPoolKey{username: username, filename: filename, options: string(optsKey)}
+
+	p.mu.Lock()
+	if entry, ok := p.entries[key]; ok && !p.closed {
+		entry.refs++
+		p.mu.Unlock()
+		return entry.query, p.releaser(entry), nil
+	}
+	p.mu.Unlock()
+
+	// 创建查询器需要访问 Redis 和向量模型，不持有锁
+	q, err := NewRAGQuery(ctx, username, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return q, func() { q.Close() }, nil
+	}
+	// 并发创建时保留先放入池中的查询器
+	if entry, ok := p.entries[key]; ok {
+		entry.refs++
+		p.mu.Unlock()
+		q.Close()
+		return entry.query, p.releaser(entry), nil
+	}
+	entry := &pooledQuery{query: q, refs: 1}
+	p.entries[key] = entry
+	p.mu.Unlock()
+	return q, p.releaser(entry), nil
+}
+
+// releaser 返回归还查询器的函数，只有第一次调用生效
+func (p *QueryPool) releaser(entry *pooledQuery) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			entry.refs--
+			entry.lastUsed = time.Now()
+			closeNow := entry.stale && entry.refs == 0
+			p.mu.Unlock()
+			if closeNow {
+				entry.query.Close()
+			}
+		})
+	}
+}
+
+// Invalidate 作废用户某个知识库的所有查询器（知识库被删除或重建后调用）
+// 正在使用的查询器在归还后关闭
+func (p *QueryPool) Invalidate(username, filename string) {
+	var toClose []*RAGQuery
+	p.mu.Lock()
+	for key, entry := range p.entries {
+		if key.username != username || key.filename != filename {
+			continue
+		}
+		delete(p.entries, key)
+		entry.stale = true
+		if entry.refs == 0 {
+			toClose = append(toClose, entry.query)
+		}
+	}
+	p.mu.Unlock()
+	closeQueries(toClose)
+}
+
+// Len 返回池中的查询器数量
+func (p *QueryPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Close 停止清理协程并关闭池中所有查询器，可以重复调用
+// 正在使用的查询器在归还后关闭，之后 Get 创建的查询器不再放入池中
+func (p *QueryPool) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	var toClose []*RAGQuery
+	p.mu.Lock()
+	p.closed = true
+	for key, entry := range p.entries {
+		delete(p.entries, key)
+		entry.stale = true
+		if entry.refs == 0 {
+			toClose = append(toClose, entry.query)
+		}
+	}
+	p.mu.Unlock()
+	closeQueries(toClose)
+	return nil
+}
+
+// sweepLoop 定期关闭空闲超过 idleTTL 的查询器
+func (p *QueryPool) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.sweep(now)
+		}
+	}
+}
+
+func (p *QueryPool) sweep(now time.Time) {
+	var toClose []*RAGQuery
+	p.mu.Lock()
+	for key, entry := range p.entries {
+		if entry.refs == 0 && now.Sub(entry.lastUsed) >= p.idleTTL {
+			delete(p.entries, key)
+			toClose = append(toClose, entry.query)
+		}
+	}
+	p.mu.Unlock()
+	closeQueries(toClose)
+}
+
+func closeQueries(queries []*RAGQuery) {
+	for _, q := range queries {
+		if err := q.Close(); err != nil {
+			logger.Warn("close rag query failed", "index", q.indexName, "error", err)
+		}
+	}
+}
+
+// 按配置创建的全局查询器池，第一次使用时创建
+var (
+	defaultQueryPool     atomic.Pointer[QueryPool]
+	defaultQueryPoolOnce sync.Once
+)
+
+// sharedQueryPool 返回全局查询器池，配置 queryPoolIdleTTL 小于 0 时返回 nil（不复用查询器）
+func sharedQueryPool() *QueryPool {
+	ttl := config.GetConfig().RagModelConfig.RagQueryPoolIdleTTL
+	if ttl < 0 {
+		return nil
+	}
+	defaultQueryPoolOnce.Do(func() {
+		defaultQueryPool.Store(NewQueryPool(time.Duration(ttl) * time.Second))
+	})
+	return defaultQueryPool.Load()
+}
+
+// AcquireQuery 从全局查询器池获取用户的查询器，用完后调用 release 归还
+// 配置为不复用查询器时每次新建，release 会关闭它
+func AcquireQuery(ctx context.Context, username string, opts QueryOptions) (*RAGQuery, func(), error) {
+	pool := sharedQueryPool()
+	if pool == nil {
+		q, err := NewRAGQuery(ctx, username, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return q, func() { q.Close() }, nil
+	}
+	return pool.Get(ctx, username, opts)
+}
+
+// invalidatePooledQueries 知识库被删除或重建后作废全局池中对应的查询器
+func invalidatePooledQueries(username, filename string) {
+	if pool := defaultQueryPool.Load(); pool != nil {
+		pool.Invalidate(username, filename)
+	}
+}
+
+// CloseQueryPool 关闭全局查询器池（服务退出时调用，需要在关闭 Redis 之前）
+func CloseQueryPool() error {
+	if pool := defaultQueryPool.Load(); pool != nil {
+		return pool.Close()
+	}
+	return nil
+}
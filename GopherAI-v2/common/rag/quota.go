@@ -0,0 +1,308 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 知识库配额：限制每个用户索引的文件数、文件总字节数和文档块数，避免单个用户占满 Redis 内存。
+// 用户的使用量记录在 Redis 中（quota:user:{用户名}），每个索引还按来源文件记录各自占用的量（quota:index:{索引名}），
+// 重新索引同一个文件时只计算差值，删除索引或文档块时归还对应的用量。
+// 只有 Redis 存储会统计和检查配额。
+
+// ErrQuotaExceeded 超出知识库配额，可通过 errors.Is 判断，具体的限制和用量见 *QuotaExceededError
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// 配额的资源类型
+const (
+	QuotaResourceFiles  = "files"
+	QuotaResourceBytes  = "bytes"
+	QuotaResourceChunks = "chunks"
+)
+
+// QuotaExceededError 超出配额的资源、配额上限、当前用量和本次需要的量
+type QuotaExceededError struct {
+	Resource  string
+	Limit     int64
+	Usage     int64
+	Requested int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s usage %d + %d exceeds limit %d", e.Resource, e.Usage, e.Requested, e.Limit)
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaUsage 知识库配额的使用量
+type QuotaUsage struct {
+	Files  int64 `json:"files"`
+	Bytes  int64 `json:"bytes"`
+	Chunks int64 `json:"chunks"`
+}
+
+// GetQuotaUsage 返回用户当前的配额使用量
+func GetQuotaUsage(ctx context.Context, username string) (QuotaUsage, error) {
+	if redisPkg.Rdb == nil {
+		return QuotaUsage{}, nil
+	}
+	vals, err := redisPkg.Rdb.HMGet(ctx, redisPkg.GenerateQuotaUser(username),
+		QuotaResourceFiles, QuotaResourceBytes, QuotaResourceChunks).Result()
+	if err != nil {
+		return QuotaUsage{}, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	n := func(v interface{}) int64 {
+		i, _ := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+		return i
+	}
+	return QuotaUsage{Files: n(vals[0]), Bytes: n(vals[1]), Chunks: n(vals[2])}, nil
+}
+
+// sourceUsage 读取一个来源文件在索引中占用的量，ok 为 false 表示还没有记录
+func sourceUsage(ctx context.Context, indexName, source string) (usage QuotaUsage, ok bool, err error) {
+	val, err := redisPkg.Rdb.HGet(ctx, redisPkg.GenerateQuotaIndex(indexName), source).Result()
+	if err == redisCli.Nil {
+		return QuotaUsage{}, false, nil
+	}
+	if err != nil {
+		return QuotaUsage{}, false, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	return parseSourceUsage(val), true, nil
+}
+
+// 来源文件的用量以 "字节数:文档块数" 的形式存储
+func formatSourceUsage(bytes, chunks int64) string {
+	return fmt.Sprintf("%d:%d", bytes, chunks)
+}
+
+func parseSourceUsage(val string) QuotaUsage {
+	bytesStr, chunksStr, _ := strings.Cut(val, ":")
+	bytes, _ := strconv.ParseInt(bytesStr, 10, 64)
+	chunks, _ := strconv.ParseInt(chunksStr, 10, 64)
+	return QuotaUsage{Files: 1, Bytes: bytes, Chunks: chunks}
+}
+
+// reserveQuotaScript 原子地预占用户的配额
+// KEYS[1] 为用户用量的 key，ARGV 依次为每种资源的 名称、预占量、上限（0 表示不限制）；
+// 逐个 HINCRBY，某个资源超出上限时撤销本次已经加上的量，返回 {资源名, 预占前的用量}，全部成功返回空表
+const reserveQuotaScript = `
+for i = 1, #ARGV, 3 do
+	local amount = tonumber(ARGV[i + 1])
+	local limit = tonumber(ARGV[i + 2])
+	local usage = redis.call('HINCRBY', KEYS[1], ARGV[i], amount)
+	if limit > 0 and usage > limit then
+		for j = 1, i, 3 do
+			redis.call('HINCRBY', KEYS[1], ARGV[j], -tonumber(ARGV[j + 1]))
+		end
+		return {ARGV[i], tostring(usage - amount)}
+	end
+end
+return {}
+`
+
+// quotaReservation 索引来源文件前预占的配额
+// 存储完成后调用 commit 按实际存储的文档块数结算，存储失败时调用 release 归还；两者只能调用一个
+type quotaReservation struct {
+	username  string
+	indexName string
+	source    string
+	old       QuotaUsage // 来源文件原来占用的量
+	hadOld    bool       // 来源文件原来是否有记录
+	reserved  QuotaUsage // 已经加到用户用量上的量
+}
+
+// reserveQuota 预占索引来源文件（bytes 字节、切出 chunks 个文档块）需要增加的用量，超出用户的配额时返回 *QuotaExceededError
+// 检查和增加在同一个 Lua 脚本中完成，并发索引不会一起越过上限；只增加的部分需要预占，减少的部分在 commit 时结算
+// 没有使用 Redis 存储时返回 nil，nil 的 commit / release 不做任何事
+func (r *RAGIndexer) reserveQuota(ctx context.Context, source string, bytes, chunks int64) (*quotaReservation, error) {
+	if r.client == nil || redisPkg.Rdb == nil {
+		return nil, nil
+	}
+	old, ok, err := sourceUsage(ctx, r.indexName, source)
+	if err != nil {
+		return nil, err
+	}
+	res := &quotaReservation{username: r.username, indexName: r.indexName, source: source, old: old, hadOld: ok}
+	delta := res.delta(bytes, chunks)
+
+	limits := config.GetConfig().QuotaConfig.Limits(r.username)
+	var args []interface{}
+	for _, c := range []struct {
+		resource  string
+		requested int64
+		limit     int64
+		reserved  *int64
+	}{
+		{QuotaResourceFiles, delta.Files, limits.MaxFiles, &res.reserved.Files},
+		{QuotaResourceBytes, delta.Bytes, limits.MaxBytes, &res.reserved.Bytes},
+		{QuotaResourceChunks, delta.Chunks, limits.MaxChunks, &res.reserved.Chunks},
+	} {
+		if c.requested > 0 {
+			args = append(args, c.resource, c.requested, c.limit)
+			*c.reserved = c.requested
+		}
+	}
+	if len(args) == 0 {
+		return res, nil
+	}
+
+	vals, err := redisPkg.Rdb.Eval(ctx, reserveQuotaScript, []string{redisPkg.GenerateQuotaUser(r.username)}, args...).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve quota: %w", err)
+	}
+	if len(vals) == 2 {
+		resource := fmt.Sprint(vals[0])
+		usage, _ := strconv.ParseInt(fmt.Sprint(vals[1]), 10, 64)
+		e := &QuotaExceededError{Resource: resource, Usage: usage}
+		switch resource {
+		case QuotaResourceFiles:
+			e.Limit, e.Requested = limits.MaxFiles, delta.Files
+		case QuotaResourceBytes:
+			e.Limit, e.Requested = limits.MaxBytes, delta.Bytes
+		case QuotaResourceChunks:
+			e.Limit, e.Requested = limits.MaxChunks, delta.Chunks
+		}
+		logger.Warn("rag quota exceeded", "username", r.username, "resource", e.Resource,
+			"limit", e.Limit, "usage", e.Usage, "requested", e.Requested)
+		return nil, e
+	}
+	return res, nil
+}
+
+// delta 计算把来源文件的用量更新为 bytes / chunks 后，用户用量的变化
+func (res *quotaReservation) delta(bytes, chunks int64) QuotaUsage {
+	delta := QuotaUsage{Bytes: bytes - res.old.Bytes, Chunks: chunks - res.old.Chunks}
+	if !res.hadOld {
+		delta.Files = 1
+	}
+	return delta
+}
+
+// commit 把来源文件的用量记录为 bytes / chunks（实际存储的文档块数），并按与预占量的差值调整用户的总用量
+// chunks 为 0（一个文档块都没有存储）时等同于 release；失败只记录日志，不影响已经完成的索引
+func (res *quotaReservation) commit(ctx context.Context, bytes, chunks int64) {
+	if res == nil {
+		return
+	}
+	if chunks == 0 {
+		res.release(ctx)
+		return
+	}
+	delta := res.delta(bytes, chunks)
+	adjust := QuotaUsage{
+		Files:  delta.Files - res.reserved.Files,
+		Bytes:  delta.Bytes - res.reserved.Bytes,
+		Chunks: delta.Chunks - res.reserved.Chunks,
+	}
+	if err := applyUsage(ctx, res.username, res.indexName, res.source, formatSourceUsage(bytes, chunks), adjust); err != nil {
+		logger.Warn("record quota usage failed", "username", res.username, "source", res.source, "error", err)
+	}
+}
+
+// release 归还预占的配额，来源文件原来的用量记录保持不变
+func (res *quotaReservation) release(ctx context.Context) {
+	if res == nil || res.reserved == (QuotaUsage{}) {
+		return
+	}
+	userKey := redisPkg.GenerateQuotaUser(res.username)
+	pipe := redisPkg.Rdb.Pipeline()
+	pipe.HIncrBy(ctx, userKey, QuotaResourceFiles, -res.reserved.Files)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceBytes, -res.reserved.Bytes)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceChunks, -res.reserved.Chunks)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("release quota reservation failed", "username", res.username, "source", res.source, "error", err)
+	}
+}
+
+// applyUsage 更新来源文件的用量（value 为空表示删除记录），并按 delta 调整用户的总用量
+// 用户和索引的两个 key 在集群模式下可能不在同一个 slot，因此使用普通 pipeline 而不是事务
+func applyUsage(ctx context.Context, username, indexName, source, value string, delta QuotaUsage) error {
+	pipe := redisPkg.Rdb.Pipeline()
+	indexKey := redisPkg.GenerateQuotaIndex(indexName)
+	if value == "" {
+		pipe.HDel(ctx, indexKey, source)
+	} else {
+		pipe.HSet(ctx, indexKey, source, value)
+	}
+	userKey := redisPkg.GenerateQuotaUser(username)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceFiles, delta.Files)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceBytes, delta.Bytes)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceChunks, delta.Chunks)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// releaseSourceUsage 归还来源文件占用的全部用量（该文件的文档块全部被删除时调用）
+func releaseSourceUsage(ctx context.Context, username, indexName, source string) {
+	if redisPkg.Rdb == nil {
+		return
+	}
+	old, ok, err := sourceUsage(ctx, indexName, source)
+	if err == nil && ok {
+		err = applyUsage(ctx, username, indexName, source, "", QuotaUsage{Files: -1, Bytes: -old.Bytes, Chunks: -old.Chunks})
+	}
+	if err != nil {
+		logger.Warn("release quota usage failed", "username", username, "source", source, "error", err)
+	}
+}
+
+// releaseChunks 归还来源文件中 n 个被删除的文档块，文档块全部删除时同时归还文件数和字节数
+func releaseChunks(ctx context.Context, username, indexName, source string, n int64) {
+	if redisPkg.Rdb == nil {
+		return
+	}
+	old, ok, err := sourceUsage(ctx, indexName, source)
+	if err != nil || !ok {
+		if err != nil {
+			logger.Warn("release quota usage failed", "username", username, "source", source, "error", err)
+		}
+		return
+	}
+	if old.Chunks <= n {
+		releaseSourceUsage(ctx, username, indexName, source)
+		return
+	}
+	err = applyUsage(ctx, username, indexName, source, formatSourceUsage(old.Bytes, old.Chunks-n), QuotaUsage{Chunks: -n})
+	if err != nil {
+		logger.Warn("release quota usage failed", "username", username, "source", source, "error", err)
+	}
+}
+
+// releaseIndexUsage 归还整个索引占用的用量（删除索引时调用）
+func releaseIndexUsage(ctx context.Context, username, indexName string) {
+	if redisPkg.Rdb == nil {
+		return
+	}
+	indexKey := redisPkg.GenerateQuotaIndex(indexName)
+	sources, err := redisPkg.Rdb.HGetAll(ctx, indexKey).Result()
+	if err != nil {
+		logger.Warn("release quota usage failed", "username", username, "index", indexName, "error", err)
+		return
+	}
+	var total QuotaUsage
+	for _, val := range sources {
+		u := parseSourceUsage(val)
+		total.Files += u.Files
+		total.Bytes += u.Bytes
+		total.Chunks += u.Chunks
+	}
+	pipe := redisPkg.Rdb.Pipeline()
+	pipe.Del(ctx, indexKey)
+	userKey := redisPkg.GenerateQuotaUser(username)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceFiles, -total.Files)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceBytes, -total.Bytes)
+	pipe.HIncrBy(ctx, userKey, QuotaResourceChunks, -total.Chunks)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Warn("release quota usage failed", "username", username, "index", indexName, "error", err)
+	}
+}
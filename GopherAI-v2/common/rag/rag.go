@@ -5,57 +5,136 @@ import (
 	redisPkg "GopherAI/common/redis"
 	"GopherAI/config"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	embeddingArk "github.com/cloudwego/eino-ext/components/embedding/ark"
 	redisIndexer "github.com/cloudwego/eino-ext/components/indexer/redis"
 	redisRetriever "github.com/cloudwego/eino-ext/components/retriever/redis"
 	"github.com/cloudwego/eino/components/embedding"
-	"github.com/cloudwego/eino/components/retriever"
 	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
 	redisCli "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// 默认每批处理的文档块数
+const defaultBatchSize = 10
+
 type RAGIndexer struct {
 	embedding embedding.Embedder
-	indexer   *redisIndexer.Indexer
+	store     VectorStore
+	username  string           // 知识库所属的用户，用于配额统计
+	indexName string           // 知识库索引名，用于指标标签
+	keyPrefix string           // 该知识库中所有文档块在 Redis 中的 key 前缀
+	batchSize int              // 每批存储的文档块数
+	client    *redisCli.Client // 使用内存存储时为 nil
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 type RAGQuery struct {
 	embedding embedding.Embedder
-	retriever retriever.Retriever
+	store     VectorStore
+	rerank    bool // 检索后是否调用重排序模型
+
+	username   string // 查询器所属的用户，用于限流；公开知识库的子查询器为空，不重复计数
+	owner      string // 知识库所属的用户
+	indexName  string
+	topK       int
+	searchMode string
+	dialect    int
+	normalize  NormalizeOptions // 检索前对问题的规范化
+	chat       ChatOptions
+	history    HistoryOptions
+
+	expandQuery   bool // 检索前是否改写问题
+	queryVariants int
+
+	mmr           bool // 是否用 MMR 重新挑选检索结果
+	mmrLambda     float64
+	mmrCandidates int
+
+	maxContextTokens int
+
+	returnFields []string // 检索时返回的字段
+	convert      func(ctx context.Context, doc redisCli.Document) (*schema.Document, error)
+	client       *redisCli.Client // 索引所在节点的客户端，使用内存存储时为 nil
+
+	public []*RAGQuery // 同时检索的公开知识库（QueryOptions.RetrieveFromPublic）
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // 构建知识库索引
 // 专业说法：文本解析、文本切块、向量化、存储向量
 // 通俗理解：把“人能读的文档”，转换成“AI 能按语义搜索的格式”，并存起来
-func NewRAGIndexer(filename, embeddingModel string) (*RAGIndexer, error) {
-
-	// 用于控制整个初始化流程（超时 / 取消等），这里先用默认背景即可
-	ctx := context.Background()
+// 索引按 用户名 + 文件名 区分，不同用户上传同名文件互不影响
+// batchSize 为每批向量化、写入 Redis 的文档块数，0 表示使用默认值 10
+// ctx 控制整个初始化流程（创建向量模型、探测维度、创建 Redis 索引），取消或超时后立即返回 ctx 的错误
+// batchSize 不合法时返回 ErrInvalidOptions，探测向量维度时调用向量模型失败返回 ErrEmbeddingFailed
+func NewRAGIndexer(ctx context.Context, username, filename, embeddingModel string, batchSize int) (_ *RAGIndexer, err error) {
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+	if batchSize < 1 {
+		return nil, fmt.Errorf("%w: batch size %d must be >= 1", ErrInvalidOptions, batchSize)
+	}
 
-	// 从环境变量中读取调用向量模型所需的 API Key
-	apiKey := os.Getenv("OPENAI_API_KEY")
+	indexName := redis.GenerateIndexName(username, filename)
+	ctx, span := startSpan(ctx, "rag.NewRAGIndexer", attribute.String("rag.index", indexName))
+	defer func() { endSpan(span, err) }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	// 向量的维度大小（等于向量模型输出的数字个数）
-	// Redis 在创建向量索引时必须提前知道这个值
-	dimension := config.GetConfig().RagModelConfig.RagDimension
+	// 向量的维度大小（等于向量模型输出的数字个数，配置了 reducedDimension 时为截断后的维度）
+	// Redis 在创建向量索引时必须提前知道这个值；已有的知识库沿用索引的维度
+	dimension, err := indexDimension(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
 
 	// 1. 配置并创建“向量生成器”（Embedding）
 	// 可以理解为：找一个“翻译官”，
 	// 专门负责把文本翻译成 AI 能理解的“向量表示”
-	embedConfig := &embeddingArk.EmbeddingConfig{
-		BaseURL: config.GetConfig().RagModelConfig.RagBaseUrl, // 向量模型服务地址
-		APIKey:  apiKey,                                       // 鉴权信息
-		Model:   embeddingModel,                               // 使用哪个向量模型
+	// 使用哪家的向量模型服务由配置中的 embeddingProvider 决定，主服务不可用时新建的知识库可以改用备用模型
+	// 后续所有文本的“向量化”都会通过它完成
+	// 临时错误自动重试，并加一层 Redis 缓存，重新索引未变化的文档块时不再重复调用向量模型
+	// 同时校验配置的维度与向量模型实际输出的维度一致，
+	// 否则 Redis 索引会拒绝写入向量，报错信息也很难看懂
+	embedder, embedderID, err := selectEmbedder(ctx, indexName, embeddingModel, dimension, OperationIndex)
+	if err != nil {
+		return nil, err
 	}
 
-	// 创建向量生成器实例
-	// 后续所有文本的“向量化”都会通过它完成
-	embedder, err := embeddingArk.NewEmbedder(ctx, embedConfig)
+	// 配置使用内存存储时不需要 Redis
+	backend, err := vectorStoreBackend()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create embedder: %w", err)
+		return nil, err
+	}
+	if backend == VectorStoreMemory {
+		keyPrefix := docKeyPrefix(username, filename, 0)
+		if err := recordIndexEmbedder(ctx, indexName, embedderID); err != nil {
+			return nil, err
+		}
+		return &RAGIndexer{
+			embedding: embedder,
+			store:     memoryStoreFor(indexName, keyPrefix, embedder),
+			username:  username,
+			indexName: indexName,
+			keyPrefix: keyPrefix,
+			batchSize: batchSize,
+		}, nil
 	}
 
 	// ===============================
@@ -63,20 +142,43 @@ func NewRAGIndexer(filename, embeddingModel string) (*RAGIndexer, error) {
 	// ===============================
 	// 可以理解为：先在 Redis 里建好“仓库”，
 	// 告诉它以后要存向量，并且每个向量的维度是多少
-	if err := redisPkg.InitRedisIndex(ctx, filename, dimension); err != nil {
+	initCtx, initSpan := startSpan(ctx, "rag.redis.init_index", attribute.Int("rag.dimension", dimension))
+	err = redisPkg.InitRedisIndex(initCtx, username, filename, dimension, vectorIndexOptions())
+	endSpan(initSpan, err)
+	if err != nil {
+		logger.Error("failed to init redis index", "username", username, "filename", filename, "error", err)
 		return nil, fmt.Errorf("failed to init redis index: %w", err)
 	}
+	// 记录建立索引使用的向量模型，之后写入和检索都使用同一个模型
+	if err := recordIndexEmbedder(ctx, indexName, embedderID); err != nil {
+		return nil, err
+	}
+	logger.Info("rag index ready", "username", username, "filename", filename, "embedder", embedderID, "dimension", dimension)
 
-	// 获取 Redis 客户端，用于后续数据写入
-	rdb := redisPkg.Rdb
+	// 获取 Redis 客户端，用于后续数据写入（集群模式下为知识库所在的节点）
+	rdb, err := redisPkg.IndexClient(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
+	// 重建过的知识库，文档块写入当前这一代的前缀下
+	gen, err := redisPkg.IndexGeneration(ctx, username, filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index generation: %w", err)
+	}
+	keyPrefix := docKeyPrefix(username, filename, gen)
+	// 新建知识库时配置了原文压缩，索引结构中没有 content 全文字段
+	compress, err := contentCompressed(ctx, rdb, indexName)
+	if err != nil {
+		return nil, err
+	}
 
 	// ===============================
 	// 3. 配置索引器（定义：文档如何被存进 Redis）
 	// ===============================
 	indexerConfig := &redisIndexer.IndexerConfig{
-		Client:    rdb,                                     // Redis 客户端
-		KeyPrefix: redis.GenerateIndexNamePrefix(filename), // 不同知识库使用不同前缀，避免冲突
-		BatchSize: 10,                                      // 批量处理文档，提高写入效率
+		Client:    rdb,                                                // Redis 客户端
+		KeyPrefix: redis.GenerationKeyPrefix(username, filename, gen), // 不同用户、不同知识库使用不同前缀，避免冲突
+		BatchSize: batchSize,                                          // 批量处理文档，提高写入效率
 
 		// 定义：一段文档（Document）在 Redis 中该如何存储
 		DocumentToHashes: func(ctx context.Context, doc *schema.Document) (*redisIndexer.Hashes, error) {
@@ -87,21 +189,40 @@ func NewRAGIndexer(filename, embeddingModel string) (*RAGIndexer, error) {
 				source = s
 			}
 
+			// Redis Hash 中的字段
+			fields := map[string]redisIndexer.FieldValue{
+				// content：原始文本内容
+				// EmbedKey 表示：该字段需要先做向量化，
+				// 生成的向量会存入名为 "vector" 的字段中
+				"content": {Value: doc.Content, EmbedKey: "vector"},
+
+				// metadata：一些辅助信息，不参与向量计算
+				"metadata": {Value: source},
+			}
+
+			// 原文压缩存储时，向量化的仍然是原文
+			if compress {
+				text := doc.Content
+				fields["content"] = redisIndexer.FieldValue{
+					Value:     encodeContent(text, true),
+					EmbedKey:  "vector",
+					Stringify: func(any) (string, error) { return text, nil },
+				}
+			}
+
+			// 其余元数据（chunk_index、page 等）各自存为独立字段，不参与向量计算
+			for k, v := range doc.MetaData {
+				if k == "source" {
+					continue
+				}
+				fields[k] = redisIndexer.FieldValue{Value: fmt.Sprint(v)}
+			}
+
 			// 构造 Redis 中实际存储的数据结构（Hash）
 			return &redisIndexer.Hashes{
 				// Redis Key，一般由“知识库名 + 文档块 ID”组成
-				Key: fmt.Sprintf("%s:%s", filename, doc.ID),
-
-				// Redis Hash 中的字段
-				Field2Value: map[string]redisIndexer.FieldValue{
-					// content：原始文本内容
-					// EmbedKey 表示：该字段需要先做向量化，
-					// 生成的向量会存入名为 "vector" 的字段中
-					"content": {Value: doc.Content, EmbedKey: "vector"},
-
-					// metadata：一些辅助信息，不参与向量计算
-					"metadata": {Value: source},
-				},
+				Key:         docKeySuffix(filename, doc.ID),
+				Field2Value: fields,
 			}, nil
 		},
 	}
@@ -125,107 +246,586 @@ func NewRAGIndexer(filename, embeddingModel string) (*RAGIndexer, error) {
 	// 后续只需要调用它，就可以把文档加入知识库
 	return &RAGIndexer{
 		embedding: embedder,
-		indexer:   idx,
+		store: &redisVectorStore{
+			indexer:   idx,
+			client:    rdb,
+			indexName: indexName,
+			keyPrefix: keyPrefix,
+		},
+		username:  username,
+		indexName: indexName,
+		keyPrefix: keyPrefix,
+		batchSize: indexerConfig.BatchSize,
+		client:    rdb,
 	}, nil
 }
 
-// IndexFile 读取文件内容并创建向量索引
-func (r *RAGIndexer) IndexFile(ctx context.Context, filePath string) error {
-	// 读取文件内容
-	content, err := os.ReadFile(filePath)
+// ProgressFunc 索引进度回调，done 为已存储的文档块数，total 为文档块总数
+type ProgressFunc func(done, total int)
+
+// IndexOptions 索引文件的配置，零值表示使用默认切块配置、不回调进度
+type IndexOptions struct {
+	ChunkOptions
+	// Progress 每批文档块存储完成后回调一次，最后一次回调时 done == total；
+	// 文件没有任何内容时不会回调
+	Progress ProgressFunc
+	// MaxConcurrency 最多同时向量化的批次数，0 表示使用默认值 4；
+	// 向量模型有限流时可以调小，1 表示逐批串行处理
+	MaxConcurrency int
+	// CSV 按行索引的配置：每一行作为一个独立文档，不再切块
+	// .csv / .tsv 文件总是按行索引，为 nil 时使用默认配置；其它文件设置后同样按 CSV 解析
+	CSV *CSVOptions
+	// Dedup 内容相同（忽略空白差异）的文档块只存储一次，例如每页重复的页眉页脚、免责声明；
+	// 保留第一次出现的块，并在元数据 locations 中记录所有出现位置
+	Dedup bool
+	// FileProgress 批量索引（IndexFiles / IndexDir）时带文件路径的进度回调，与 Progress 同时回调；单个文件索引时不使用
+	FileProgress FileProgressFunc
+}
+
+// 用于探测向量维度的文本
+const dimensionProbeText = "dimension probe"
+
+// validateDimension 向量化一段探测文本，检查向量长度是否等于配置的维度
+func validateDimension(ctx context.Context, embedder embedding.Embedder, model string, dimension int) error {
+	vectors, err := embedder.EmbedStrings(ctx, []string{dimensionProbeText})
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return fmt.Errorf("failed to probe embedding dimension: %w", err)
 	}
+	if len(vectors) != 1 {
+		return fmt.Errorf("failed to probe embedding dimension: %w: got %d vectors for 1 text", ErrEmbeddingFailed, len(vectors))
+	}
+	if len(vectors[0]) != dimension {
+		return fmt.Errorf("embedding dimension mismatch: model %s outputs %d dimensions but ragModelConfig.dimension is %d",
+			model, len(vectors[0]), dimension)
+	}
+	return nil
+}
 
-	// 将文件内容转换为文档
-	// TODO: 这里可以根据需要进行文本切块，目前简单处理为一个文档
-	doc := &schema.Document{
-		ID:      "doc_1", // 可以使用 UUID 或其他唯一标识
-		Content: string(content),
-		MetaData: map[string]any{
-			"source": filePath,
-		},
+// ErrInvalidOptions 创建索引器 / 查询器或索引文件时传入的配置不合法，可通过 errors.Is 判断（对应请求参数错误）
+var ErrInvalidOptions = errors.New("invalid options")
+
+// ErrNoChunksIndexed 文件不为空，但没有提取出任何可以索引的文本，可通过 errors.Is 判断
+var ErrNoChunksIndexed = errors.New("no chunks were extracted from a non-empty file")
+
+// ErrEmptyDocument 文件（或网页）没有内容或只有空白字符，可通过 errors.Is 判断
+// 空白内容向量化后是没有意义的向量，会干扰检索，因此不会写入知识库
+var ErrEmptyDocument = errors.New("document is empty or contains only whitespace")
+
+// IndexFile 读取文件内容，切块后创建向量索引
+// 返回实际存储的文档块数；出错时为出错前已存储的块数
+// 配置不合法时返回 ErrInvalidOptions，向量化失败时返回 ErrEmbeddingFailed；
+// 文件为空或只有空白字符时返回 0 和 ErrEmptyDocument；
+// 文件有内容但没有切出任何文档块（例如扫描版 PDF 中没有文字）时返回 0 和 ErrNoChunksIndexed
+// 索引后会超出用户的知识库配额时不存储任何文档块，返回 *QuotaExceededError（errors.Is(err, ErrQuotaExceeded) 为 true）
+func (r *RAGIndexer) IndexFile(ctx context.Context, filePath string, opts IndexOptions) (stored int, err error) {
+	ctx, span := startSpan(ctx, "rag.IndexFile", attribute.String("rag.file", filepath.Base(filePath)))
+	defer func() { endSpan(span, err) }()
+
+	if opts.MaxConcurrency == 0 {
+		opts.MaxConcurrency = defaultMaxConcurrency
+	}
+	if opts.MaxConcurrency < 1 {
+		return 0, fmt.Errorf("%w: max concurrency %d must be >= 1", ErrInvalidOptions, opts.MaxConcurrency)
 	}
 
-	// 使用 indexer 存储文档（会自动进行向量化）
-	_, err = r.indexer.Store(ctx, []*schema.Document{doc})
+	docs, err := loadDocuments(filePath, opts)
 	if err != nil {
-		return fmt.Errorf("failed to store document: %w", err)
+		return 0, err
+	}
+	if opts.Dedup {
+		docs = dedupDocuments(docs)
+	}
+	span.SetAttributes(attribute.Int("rag.chunks", len(docs)))
+	if len(docs) == 0 {
+		if blank, blankErr := isBlankFile(filePath); blankErr == nil && blank {
+			return 0, fmt.Errorf("%s: %w", filepath.Base(filePath), ErrEmptyDocument)
+		}
+		return 0, fmt.Errorf("%s: %w", filepath.Base(filePath), ErrNoChunksIndexed)
 	}
 
-	return nil
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	quota, err := r.reserveQuota(ctx, filePath, info.Size(), int64(len(docs)))
+	if err != nil {
+		return 0, err
+	}
+
+	// 使用 indexer 按批存储文档（会自动进行向量化），多个批次并发处理，每批完成后汇报进度
+	stored, err = r.storeBatches(ctx, docs, opts.MaxConcurrency, opts.Progress)
+	quota.commit(context.WithoutCancel(ctx), info.Size(), int64(stored))
+	return stored, err
+}
+
+// isBlankFile 判断文件是否为空或只包含空白字符（忽略 UTF-8 BOM）
+func isBlankFile(filePath string) (bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(data), "\uFEFF")) == "", nil
 }
 
-// DeleteIndex 删除指定文件的知识库索引（静态方法，不依赖实例）
-func DeleteIndex(ctx context.Context, filename string) error {
-	if err := redisPkg.DeleteRedisIndex(ctx, filename); err != nil {
+// loadDocuments 按索引配置把文件转换为待存储的文档：表格文件按行，其它文件提取文本后切块
+func loadDocuments(filePath string, opts IndexOptions) ([]*schema.Document, error) {
+	if opts.CSV != nil || isTabularFile(filePath) {
+		var csvOpts CSVOptions
+		if opts.CSV != nil {
+			csvOpts = *opts.CSV
+		}
+		docs, err := buildCSVDocuments(filePath, csvOpts)
+		if err != nil {
+			return nil, err
+		}
+		// 表格文件的第一行是表头而不是标题，直接使用文件名
+		return linkChunks(tagTitles(tagLanguages(normalizeDocuments(docs, opts.Normalize)), fallbackTitle(filePath))), nil
+	}
+	return buildDocuments(filePath, opts.ChunkOptions)
+}
+
+// buildDocuments 提取文件文本并切块，每一块作为一个独立文档，块内容按 opts.Normalize 规范化
+// 文档 ID 由文件路径和块序号决定，元数据中带有内容哈希，用于增量更新
+func buildDocuments(filePath string, opts ChunkOptions) ([]*schema.Document, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	// 提取文件中的文本（PDF 会按页提取）
+	segments, err := extractSegments(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return linkChunks(tagLanguages(normalizeDocuments(chunkSegments(filePath, segments, opts), opts.Normalize))), nil
+}
+
+// chunkSegments 将文本切块，每一块作为一个独立文档，source 为文件路径或网页 URL
+// 每一块都带有整个文档的标题（见 detectTitle）；opts 需要已经补全默认值；文档 ID 由 linkChunks 生成
+func chunkSegments(source string, segments []textSegment, opts ChunkOptions) []*schema.Document {
+	title := detectTitle(source, segments)
+	var docs []*schema.Document
+	for _, seg := range segments {
+		for _, chunk := range chunkText(seg.Text, opts) {
+			// 只有空白的块（例如连续的空行、只有标题分隔符的段落）不存储
+			if strings.TrimSpace(chunk.Content) == "" {
+				continue
+			}
+			i := len(docs)
+			metadata := map[string]any{
+				"source":       source,
+				"chunk_index":  i,
+				"content_hash": contentHash(chunk.Content),
+				"title":        title,
+			}
+			if seg.Page > 0 {
+				metadata["page"] = seg.Page
+			}
+			if chunk.Heading != "" {
+				metadata["heading"] = chunk.Heading
+			}
+			docs = append(docs, &schema.Document{
+				Content:  chunk.Content,
+				MetaData: metadata,
+			})
+		}
+	}
+	return docs
+}
+
+// docKeySuffix 文档块在知识库前缀之后的 key 部分（文件名经过转义）
+func docKeySuffix(filename, docID string) string {
+	return fmt.Sprintf("%s:%s", redisPkg.EscapeKeyPart(filename), docID)
+}
+
+// newChunkID 根据来源、块内容的哈希和同样内容在来源中第几次出现（从 0 开始）生成文档 ID（UUID v5）
+// 不同文件的块 ID 互不相同；文件修改后内容没变的块 ID 保持不变，
+// 在前面插入或删除内容不会让后面所有块的 ID 都变化，增量更新（UpdateFile）只需重新向量化真正变化的块
+func newChunkID(source, hash string, occurrence int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s#%s#%d", source, hash, occurrence))).String()
+}
+
+// legacyChunkID 旧版本按文件路径和块序号生成的文档 ID，用于查找旧数据的相邻块
+func legacyChunkID(source string, index int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s#%d", source, index))).String()
+}
+
+// linkChunks 按内容为文档块生成 ID（见 newChunkID），并在元数据 prev_id / next_id 中
+// 记录同一来源中前一块和后一块的 ID，检索时按它们拼接相邻块（见 attachNeighbors）
+// 需要在规范化之后调用，这样规范化时丢弃的块不会出现在相邻关系中
+func linkChunks(docs []*schema.Document) []*schema.Document {
+	occurrences := map[string]int{}
+	for i, doc := range docs {
+		if doc.MetaData == nil {
+			doc.MetaData = map[string]any{}
+		}
+		source, _ := doc.MetaData["source"].(string)
+		hash, _ := doc.MetaData["content_hash"].(string)
+		if hash == "" {
+			hash = contentHash(doc.Content)
+		}
+		key := source + "#" + hash
+		doc.ID = newChunkID(source, hash, occurrences[key])
+		occurrences[key]++
+
+		delete(doc.MetaData, "prev_id")
+		delete(doc.MetaData, "next_id")
+		if i > 0 {
+			if prevSource, _ := docs[i-1].MetaData["source"].(string); prevSource == source {
+				doc.MetaData["prev_id"] = docs[i-1].ID
+				docs[i-1].MetaData["next_id"] = doc.ID
+			}
+		}
+	}
+	return docs
+}
+
+// vectorIndexOptions 按配置返回新建向量索引使用的算法和参数
+func vectorIndexOptions() redisPkg.VectorIndexOptions {
+	conf := config.GetConfig().RagModelConfig
+	return redisPkg.VectorIndexOptions{
+		Algorithm:      conf.RagVectorIndexAlgorithm,
+		DistanceMetric: conf.RagDistanceMetric,
+		M:              conf.RagHnswM,
+		EFConstruction: conf.RagHnswEfConstruction,
+		EFRuntime:      conf.RagHnswEfRuntime,
+		NoContentText:  conf.RagContentCompression != "",
+	}
+}
+
+// DeleteIndex 删除指定用户某个文件的知识库索引（静态方法，不依赖实例）
+func DeleteIndex(ctx context.Context, username, filename string) error {
+	backend, err := vectorStoreBackend()
+	if err != nil {
+		return err
+	}
+	indexName := redis.GenerateIndexName(username, filename)
+	invalidatePooledQueries(username, filename)
+	if err := forgetIndexEmbedder(ctx, indexName); err != nil {
+		return fmt.Errorf("failed to delete index embedder: %w", err)
+	}
+	// 同名知识库重新建立后不能读到旧知识库的检索结果
+	invalidateResultCache(ctx, indexName)
+	if backend == VectorStoreMemory {
+		dropMemoryStore(indexName)
+		return nil
+	}
+	if err := redisPkg.DeleteRedisIndex(ctx, username, filename); err != nil {
 		return fmt.Errorf("failed to delete redis index: %w", err)
 	}
+	releaseIndexUsage(ctx, username, indexName)
 	return nil
 }
 
-// NewRAGQuery 创建 RAG 查询器（用于向量检索和问答）
-func NewRAGQuery(ctx context.Context, username string) (*RAGQuery, error) {
-	cfg := config.GetConfig()
-	apiKey := os.Getenv("OPENAI_API_KEY")
+// ListIndexes 列出 Redis 中所有的知识库，返回对应的文件名（按字典序）
+// 不符合 GopherAI 命名规则的索引会被忽略
+func ListIndexes(ctx context.Context) ([]string, error) {
+	names, err := redisPkg.ListRedisIndexes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redis indexes: %w", err)
+	}
+
+	// 重建索引期间同一个知识库会同时存在新旧两代索引
+	seen := make(map[string]bool)
+	filenames := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, filename, ok := redisPkg.ParseIndexName(name); ok && !seen[filename] {
+			seen[filename] = true
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
 
-	// 创建 embedding 模型
-	embedConfig := &embeddingArk.EmbeddingConfig{
-		BaseURL: cfg.RagModelConfig.RagBaseUrl,
-		APIKey:  apiKey,
-		Model:   cfg.RagModelConfig.RagEmbeddingModel,
+// ListIndexesForUser 列出指定用户的知识库（只匹配该用户前缀下的索引）
+// 旧版本的索引名不含用户名，这类索引以用户上传目录中的文件为准
+func ListIndexesForUser(ctx context.Context, username string) ([]string, error) {
+	names, err := redisPkg.ListRedisIndexes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redis indexes: %w", err)
+	}
+
+	owned, err := uploadedFiles(username)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	filenames := make([]string, 0)
+	for _, name := range names {
+		owner, filename, ok := redisPkg.ParseIndexName(name)
+		if !ok || seen[filename] {
+			continue
+		}
+		if owner == username || (owner == "" && owned[filename]) {
+			seen[filename] = true
+			filenames = append(filenames, filename)
+		}
 	}
-	embedder, err := embeddingArk.NewEmbedder(ctx, embedConfig)
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+// uploadedFiles 返回用户上传目录中的文件名集合，目录不存在时返回空集合
+func uploadedFiles(username string) (map[string]bool, error) {
+	userDir, err := config.GetConfig().UserUploadDir(username)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(userDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create embedder: %w", err)
+		if os.IsNotExist(err) {
+			return map[string]bool{}, nil
+		}
+		return nil, fmt.Errorf("failed to read upload dir for user %s: %w", username, err)
+	}
+	owned := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !f.IsDir() {
+			owned[f.Name()] = true
+		}
 	}
+	return owned, nil
+}
+
+// 默认检索返回的文档数
+const defaultTopK = 5
+
+// 默认的 RediSearch 查询方言
+const defaultDialect = 2
+
+// QueryOptions RAG 查询配置，零值表示使用默认配置
+type QueryOptions struct {
+	TopK int // 检索返回的文档数，0 表示使用默认值 5
+	// Rerank 检索后是否使用重排序模型按相关度重新排序（需要在配置中设置重排序模型）
+	Rerank bool
+	// SearchMode 检索方式：vector（默认）/ keyword / hybrid
+	SearchMode string
+	// Chat 问答（Answer / AnswerStream）使用的对话模型参数，未设置的项使用配置中的值
+	Chat ChatOptions
+	// History 多轮对话（AnswerWithHistory）保留的历史长度
+	History HistoryOptions
+	// ExpandQuery 检索前先让对话模型把问题改写为更完整的检索问题，
+	// 原问题和改写后的问题分别检索，结果按文档去重后合并
+	ExpandQuery bool
+	// QueryVariants 改写出的问题个数（最多 5 个），0 表示 1 个
+	QueryVariants int
+	// UseMMR 先检索 MMRCandidates 个候选文档，再用最大边际相关性（MMR）从中挑选 TopK 个，
+	// 避免返回多个内容几乎相同的文档块
+	UseMMR bool
+	// Lambda MMR 中相关性的权重（0-1），越小越看重多样性；0 表示使用默认值 0.5
+	Lambda float64
+	// MMRCandidates MMR 的候选文档数，0 表示使用默认值 20
+	MMRCandidates int
+	// MaxContextTokensEstimate 问答时参考文档最多占用的 token 数，0 表示不限制
+	// token 数由 estimateTokens 按字符粗略估算，不是模型分词器的精确值，留出余量设置
+	MaxContextTokensEstimate int
+	// ReturnFields 除默认字段（content、metadata、chunk_index、page、heading、lang、title、prev_id、next_id）外额外返回的元数据字段，
+	// 字段必须在索引结构中；NUMERIC 类型的字段会解析为数值
+	ReturnFields []string
+	// Normalize 检索前对问题的规范化，应与建立索引时 ChunkOptions.Normalize 的配置一致
+	Normalize NormalizeOptions
+	// Dialect 检索使用的 RediSearch 查询方言（2-4），0 表示使用默认值 2；向量检索要求方言不低于 2
+	Dialect int
+	// RetrieveFromPublic 同时检索其他用户标记为公开的知识库（见 SetPublicIndexLister），结果按排名融合，
+	// 来自其他用户知识库的文档块在元数据 index_owner 中记录所属的用户；用户自己没有知识库时只检索公开知识库
+	RetrieveFromPublic bool
+}
+
+// ErrIndexNotFound 用户还没有上传文档或知识库索引不存在，可通过 errors.Is 判断（提示用户先上传文档）
+var ErrIndexNotFound = redisPkg.ErrIndexNotFound
+
+// ErrNoUploadedFile 用户的上传目录中没有文件，是 ErrIndexNotFound 的一种（errors.Is(err, ErrIndexNotFound) 同样成立）
+var ErrNoUploadedFile = fmt.Errorf("no uploaded file found: %w", ErrIndexNotFound)
 
-	// 获取用户上传的文件名（假设每个用户只有一个文件）
-	// 这里需要从用户目录读取文件名
-	userDir := fmt.Sprintf("uploads/%s", username)
+// userIndexFile 返回用户上传的文件名（假设每个用户只有一个文件），即检索使用的知识库
+// 这里需要从用户目录读取文件名
+func userIndexFile(username string) (string, error) {
+	userDir, err := config.GetConfig().UserUploadDir(username)
+	if err != nil {
+		return "", err
+	}
 	files, err := os.ReadDir(userDir)
 	if err != nil || len(files) == 0 {
-		return nil, fmt.Errorf("no uploaded file found for user %s", username)
+		return "", fmt.Errorf("user %s: %w", username, ErrNoUploadedFile)
 	}
 
-	var filename string
 	for _, f := range files {
 		if !f.IsDir() {
-			filename = f.Name()
-			break
+			return f.Name(), nil
 		}
 	}
+	return "", fmt.Errorf("user %s: %w", username, ErrNoUploadedFile)
+}
+
+// NewRAGQuery 创建 RAG 查询器（用于向量检索和问答）
+// 配置不合法时返回 ErrInvalidOptions；用户没有上传文件时返回 ErrNoUploadedFile，没有可用的知识库时返回 ErrIndexNotFound
+func NewRAGQuery(ctx context.Context, username string, opts QueryOptions) (_ *RAGQuery, err error) {
+	ctx, span := startSpan(ctx, "rag.NewRAGQuery")
+	defer func() { endSpan(span, err) }()
+
+	if opts.TopK == 0 {
+		opts.TopK = defaultTopK
+	}
+	if opts.TopK < 1 {
+		return nil, fmt.Errorf("%w: TopK %d must be >= 1", ErrInvalidOptions, opts.TopK)
+	}
+	switch opts.SearchMode {
+	case "":
+		opts.SearchMode = SearchModeVector
+	case SearchModeVector, SearchModeKeyword, SearchModeHybrid:
+	default:
+		return nil, fmt.Errorf("%w: unknown search mode %s", ErrInvalidOptions, opts.SearchMode)
+	}
+
+	if opts.Lambda == 0 {
+		opts.Lambda = defaultMMRLambda
+	}
+	if opts.Lambda < 0 || opts.Lambda > 1 {
+		return nil, fmt.Errorf("%w: Lambda %v must be between 0 and 1", ErrInvalidOptions, opts.Lambda)
+	}
+	if opts.MMRCandidates == 0 {
+		opts.MMRCandidates = defaultMMRCandidates
+	}
+	if opts.Dialect == 0 {
+		opts.Dialect = defaultDialect
+	}
+	if opts.Dialect < 2 || opts.Dialect > 4 {
+		return nil, fmt.Errorf("%w: Dialect %d must be between 2 and 4", ErrInvalidOptions, opts.Dialect)
+	}
+	if opts.MMRCandidates < 1 {
+		return nil, fmt.Errorf("%w: MMRCandidates %d must be >= 1", ErrInvalidOptions, opts.MMRCandidates)
+	}
+	if opts.MaxContextTokensEstimate < 0 {
+		return nil, fmt.Errorf("%w: MaxContextTokensEstimate %d must be >= 0", ErrInvalidOptions, opts.MaxContextTokensEstimate)
+	}
+
+	filename, err := userIndexFile(username)
+	if opts.RetrieveFromPublic && errors.Is(err, ErrNoUploadedFile) {
+		// 没有自己的知识库时只检索公开知识库
+		return newPublicOnlyQuery(ctx, username, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	q, err := newIndexQuery(ctx, username, filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("rag.index", q.indexName))
+	if opts.RetrieveFromPublic {
+		q.attachPublicIndexes(ctx, filename, opts)
+	}
+	return q, nil
+}
+
+// newIndexQuery 创建检索 username 的 filename 知识库的查询器，opts 已经校验并填好默认值
+func newIndexQuery(ctx context.Context, username, filename string, opts QueryOptions) (*RAGQuery, error) {
+	ragConf := config.GetConfig().RagModelConfig
+
+	q := &RAGQuery{
+		rerank:     opts.Rerank,
+		username:   username,
+		owner:      username,
+		topK:       opts.TopK,
+		searchMode: opts.SearchMode,
+		dialect:    opts.Dialect,
+		normalize:  opts.Normalize,
+		chat:       opts.Chat,
+		history:    opts.History,
+
+		expandQuery:   opts.ExpandQuery,
+		queryVariants: opts.QueryVariants,
+
+		mmr:           opts.UseMMR,
+		mmrLambda:     opts.Lambda,
+		mmrCandidates: opts.MMRCandidates,
 
-	if filename == "" {
-		return nil, fmt.Errorf("no valid file found for user %s", username)
+		maxContextTokens: opts.MaxContextTokensEstimate,
+	}
+
+	// 配置使用内存存储时不需要 Redis，只支持向量检索
+	backend, err := vectorStoreBackend()
+	if err != nil {
+		return nil, err
+	}
+	if backend == VectorStoreMemory {
+		if opts.SearchMode != SearchModeVector {
+			return nil, fmt.Errorf("search mode %s: %w", opts.SearchMode, ErrUnsupportedByStore)
+		}
+		q.indexName = redis.GenerateIndexName(username, filename)
+		store, ok := lookupMemoryStore(q.indexName, nil)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", q.indexName, ErrIndexNotFound)
+		}
+		q.embedding, _, err = selectEmbedder(ctx, q.indexName, ragConf.RagEmbeddingModel, newIndexDimension(), OperationQuery)
+		if err != nil {
+			return nil, err
+		}
+		store.embedder = q.embedding
+		q.store = store
+		return q, nil
 	}
 
 	// 创建 retriever
-	rdb := redisPkg.Rdb
-	indexName := redis.GenerateIndexName(filename)
+	// 只检索该用户自己的索引，升级前创建的旧索引仍然可以被找到
+	rdb, err := redisPkg.IndexClient(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
+	indexName, err := redis.ResolveIndexName(ctx, username, filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve index name: %w", err)
+	}
+	// 索引不存在时在这里报错，而不是等到检索时才返回难以理解的 Redis 错误
+	exists, err := redis.IndexExists(ctx, indexName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check index: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", indexName, ErrIndexNotFound)
+	}
+
+	// 创建 embedding 模型：使用建立该知识库时的向量模型，临时错误自动重试，相同的问题直接命中缓存
+	// 问题向量按索引的维度截断，与文档块的向量一致
+	dimension, err := indexDimension(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
+	embedder, _, err := selectEmbedder(ctx, indexName, ragConf.RagEmbeddingModel, dimension, OperationQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, convert, err := queryFields(ctx, rdb, indexName, opts.ReturnFields)
+	if err != nil {
+		return nil, err
+	}
+	// 原文压缩的知识库没有 content 全文索引
+	if opts.SearchMode != SearchModeVector {
+		compressed, err := contentCompressed(ctx, rdb, indexName)
+		if err != nil {
+			return nil, err
+		}
+		if compressed {
+			return nil, fmt.Errorf("search mode %s on compressed index %s: %w", opts.SearchMode, indexName, ErrUnsupportedByStore)
+		}
+	}
+	// 按索引创建时的距离度量把向量距离换算为相似度
+	metric, err := redisPkg.GetIndexDistanceMetric(ctx, rdb, indexName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index distance metric: %w", err)
+	}
 
 	retrieverConfig := &redisRetriever.RetrieverConfig{
-		Client:       rdb,
-		Index:        indexName,
-		Dialect:      2,
-		ReturnFields: []string{"content", "metadata", "distance"},
-		TopK:         5,
-		VectorField:  "vector",
-		DocumentConverter: func(ctx context.Context, doc redisCli.Document) (*schema.Document, error) {
-			resp := &schema.Document{
-				ID:       doc.ID,
-				Content:  "",
-				MetaData: map[string]any{},
-			}
-			for field, val := range doc.Fields {
-				if field == "content" {
-					resp.Content = val
-				} else {
-					resp.MetaData[field] = val
-				}
-			}
-			return resp, nil
-		},
+		Client:            rdb,
+		Index:             indexName,
+		Dialect:           opts.Dialect,
+		ReturnFields:      append(append([]string{}, fields...), "distance"),
+		TopK:              opts.TopK,
+		VectorField:       "vector",
+		DocumentConverter: withSimilarityScore(metric, convert),
 	}
 	retrieverConfig.Embedding = embedder
 
@@ -234,40 +834,271 @@ func NewRAGQuery(ctx context.Context, username string) (*RAGQuery, error) {
 		return nil, fmt.Errorf("failed to create retriever: %w", err)
 	}
 
-	return &RAGQuery{
-		embedding: embedder,
+	q.embedding = embedder
+	q.indexName = indexName
+	keyPrefix, err := currentDocKeyPrefix(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
+	q.store = &redisVectorStore{
 		retriever: rtr,
-	}, nil
+		client:    rdb,
+		indexName: indexName,
+		keyPrefix: keyPrefix,
+	}
+	q.returnFields = fields
+	q.convert = convert
+	q.client = rdb
+	return q, nil
 }
 
-// RetrieveDocuments 检索相关文档
-func (r *RAGQuery) RetrieveDocuments(ctx context.Context, query string) ([]*schema.Document, error) {
-	docs, err := r.retriever.Retrieve(ctx, query)
+// 检索时需要从 Redis 中取回的字段（向量检索额外返回 distance）
+var returnFields = []string{"content", "metadata", "chunk_index", "page", "heading", "lang", "title", "prev_id", "next_id"}
+
+// 默认字段中需要解析为整数的字段
+var intFields = map[string]bool{"chunk_index": true, "page": true}
+
+// queryFields 返回检索时需要取回的字段和对应的文档转换函数
+// extra 中的字段必须在索引结构中，NUMERIC 类型的字段转换时解析为 float64
+func queryFields(ctx context.Context, client *redisCli.Client, indexName string, extra []string) ([]string, func(context.Context, redisCli.Document) (*schema.Document, error), error) {
+	if len(extra) == 0 {
+		return returnFields, convertDocument, nil
+	}
+
+	fieldTypes, err := redisPkg.GetIndexSchema(ctx, client, indexName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
+		return nil, nil, fmt.Errorf("failed to get index schema: %w", err)
 	}
-	return docs, nil
+	fields := append([]string{}, returnFields...)
+	floatFields := make(map[string]bool)
+	for _, field := range extra {
+		typ, ok := fieldTypes[field]
+		if !ok {
+			return nil, nil, fmt.Errorf("return field %q is not in the index schema", field)
+		}
+		if slices.Contains(fields, field) {
+			continue
+		}
+		fields = append(fields, field)
+		if typ == "NUMERIC" {
+			floatFields[field] = true
+		}
+	}
+	convert := func(ctx context.Context, doc redisCli.Document) (*schema.Document, error) {
+		return convertTypedDocument(doc, floatFields)
+	}
+	return fields, convert, nil
 }
 
-// BuildRAGPrompt 构建包含检索文档的提示词
-func BuildRAGPrompt(query string, docs []*schema.Document) string {
-	if len(docs) == 0 {
-		return query
+// convertDocument 将 Redis 检索结果转换为文档
+// 向量检索的得分在 distance 字段中（越小越相似），关键词检索的得分在 doc.Score 中（越大越相关）
+// chunk_index、page 解析为 int，distance 解析为 float64
+func convertDocument(ctx context.Context, doc redisCli.Document) (*schema.Document, error) {
+	return convertTypedDocument(doc, nil)
+}
+
+// convertTypedDocument 与 convertDocument 相同，floatFields 中的字段额外解析为 float64
+// chunk_index、page 等字段的值无法解析为数值时保留原始字符串
+func convertTypedDocument(doc redisCli.Document, floatFields map[string]bool) (*schema.Document, error) {
+	resp := &schema.Document{
+		ID:       doc.ID,
+		Content:  "",
+		MetaData: map[string]any{},
+	}
+	if doc.Score != nil {
+		resp.MetaData["keyword_score"] = *doc.Score
+	}
+	for field, val := range doc.Fields {
+		if isVectorScoreField(field) {
+			continue
+		}
+		switch field {
+		case "content":
+			content, err := decodeContent(val)
+			if err != nil {
+				return nil, err
+			}
+			resp.Content = content
+		case "metadata":
+			// metadata 字段中存的是索引时的 source（文件路径）
+			resp.MetaData[field] = val
+			resp.MetaData["source"] = val
+		default:
+			resp.MetaData[field] = typedValue(field, val, floatFields)
+		}
+	}
+	// 向量距离解析为数值，越小表示越相似
+	if distance, ok, err := decodeDistance(doc.Fields); err != nil {
+		return nil, err
+	} else if ok {
+		resp.MetaData["distance"] = distance
+	}
+	// 支持标题之前写入的文档块没有 title 字段，使用文件名
+	if _, ok := resp.MetaData["title"]; !ok {
+		if source, ok := resp.MetaData["source"].(string); ok && source != "" {
+			resp.MetaData["title"] = fallbackTitle(source)
+		}
 	}
+	return resp, nil
+}
 
-	contextText := ""
-	for i, doc := range docs {
-		contextText += fmt.Sprintf("[文档 %d]: %s\n\n", i+1, doc.Content)
+// typedValue 按字段类型解析字段值
+func typedValue(field, val string, floatFields map[string]bool) any {
+	if intFields[field] {
+		if n, err := strconv.Atoi(val); err == nil {
+			return n
+		}
 	}
+	if floatFields[field] {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return val
+}
+
+// RetrieveOptions 单次检索的配置，零值表示不做额外处理
+type RetrieveOptions struct {
+	// MaxDistance 最大向量距离，距离大于该值的文档会被过滤掉；0 表示不过滤
+	// 只作用于向量检索的结果，关键词检索的结果没有向量距离
+	MaxDistance float64
+	// Filter 元数据过滤条件（字段 -> 值），只检索元数据完全匹配的文档，例如 {"source": "uploads/u/a.md"}
+	// 字段必须在索引结构中，否则返回错误
+	Filter map[string]string
+	// Highlight 关键词检索（keyword / hybrid）时在元数据 highlights 中返回 content 中命中的区间（[]Highlight）
+	Highlight bool
+	// SemanticHighlight 没有关键词高亮的文档（例如纯向量检索的结果）把文档块按句切分后重新向量化，
+	// 在 highlights 中返回与问题最相似的一句；每次检索会多调用一次向量模型
+	SemanticHighlight bool
+	// BypassCache 跳过检索结果缓存（既不读也不写），供对结果时效性敏感的调用方使用
+	BypassCache bool
+	// Language 只检索该语言（zh / en / ...，见 DetectLanguage）的文档块，等同于 Filter 中加上 {"lang": Language}
+	// 空字符串表示不按语言过滤；在支持语言字段之前建立的 Redis 索引需要先用 RebuildIndex 重建
+	Language string
+	// Timeout 单次检索（包括问题向量化、Redis 检索、重排序等）的超时时间，0 表示只受调用方 ctx 的限制
+	// 超时后返回 *RetrievalTimeoutError（errors.Is(err, ErrRetrievalTimeout) 为 true）
+	Timeout time.Duration
+	// WithNeighbors 把每个结果在同一来源中的前一块和后一块（按 chunk_index）拼接到 content 中，
+	// 给模型更完整的上下文；已经作为单独结果返回的块不会重复拼接，元数据 neighbors 中记录拼接进来的文档块 ID
+	WithNeighbors bool
+}
 
-	prompt := fmt.Sprintf(`基于以下参考文档回答用户的问题。如果文档中没有相关信息，请说明无法找到相关信息。
+// RetrieveDocuments 检索相关文档
+// 所有文档都被距离阈值过滤掉时返回空切片而不是错误，调用方可以直接退化为原始问题
+// 配置了 resultCacheTTL 时，相同的问题和检索参数在缓存时间内直接返回缓存的结果，
+// opts.BypassCache 为 true 时跳过缓存；ctx 已经取消时直接返回 ctx.Err()，超出检索限流（queryRateLimit）时返回 *RateLimitError
+func (r *RAGQuery) RetrieveDocuments(ctx context.Context, query string, opts RetrieveOptions) (docs []*schema.Document, err error) {
+	if len(r.public) > 0 && ctx.Value(ownIndexOnlyKey{}) == nil {
+		return r.retrieveWithPublic(ctx, query, opts)
+	}
+	start := time.Now()
+	ctx, span := startSpan(ctx, "rag.RetrieveDocuments",
+		attribute.String("rag.index", r.indexName),
+		attribute.String("rag.search_mode", r.searchMode),
+		attribute.Int("rag.top_k", r.topK))
+	defer func() {
+		span.SetAttributes(attribute.Int("rag.results", len(docs)))
+		endSpan(span, err)
+		observeRetrieval(r.indexName, start, err)
+		if err != nil {
+			logger.Error("rag retrieval failed", "index", r.indexName, "mode", r.searchMode,
+				"latency_ms", time.Since(start).Milliseconds(), "error", err)
+			return
+		}
+		logger.Info("rag retrieval", "index", r.indexName, "mode", r.searchMode,
+			"results", len(docs), "latency_ms", time.Since(start).Milliseconds())
+	}()
 
-参考文档：
-%s
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkRateLimit(ctx, RateLimitQuery, r.username); err != nil {
+		return nil, err
+	}
+	if opts.Timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = opts.withTimeout(ctx)
+		defer cancel()
+		// 先于上面记录日志和指标的 defer 执行，日志中记录的是转换后的错误
+		defer func() { err = retrievalTimeoutError(parent, ctx, opts.Timeout, err) }()
+	}
 
-用户问题：%s
+	query = normalizeText(query, r.normalize)
+	if opts.Language != "" {
+		filter := make(map[string]string, len(opts.Filter)+1)
+		for k, v := range opts.Filter {
+			filter[k] = v
+		}
+		filter["lang"] = opts.Language
+		opts.Filter = filter
+	}
+	cacheKey := ""
+	ttl := resultCacheTTL()
+	if ttl > 0 && !opts.BypassCache {
+		if version, err := resultCacheVersion(ctx, r.indexName); err != nil {
+			logger.Warn("result cache version lookup failed", "index", r.indexName, "error", err)
+		} else {
+			cacheKey = r.resultCacheKey(version, query, opts)
+			if cached, ok := cachedResult(ctx, cacheKey); ok {
+				span.SetAttributes(attribute.Bool("rag.cache_hit", true))
+				return cached, nil
+			}
+		}
+	}
 
-请提供准确、完整的回答：`, contextText, query)
+	if r.expandQuery {
+		docs, err = r.multiSearch(ctx, r.expandQueries(ctx, query), opts)
+	} else {
+		docs, err = r.search(ctx, query, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.mmr {
+		docs, err = r.selectMMR(ctx, query, docs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.rerank {
+		reranked, rerankErr := Rerank(ctx, query, docs)
+		switch {
+		case rerankErr == nil:
+			docs = reranked
+		case degradeOnModelFailure(ctx):
+			// 降级：保留向量检索的顺序，结果不缓存，重排序服务恢复后重新检索
+			logger.Warn("rag rerank failed, using vector-ranked order", "index", r.indexName, "error", rerankErr)
+			cacheKey = ""
+		default:
+			return nil, fmt.Errorf("failed to rerank documents: %w", rerankErr)
+		}
+	}
+	if opts.SemanticHighlight {
+		if err := r.highlightSentences(ctx, query, docs); err != nil {
+			return nil, err
+		}
+	}
+	// 高亮只针对命中的文档块，所以在拼接之前计算
+	if opts.WithNeighbors {
+		if err := r.attachNeighbors(ctx, docs); err != nil {
+			return nil, err
+		}
+	}
+	r.tagIndexOwner(docs)
+	if cacheKey != "" {
+		storeResult(ctx, cacheKey, docs, ttl)
+	}
+	return docs, nil
+}
 
-	return prompt
+// filterByDistance 保留距离不超过 maxDistance 的文档，没有距离信息的文档会被丢弃
+func filterByDistance(docs []*schema.Document, maxDistance float64) []*schema.Document {
+	filtered := make([]*schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		if distance, ok := doc.MetaData["distance"].(float64); ok && distance <= maxDistance {
+			filtered = append(filtered, doc)
+		}
+	}
+	return filtered
 }
@@ -0,0 +1,400 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// newTestRedis 启动一个 miniredis，commands 中的命令（例如 FT.INFO）替换为测试提供的实现，
+// miniredis 本身不支持 RediSearch
+func newTestRedis(t *testing.T, commands map[string]server.Cmd) *redisCli.Client {
+	t.Helper()
+	m := miniredis.RunT(t)
+	for name, cmd := range commands {
+		if err := m.Server().Register(name, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := redisCli.NewClient(&redisCli.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// ftInfo 返回 FT.INFO 的实现，schema 为字段名 -> 类型，其它索引名返回“索引不存在”
+func ftInfo(indexName string, schema map[string]string) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) != 1 || args[0] != indexName {
+			c.WriteError("Unknown index name")
+			return
+		}
+		names := make([]string, 0, len(schema))
+		for name := range schema {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		c.WriteLen(4)
+		c.WriteBulk("index_name")
+		c.WriteBulk(indexName)
+		c.WriteBulk("attributes")
+		c.WriteLen(len(names))
+		for _, name := range names {
+			c.WriteStrings([]string{"identifier", name, "attribute", name, "type", schema[name]})
+		}
+	}
+}
+
+func chunkDocs(source string, contents ...string) []*schema.Document {
+	docs := make([]*schema.Document, len(contents))
+	for i, c := range contents {
+		docs[i] = &schema.Document{Content: c, MetaData: map[string]any{
+			"source":       source,
+			"chunk_index":  i,
+			"content_hash": contentHash(c),
+		}}
+	}
+	return linkChunks(docs)
+}
+
+func TestNewChunkID(t *testing.T) {
+	base := newChunkID("a.md", contentHash("alpha"), 0)
+
+	tests := []struct {
+		name   string
+		source string
+		hash   string
+		occ    int
+		same   bool // 是否应与 base 相同
+	}{
+		{"deterministic", "a.md", contentHash("alpha"), 0, true},
+		{"other source", "b.md", contentHash("alpha"), 0, false},
+		{"other content", "a.md", contentHash("beta"), 0, false},
+		{"repeated content", "a.md", contentHash("alpha"), 1, false},
+		{"source prefix", "a.m", contentHash("alpha"), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := newChunkID(tt.source, tt.hash, tt.occ)
+			parsed, err := uuid.Parse(id)
+			if err != nil {
+				t.Fatalf("id %q is not a uuid: %v", id, err)
+			}
+			if parsed.Version() != 5 {
+				t.Errorf("id %q has version %d, want 5", id, parsed.Version())
+			}
+			if (id == base) != tt.same {
+				t.Errorf("newChunkID(%q, %q, %d) = %s, base %s, want same=%v", tt.source, tt.hash, tt.occ, id, base, tt.same)
+			}
+		})
+	}
+}
+
+func TestLinkChunksStableIDs(t *testing.T) {
+	before := chunkDocs("a.md", "alpha", "beta", "gamma")
+
+	tests := []struct {
+		name    string
+		after   []*schema.Document
+		kept    map[int]int // before 中的下标 -> after 中的下标，这些块的 ID 应该保持不变
+		changed []int       // after 中 ID 应该是新的块
+	}{
+		{"unchanged", chunkDocs("a.md", "alpha", "beta", "gamma"), map[int]int{0: 0, 1: 1, 2: 2}, nil},
+		{"insert at start", chunkDocs("a.md", "intro", "alpha", "beta", "gamma"), map[int]int{0: 1, 1: 2, 2: 3}, []int{0}},
+		{"delete middle", chunkDocs("a.md", "alpha", "gamma"), map[int]int{0: 0, 2: 1}, nil},
+		{"edit middle", chunkDocs("a.md", "alpha", "beta v2", "gamma"), map[int]int{0: 0, 2: 2}, []int{1}},
+		{"other source", chunkDocs("b.md", "alpha", "beta", "gamma"), nil, []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for from, to := range tt.kept {
+				if before[from].ID != tt.after[to].ID {
+					t.Errorf("chunk %q: id changed from %s to %s", before[from].Content, before[from].ID, tt.after[to].ID)
+				}
+			}
+			for _, i := range tt.changed {
+				for _, old := range before {
+					if old.ID == tt.after[i].ID {
+						t.Errorf("chunk %q reused id %s of %q", tt.after[i].Content, old.ID, old.Content)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLinkChunksDuplicatesAndNeighbors(t *testing.T) {
+	docs := chunkDocs("a.md", "same", "other", "same")
+	if docs[0].ID == docs[2].ID {
+		t.Fatalf("duplicate contents share id %s", docs[0].ID)
+	}
+
+	tests := []struct {
+		i          int
+		prev, next string
+	}{
+		{0, "", docs[1].ID},
+		{1, docs[0].ID, docs[2].ID},
+		{2, docs[1].ID, ""},
+	}
+	for _, tt := range tests {
+		prev, next := neighborIDs(docs[tt.i])
+		if prev != tt.prev || next != tt.next {
+			t.Errorf("neighborIDs(docs[%d]) = (%q, %q), want (%q, %q)", tt.i, prev, next, tt.prev, tt.next)
+		}
+	}
+}
+
+func TestQueryFields(t *testing.T) {
+	client := newTestRedis(t, map[string]server.Cmd{
+		"FT.INFO": ftInfo("idx", map[string]string{
+			"content": "TEXT", "metadata": "TEXT", "author": "TAG", "year": "NUMERIC", "vector": "VECTOR",
+		}),
+	})
+
+	tests := []struct {
+		name      string
+		extra     []string
+		wantExtra []string // 默认字段之后追加的字段
+		wantErr   bool
+	}{
+		{"defaults", nil, nil, false},
+		{"tag field", []string{"author"}, []string{"author"}, false},
+		{"numeric field", []string{"year", "author"}, []string{"year", "author"}, false},
+		{"default field not repeated", []string{"content", "author"}, []string{"author"}, false},
+		{"unknown field", []string{"missing"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, _, err := queryFields(context.Background(), client, "idx", tt.extra)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(slices.Clone(returnFields), tt.wantExtra...)
+			if !slices.Equal(fields, want) {
+				t.Errorf("fields = %v, want %v", fields, want)
+			}
+		})
+	}
+}
+
+func TestQueryFieldsTypedMetadata(t *testing.T) {
+	client := newTestRedis(t, map[string]server.Cmd{
+		"FT.INFO": ftInfo("idx", map[string]string{"author": "TAG", "year": "NUMERIC"}),
+	})
+	_, convert, err := queryFields(context.Background(), client, "idx", []string{"author", "year"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := convert(context.Background(), redisCli.Document{ID: "doc:1", Fields: map[string]string{
+		"content":     "text",
+		"metadata":    "/uploads/alice/a.md",
+		"chunk_index": "3",
+		"page":        "n/a",
+		"author":      "2020",
+		"year":        "2020",
+		"distance":    "0.25",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field string
+		want  any
+	}{
+		{"chunk_index", 3},
+		{"page", "n/a"}, // 无法解析为数值时保留原始字符串
+		{"author", "2020"},
+		{"year", 2020.0},
+		{"distance", 0.25},
+		{"source", "/uploads/alice/a.md"},
+		{"title", "a.md"},
+	}
+	for _, tt := range tests {
+		if got := doc.MetaData[tt.field]; got != tt.want {
+			t.Errorf("MetaData[%q] = %#v, want %#v", tt.field, got, tt.want)
+		}
+	}
+	if doc.Content != "text" {
+		t.Errorf("Content = %q, want %q", doc.Content, "text")
+	}
+}
+
+func TestNewRAGQueryIndexNotFound(t *testing.T) {
+	SetLogger(nil)
+	client := newTestRedis(t, map[string]server.Cmd{"FT.INFO": ftInfo("other_idx", nil)})
+	oldRdb := redisPkg.Rdb
+	redisPkg.Rdb = client
+	t.Cleanup(func() { redisPkg.Rdb = oldRdb })
+
+	base := t.TempDir()
+	for _, dir := range []string{"empty", "dirs/sub", "alice"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(base, "alice", "notes.md"), []byte("# notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		username     string
+		store        string
+		wantNoUpload bool // 是否为 ErrNoUploadedFile；所有情况都应为 ErrIndexNotFound
+	}{
+		{"no upload dir", "nobody", VectorStoreRedis, true},
+		{"empty upload dir", "empty", VectorStoreRedis, true},
+		{"only directories", "dirs", VectorStoreRedis, true},
+		{"redis index missing", "alice", VectorStoreRedis, false},
+		{"memory index missing", "alice", VectorStoreMemory, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &config.Config{}
+			conf.UploadDir = base
+			conf.RagModelConfig.RagVectorStore = tt.store
+			config.SetConfig(conf)
+
+			q, err := NewRAGQuery(context.Background(), tt.username, QueryOptions{})
+			if q != nil {
+				t.Fatalf("NewRAGQuery returned a query for %s", tt.username)
+			}
+			if !errors.Is(err, ErrIndexNotFound) {
+				t.Fatalf("err = %v, want ErrIndexNotFound", err)
+			}
+			if !errors.Is(err, redisPkg.ErrIndexNotFound) {
+				t.Errorf("err = %v, want redis.ErrIndexNotFound", err)
+			}
+			if errors.Is(err, ErrNoUploadedFile) != tt.wantNoUpload {
+				t.Errorf("errors.Is(%v, ErrNoUploadedFile) = %v, want %v", err, !tt.wantNoUpload, tt.wantNoUpload)
+			}
+		})
+	}
+}
+
+func TestIndexFileEmptyDocuments(t *testing.T) {
+	SetLogger(nil)
+	config.SetConfig(&config.Config{})
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		filename   string
+		data       []byte
+		wantStored int
+		wantErr    error
+	}{
+		{"empty", "empty.txt", nil, 0, ErrEmptyDocument},
+		{"spaces and newlines", "blank.txt", []byte("  \n\t\r\n  "), 0, ErrEmptyDocument},
+		{"bom only", "bom.txt", []byte("\uFEFF\n"), 0, ErrEmptyDocument},
+		{"only newlines", "blank.md", []byte("\n\n\n\n"), 0, ErrEmptyDocument},
+		{"pdf without text", "scan.pdf", simplePDF(false, "").bytes(1), 0, ErrNoChunksIndexed},
+		{"text", "notes.txt", []byte("hello world"), 1, nil},
+		{"text surrounded by blank lines", "notes.md", []byte("\n\n# Title\n\nbody\n\n\n"), 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.filename)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			store := NewMemoryVectorStore(&fakeEmbedder{})
+			r := &RAGIndexer{store: store, indexName: "test", batchSize: defaultBatchSize}
+
+			stored, err := r.IndexFile(context.Background(), path, IndexOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IndexFile err = %v, want %v", err, tt.wantErr)
+			}
+			if stored != tt.wantStored || len(store.data.docs) != tt.wantStored {
+				t.Errorf("stored %d (store holds %d), want %d", stored, len(store.data.docs), tt.wantStored)
+			}
+		})
+	}
+}
+
+// 两个用户上传了同名文件，检索时只能看到自己的文档块
+func TestRetrieveDocumentsIsolatedByUser(t *testing.T) {
+	SetLogger(nil)
+	base := t.TempDir()
+	conf := &config.Config{}
+	conf.UploadDir = base
+	conf.RagModelConfig.RagVectorStore = VectorStoreMemory
+	conf.RagModelConfig.RagEmbeddingProvider = "fake"
+	conf.RagModelConfig.RagDimension = 2
+	config.SetConfig(conf)
+	embedderFactories["fake"] = func(context.Context, EmbedderConfig) (embedding.Embedder, error) {
+		return &fakeEmbedder{}, nil
+	}
+	oldRdb := redisPkg.Rdb
+	redisPkg.Rdb = nil
+	t.Cleanup(func() {
+		delete(embedderFactories, "fake")
+		redisPkg.Rdb = oldRdb
+	})
+
+	ctx := context.Background()
+	contents := map[string]string{
+		"alice": "alice secret: the launch is on monday",
+		"bob":   "bob secret: the budget is ten thousand",
+	}
+	for username, content := range contents {
+		dir := filepath.Join(base, username)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "shared.md")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		indexer, err := NewRAGIndexer(ctx, username, "shared.md", "fake-model", 0)
+		if err != nil {
+			t.Fatalf("NewRAGIndexer(%s): %v", username, err)
+		}
+		if _, err := indexer.IndexFile(ctx, path, IndexOptions{}); err != nil {
+			t.Fatalf("IndexFile(%s): %v", username, err)
+		}
+		indexer.Close()
+		t.Cleanup(func() { DeleteIndex(ctx, username, "shared.md") })
+	}
+
+	for username, own := range contents {
+		t.Run(username, func(t *testing.T) {
+			q, err := NewRAGQuery(ctx, username, QueryOptions{TopK: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.Close()
+			docs, err := q.RetrieveDocuments(ctx, "secret", RetrieveOptions{BypassCache: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) == 0 {
+				t.Fatal("no documents retrieved")
+			}
+			for _, doc := range docs {
+				if doc.Content != own {
+					t.Errorf("%s retrieved %q", username, doc.Content)
+				}
+			}
+		})
+	}
+}
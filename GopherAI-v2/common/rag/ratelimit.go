@@ -0,0 +1,76 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 限流：每个用户的检索和索引分别使用一个令牌桶（配置项 queryRateLimit / indexRateLimit，单位为次/分钟），
+// 计数保存在 Redis 中，多个实例共享同一个额度。检索在 RetrieveDocuments 入口处计数（RetrieveBatch 中每个问题计一次），
+// 索引由上传入口在删除旧文件之前调用 CheckIndexRateLimit 计数，被限流的上传不会影响已有的知识库；
+// Redis 不可用时只记录日志并放行，不因为限流本身的故障拒绝请求。
+
+// ErrRateLimited 请求过于频繁，可通过 errors.Is 判断，需要等待的时间见 *RateLimitError
+var ErrRateLimited = errors.New("rate limited")
+
+// 限流的操作
+const (
+	RateLimitQuery = "query"
+	RateLimitIndex = "index"
+)
+
+// RateLimitError 超出限流的操作、每分钟的上限和距离下次允许请求的等待时间
+type RateLimitError struct {
+	Operation  string
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit of %d per minute exceeded, retry after %s", e.Operation, e.Limit, e.RetryAfter)
+}
+
+// Is 使 errors.Is(err, ErrRateLimited) 成立
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// CheckIndexRateLimit 为用户的一次索引（上传）计数，超出 indexRateLimit 时返回 *RateLimitError
+func CheckIndexRateLimit(ctx context.Context, username string) error {
+	return checkRateLimit(ctx, RateLimitIndex, username)
+}
+
+// checkRateLimit 为用户的 operation 取走一个令牌，超出限制时返回 *RateLimitError
+func checkRateLimit(ctx context.Context, operation, username string) error {
+	limit := rateLimitFor(operation)
+	if limit <= 0 || redisPkg.Rdb == nil || username == "" {
+		return nil
+	}
+	wait, err := redisPkg.TakeRateLimitToken(ctx, redisPkg.GenerateRateLimit(operation, username), limit, 1)
+	if err != nil {
+		logger.Warn("rag rate limit check failed", "operation", operation, "username", username, "error", err)
+		return nil
+	}
+	if wait > 0 {
+		logger.Warn("rag rate limited", "operation", operation, "username", username,
+			"limit", limit, "retry_after_ms", wait.Milliseconds())
+		return &RateLimitError{Operation: operation, Limit: limit, RetryAfter: wait}
+	}
+	return nil
+}
+
+// rateLimitFor 返回操作每分钟的上限，0 表示不限制
+func rateLimitFor(operation string) int {
+	conf := config.GetConfig().RagModelConfig
+	switch operation {
+	case RateLimitQuery:
+		return conf.RagQueryRateLimit
+	case RateLimitIndex:
+		return conf.RagIndexRateLimit
+	}
+	return 0
+}
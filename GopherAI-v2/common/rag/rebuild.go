@@ -0,0 +1,242 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// RebuildIndex 用新的向量模型重建知识库，返回重新向量化的文档块数（管理员操作）
+// 更换向量模型后，旧模型生成的向量不能再与新模型向量化的问题比较，需要重建：
+//  1. 从 Redis 中读出当前这一代所有文档块的原文（content 字段）和元数据
+//  2. 按新模型的维度创建下一代索引，重新向量化后写入新的 key 前缀
+//  3. 全部写完后在一个事务中把知识库的索引名切换到新索引，并删除旧索引和旧数据
+//
+// 切换之前检索仍然使用旧索引，不会看到建了一半的索引；中途失败时删除新索引和已写入的数据，旧索引保持不变。
+// newModel 与主向量模型使用同一家服务（相同的地址和密钥），progress 在每批写入后回调，可以为 nil。
+// 重建期间新写入旧索引的文档块不会进入新索引，调用方需要暂停该知识库的上传。
+// 只有 Redis 存储支持重建，旧版本只按文件名创建的索引需要重新上传。
+func RebuildIndex(ctx context.Context, username, filename, newModel string, progress ProgressFunc) (int, error) {
+	if newModel == "" {
+		return 0, fmt.Errorf("new embedding model is required")
+	}
+	backend, err := vectorStoreBackend()
+	if err != nil {
+		return 0, err
+	}
+	if backend == VectorStoreMemory {
+		return 0, fmt.Errorf("rebuild index: %w", ErrUnsupportedByStore)
+	}
+
+	indexName := redisPkg.GenerateIndexName(username, filename)
+	resolved, err := redisPkg.ResolveIndexName(ctx, username, filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve index name: %w", err)
+	}
+	if resolved != indexName {
+		return 0, fmt.Errorf("rebuild legacy index %s: %w", resolved, ErrUnsupportedByStore)
+	}
+	exists, err := redisPkg.IndexExists(ctx, indexName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check index: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("%s: %w", indexName, ErrIndexNotFound)
+	}
+
+	client, err := redisPkg.IndexClient(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	oldGen, err := redisPkg.IndexGeneration(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	newGen := oldGen + 1
+
+	// 新模型的维度以实际输出为准，配置了 reducedDimension 时截断到这个维度
+	choice := newEmbedderChoice(embedderConfigFromConfig(newModel))
+	if reduced := config.GetConfig().RagModelConfig.RagReducedDimension; reduced > 0 {
+		if err := checkReducedDimension(newModel, reduced); err != nil {
+			return 0, err
+		}
+		choice.cfg.Dimensions = reduced
+	}
+	embedder, err := buildEmbedder(ctx, choice, indexName, OperationIndex)
+	if err != nil {
+		return 0, err
+	}
+	probe, err := embedder.EmbedStrings(ctx, []string{dimensionProbeText})
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe embedding dimension: %w", err)
+	}
+	if len(probe) != 1 || len(probe[0]) == 0 {
+		return 0, fmt.Errorf("failed to probe embedding dimension: got %d vectors for 1 text", len(probe))
+	}
+	dimension := len(probe[0])
+
+	oldPrefix := docKeyPrefix(username, filename, oldGen)
+	keys, err := scanKeys(ctx, client, escapeGlob(oldPrefix)+"*")
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	logger.Info("rag index rebuild started", "index", indexName, "embedder", choice.id,
+		"dimension", dimension, "generation", newGen, "chunks", len(keys))
+	if err := redisPkg.CreateGenerationIndex(ctx, username, filename, newGen, dimension, vectorIndexOptions()); err != nil {
+		return 0, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	var done int
+	err = copyExtraTagFields(ctx, client, indexName, redisPkg.GenerationIndexName(username, filename, newGen))
+	if err == nil {
+		// 新索引按当前配置决定是否压缩原文
+		compress := vectorIndexOptions().NoContentText
+		done, err = reembedChunks(ctx, client, embedder, keys, oldPrefix, docKeyPrefix(username, filename, newGen), compress, progress)
+	}
+	if err == nil {
+		err = redisPkg.SwapIndexGeneration(ctx, username, filename, oldGen, newGen)
+	}
+	if err != nil {
+		// 回滚：删除新索引和已经写入的数据，旧索引不受影响；ctx 可能已经取消，回滚不跟随 ctx
+		if dropErr := redisPkg.DropGenerationIndex(context.WithoutCancel(ctx), username, filename, newGen); dropErr != nil {
+			logger.Error("rag index rebuild rollback failed", "index", indexName, "generation", newGen, "error", dropErr)
+		}
+		logger.Error("rag index rebuild failed", "index", indexName, "rebuilt", done, "total", len(keys), "error", err)
+		return 0, fmt.Errorf("failed to rebuild index: %w", err)
+	}
+
+	// 之后写入和检索都使用新模型
+	if err := recordIndexEmbedder(ctx, indexName, choice.id); err != nil {
+		return 0, err
+	}
+	invalidateResultCache(ctx, indexName)
+	// 池中的查询器还在使用旧模型和旧索引的 key 前缀
+	invalidatePooledQueries(username, filename)
+	logger.Info("rag index rebuilt", "index", indexName, "embedder", choice.id, "generation", newGen,
+		"chunks", done, "latency_ms", time.Since(start).Milliseconds())
+	return done, nil
+}
+
+// copyExtraTagFields 把旧索引中后来添加的 TAG 字段（例如 IndexJSON 的元数据字段）加到新索引中，重建后仍然可以按这些字段过滤
+func copyExtraTagFields(ctx context.Context, client *redisCli.Client, oldIndex, newIndex string) error {
+	fieldTypes, err := redisPkg.GetIndexSchema(ctx, client, oldIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get index schema: %w", err)
+	}
+	var extra []string
+	for field, typ := range fieldTypes {
+		if typ == "TAG" {
+			extra = append(extra, field)
+		}
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	sort.Strings(extra)
+	// 新索引中已有的字段（lang 等）会被跳过
+	return redisPkg.AddIndexTagFields(ctx, client, newIndex, extra)
+}
+
+// reembedChunks 分批读出旧文档块，用 embedder 重新向量化 content 后写入新前缀下的同名 key
+// 除 vector 以外的字段原样复制，content 按 compress 重新压缩或解压，返回写入的文档块数
+func reembedChunks(ctx context.Context, client *redisCli.Client, embedder embedding.Embedder,
+	keys []string, oldPrefix, newPrefix string, compress bool, progress ProgressFunc) (int, error) {
+	done := 0
+	for start := 0; start < len(keys); start += defaultBatchSize {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		batch := keys[start:min(start+defaultBatchSize, len(keys))]
+
+		pipe := client.Pipeline()
+		cmds := make([]*redisCli.MapStringStringCmd, len(batch))
+		for i, key := range batch {
+			cmds[i] = pipe.HGetAll(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return done, fmt.Errorf("failed to read chunks: %w", err)
+		}
+
+		var chunks []map[string]string
+		var texts []string
+		var newKeys []string
+		for i, cmd := range cmds {
+			fields := cmd.Val()
+			// 扫描之后被删除的文档块
+			if len(fields) == 0 {
+				continue
+			}
+			delete(fields, "vector")
+			text, err := decodeContent(fields["content"])
+			if err != nil {
+				return done, err
+			}
+			fields["content"] = encodeContent(text, compress)
+			// 支持语言字段之前写入的文档块补上语言
+			if _, ok := fields["lang"]; !ok {
+				fields["lang"] = DetectLanguage(text)
+			}
+			// 支持标题字段之前写入的文档块使用文件名作为标题
+			if _, ok := fields["title"]; !ok && fields["metadata"] != "" {
+				fields["title"] = fallbackTitle(fields["metadata"])
+			}
+			chunks = append(chunks, fields)
+			texts = append(texts, text)
+			newKeys = append(newKeys, newPrefix+batch[i][len(oldPrefix):])
+		}
+		if len(chunks) == 0 {
+			continue
+		}
+
+		vectors, err := embedder.EmbedStrings(ctx, texts)
+		if err != nil {
+			return done, fmt.Errorf("failed to embed chunks: %w", err)
+		}
+		if len(vectors) != len(texts) {
+			return done, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+		}
+
+		pipe = client.Pipeline()
+		for i, fields := range chunks {
+			values := make([]interface{}, 0, 2*len(fields)+2)
+			for k, v := range fields {
+				values = append(values, k, v)
+			}
+			values = append(values, "vector", encodeVector(vectors[i]))
+			pipe.HSet(ctx, newKeys[i], values...)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return done, fmt.Errorf("failed to store chunks: %w", err)
+		}
+		done += len(chunks)
+		if progress != nil {
+			progress(done, len(keys))
+		}
+	}
+	return done, nil
+}
+
+// scanKeys 返回匹配 pattern 的所有 key
+func scanKeys(ctx context.Context, client *redisCli.Client, pattern string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := client.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chunks: %w", err)
+		}
+		keys = append(keys, batch...)
+		cursor = next
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
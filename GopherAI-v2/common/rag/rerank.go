@@ -0,0 +1,122 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const defaultRerankTimeout = 10 * time.Second
+
+// rerankRequest 重排序接口请求体（Cohere / Jina 风格的 /rerank 接口）
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// Rerank 调用交叉编码器（cross-encoder）重排序模型，按与问题的相关度从高到低重新排列文档
+// 每个文档的元数据中会新增 rerank_score 字段，原有的 distance 等字段保持不变
+// 重排序服务地址和模型在 ragModelConfig 的 rerankBaseUrl / rerankModel 中配置
+func Rerank(ctx context.Context, query string, docs []*schema.Document) ([]*schema.Document, error) {
+	if len(docs) == 0 {
+		return docs, nil
+	}
+
+	cfg := config.GetConfig().RagModelConfig
+	if cfg.RagRerankBaseUrl == "" || cfg.RagRerankModel == "" {
+		return nil, fmt.Errorf("reranker is not configured")
+	}
+
+	contents := make([]string, len(docs))
+	for i, doc := range docs {
+		contents[i] = doc.Content
+	}
+	bodyBytes, err := json.Marshal(rerankRequest{
+		Model:     cfg.RagRerankModel,
+		Query:     query,
+		Documents: contents,
+		TopN:      len(docs),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimRight(cfg.RagRerankBaseUrl, "/") + "/rerank"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey())
+
+	resp, err := rerankClient(cfg).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call reranker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reranker returned status %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result rerankResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode rerank response: %w", err)
+	}
+
+	// 按相关度从高到低排列；重排序服务没有返回的文档放在最后，保持原有顺序
+	sort.SliceStable(result.Results, func(i, j int) bool {
+		return result.Results[i].RelevanceScore > result.Results[j].RelevanceScore
+	})
+	reranked := make([]*schema.Document, 0, len(docs))
+	seen := make([]bool, len(docs))
+	for _, r := range result.Results {
+		if r.Index < 0 || r.Index >= len(docs) || seen[r.Index] {
+			continue
+		}
+		seen[r.Index] = true
+		doc := docs[r.Index]
+		if doc.MetaData == nil {
+			doc.MetaData = map[string]any{}
+		}
+		doc.MetaData["rerank_score"] = r.RelevanceScore
+		reranked = append(reranked, doc)
+	}
+	for i, doc := range docs {
+		if !seen[i] {
+			reranked = append(reranked, doc)
+		}
+	}
+	return reranked, nil
+}
+
+// rerankClient 调用重排序模型用的 HTTP 客户端，超时时间在 ragModelConfig 的 rerankTimeout 中配置
+func rerankClient(cfg config.RagModelConfig) *http.Client {
+	timeout := defaultRerankTimeout
+	if cfg.RagRerankTimeout > 0 {
+		timeout = time.Duration(cfg.RagRerankTimeout) * time.Millisecond
+	}
+	return &http.Client{Timeout: timeout}
+}
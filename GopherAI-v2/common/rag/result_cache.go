@@ -0,0 +1,132 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 检索结果缓存：相同的问题和检索参数在缓存时间内直接返回上一次的检索结果，
+// 不再调用向量模型、Redis 检索和重排序模型。
+// 缓存 key 中包含知识库的版本号，知识库内容变化（写入、增量更新、删除文档块）时递增版本号，
+// 旧版本的缓存不会再被读到，等待过期即可，不需要逐个删除。
+// 只有配置了 resultCacheTTL 且使用 Redis 时才启用。
+
+// resultCacheTTL 返回检索结果的缓存时间，0 表示不缓存
+func resultCacheTTL() time.Duration {
+	if redisPkg.Rdb == nil {
+		return 0
+	}
+	return time.Duration(config.GetConfig().RagModelConfig.RagResultCacheTTL) * time.Second
+}
+
+// resultCacheParams 决定检索结果的所有参数，序列化后作为缓存 key 的一部分
+type resultCacheParams struct {
+	Version           int64             `json:"version"`
+	Query             string            `json:"query"`
+	TopK              int               `json:"top_k"`
+	SearchMode        string            `json:"search_mode"`
+	MaxDistance       float64           `json:"max_distance"`
+	Filter            map[string]string `json:"filter,omitempty"` // encoding/json 按 key 排序输出
+	Highlight         bool              `json:"highlight"`
+	SemanticHighlight bool              `json:"semantic_highlight"`
+	ExpandQuery       bool              `json:"expand_query"`
+	QueryVariants     int               `json:"query_variants"`
+	MMR               bool              `json:"mmr"`
+	MMRLambda         float64           `json:"mmr_lambda"`
+	MMRCandidates     int               `json:"mmr_candidates"`
+	Rerank            bool              `json:"rerank"`
+	ReturnFields      []string          `json:"return_fields"`
+	WithNeighbors     bool              `json:"with_neighbors"`
+}
+
+// normalizeQuery 去掉首尾空白并合并连续空白，大小写不同的问题视为不同的问题
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// resultCacheKey 计算一次检索的缓存 key
+func (r *RAGQuery) resultCacheKey(version int64, query string, opts RetrieveOptions) string {
+	fields := append([]string(nil), r.returnFields...)
+	sort.Strings(fields)
+	params, _ := json.Marshal(resultCacheParams{
+		Version:           version,
+		Query:             normalizeQuery(query),
+		TopK:              r.topK,
+		SearchMode:        r.searchMode,
+		MaxDistance:       opts.MaxDistance,
+		Filter:            opts.Filter,
+		Highlight:         opts.Highlight,
+		SemanticHighlight: opts.SemanticHighlight,
+		ExpandQuery:       r.expandQuery,
+		QueryVariants:     r.queryVariants,
+		MMR:               r.mmr,
+		MMRLambda:         r.mmrLambda,
+		MMRCandidates:     r.mmrCandidates,
+		Rerank:            r.rerank,
+		ReturnFields:      fields,
+		WithNeighbors:     opts.WithNeighbors,
+	})
+	sum := sha256.Sum256(params)
+	return redisPkg.GenerateResultCacheKey(r.indexName, hex.EncodeToString(sum[:]))
+}
+
+// resultCacheVersion 读取知识库当前的缓存版本号，还没有写入过时为 0
+func resultCacheVersion(ctx context.Context, indexName string) (int64, error) {
+	version, err := redisPkg.Rdb.Get(ctx, redisPkg.GenerateResultCacheVersion(indexName)).Int64()
+	if err == redisCli.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// invalidateResultCache 递增知识库的缓存版本号，使已缓存的检索结果全部失效
+// 失败只记录日志：最坏情况下旧的结果在缓存过期前仍会被返回
+func invalidateResultCache(ctx context.Context, indexName string) {
+	if redisPkg.Rdb == nil {
+		return
+	}
+	if err := redisPkg.Rdb.Incr(ctx, redisPkg.GenerateResultCacheVersion(indexName)).Err(); err != nil {
+		logger.Warn("result cache invalidation failed", "index", indexName, "error", err)
+	}
+}
+
+// cachedResult 读取缓存的检索结果，ok 为 false 表示未命中
+// 用 gob 而不是 JSON 编码，保证元数据中的 int / float64 等类型在读回后保持不变
+func cachedResult(ctx context.Context, key string) (docs []*schema.Document, ok bool) {
+	data, err := redisPkg.Rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redisCli.Nil {
+			logger.Warn("result cache lookup failed", "error", err)
+		}
+		return nil, false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&docs); err != nil {
+		logger.Warn("result cache decode failed", "error", err)
+		return nil, false
+	}
+	return docs, true
+}
+
+// storeResult 缓存检索结果，失败只记录日志
+func storeResult(ctx context.Context, key string, docs []*schema.Document, ttl time.Duration) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(docs); err != nil {
+		logger.Warn("result cache encode failed", "error", err)
+		return
+	}
+	if err := redisPkg.Rdb.Set(ctx, key, buf.Bytes(), ttl).Err(); err != nil {
+		logger.Warn("result cache store failed", "error", err)
+	}
+}
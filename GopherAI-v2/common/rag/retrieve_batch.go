@@ -0,0 +1,146 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 批量检索时同时执行的检索数
+const defaultRetrieveConcurrency = 4
+
+// queryVectorsKey 在 ctx 中保存预先计算好的问题向量（问题文本 -> 向量）
+type queryVectorsKey struct{}
+
+// withQueryVectors 把预先计算好的问题向量放入 ctx，检索时不再为这些问题调用向量模型
+func withQueryVectors(ctx context.Context, vectors map[string][]float64) context.Context {
+	return context.WithValue(ctx, queryVectorsKey{}, vectors)
+}
+
+// precomputedEmbedder 需要向量化的文本都已经在 ctx 中预先计算好时直接返回，否则调用内层的向量生成器
+type precomputedEmbedder struct {
+	embedder embedding.Embedder
+}
+
+// withPrecomputed 为向量生成器加上预先计算向量的查找（放在最外层，命中时不产生向量化调用和指标）
+func withPrecomputed(embedder embedding.Embedder) embedding.Embedder {
+	return &precomputedEmbedder{embedder: embedder}
+}
+
+func (e *precomputedEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	if known, ok := ctx.Value(queryVectorsKey{}).(map[string][]float64); ok {
+		vectors := make([][]float64, len(texts))
+		for i, text := range texts {
+			vec, ok := known[text]
+			if !ok {
+				return e.embedder.EmbedStrings(ctx, texts, opts...)
+			}
+			vectors[i] = vec
+		}
+		return vectors, nil
+	}
+	return e.embedder.EmbedStrings(ctx, texts, opts...)
+}
+
+func (e *precomputedEmbedder) Close() error {
+	return closeEmbedder(e.embedder)
+}
+
+// RetrieveBatch 一次检索多个问题，结果与 queries 一一对应（顺序相同），每个问题的检索与 RetrieveDocuments 相同
+// 所有问题在一次向量模型调用中向量化，之后最多 4 个检索并发执行；
+// 任意一个问题检索失败时取消其余检索并返回第一个错误
+func (r *RAGQuery) RetrieveBatch(ctx context.Context, queries []string, opts RetrieveOptions) (results [][]*schema.Document, err error) {
+	results = make([][]*schema.Document, len(queries))
+	if len(queries) == 0 {
+		return results, nil
+	}
+	ctx, span := startSpan(ctx, "rag.RetrieveBatch",
+		attribute.String("rag.index", r.indexName),
+		attribute.Int("rag.queries", len(queries)))
+	defer func() { endSpan(span, err) }()
+
+	// 关键词检索不需要问题向量；批量向量化同样受 opts.Timeout 限制
+	if r.searchMode != SearchModeKeyword {
+		embedCtx, cancel := opts.withTimeout(ctx)
+		vectors, err := r.embedQueries(embedCtx, queries)
+		cancel()
+		if err != nil {
+			return nil, retrievalTimeoutError(ctx, embedCtx, opts.Timeout, err)
+		}
+		ctx = withQueryVectors(ctx, vectors)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, defaultRetrieveConcurrency)
+	for i, query := range queries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			docs, err := r.RetrieveDocuments(ctx, query, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("query %d: %w", i, err)
+					cancel()
+				}
+				return
+			}
+			results[i] = docs
+		}(i, query)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// embedQueries 规范化后的问题去重后一次性向量化，返回问题文本 -> 向量
+func (r *RAGQuery) embedQueries(ctx context.Context, queries []string) (map[string][]float64, error) {
+	seen := make(map[string]bool, len(queries))
+	texts := make([]string, 0, len(queries))
+	for _, query := range queries {
+		text := normalizeText(query, r.normalize)
+		if !seen[text] {
+			seen[text] = true
+			texts = append(texts, text)
+		}
+	}
+	vectors, err := r.embedding.EmbedStrings(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed queries: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	known := make(map[string][]float64, len(texts))
+	for i, text := range texts {
+		known[text] = vectors[i]
+	}
+	return known, nil
+}
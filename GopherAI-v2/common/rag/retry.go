@@ -0,0 +1,123 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+)
+
+const (
+	// 默认最多尝试次数（包括第一次调用）
+	defaultRetryMaxAttempts = 3
+	// 第一次重试前的等待时间，之后每次翻倍
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	// 单次等待的上限
+	defaultRetryMaxDelay = 8 * time.Second
+)
+
+// RetryOptions 重试配置，零值表示使用默认配置
+type RetryOptions struct {
+	MaxAttempts int           // 最多尝试次数（包括第一次调用），默认 3
+	BaseDelay   time.Duration // 第一次重试前的等待时间，默认 500ms
+	MaxDelay    time.Duration // 单次等待的上限，默认 8s
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = defaultRetryBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = defaultRetryMaxDelay
+	}
+	return o
+}
+
+// RetryEmbedder 对向量模型的调用做重试
+// 只重试限流（429）、网关错误（502/503）和超时等临时错误，鉴权失败等错误直接返回
+type RetryEmbedder struct {
+	embedder embedding.Embedder
+	opts     RetryOptions
+}
+
+// NewRetryEmbedder 为向量生成器加上指数退避重试
+func NewRetryEmbedder(embedder embedding.Embedder, opts RetryOptions) *RetryEmbedder {
+	return &RetryEmbedder{embedder: embedder, opts: opts.withDefaults()}
+}
+
+// withRetry 按配置为 RAG 使用的向量生成器加上重试
+func withRetry(embedder embedding.Embedder) *RetryEmbedder {
+	return NewRetryEmbedder(embedder, RetryOptions{
+		MaxAttempts: config.GetConfig().RagModelConfig.RagEmbeddingMaxAttempts,
+	})
+}
+
+// EmbedStrings 实现 embedding.Embedder
+func (e *RetryEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	var lastErr error
+	for attempt := 1; attempt <= e.opts.MaxAttempts; attempt++ {
+		vectors, err := e.embedder.EmbedStrings(ctx, texts, opts...)
+		if err == nil {
+			return vectors, nil
+		}
+		lastErr = err
+		if !isTransientError(ctx, err) || attempt == e.opts.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(e.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("embedding retry cancelled: %w", ctx.Err())
+		}
+	}
+	return nil, fmt.Errorf("embedding failed after retries: %w", lastErr)
+}
+
+// backoff 第 attempt 次失败后的等待时间：BaseDelay * 2^(attempt-1)，不超过 MaxDelay，
+// 并在 [0.5, 1) 倍之间随机抖动，避免多个请求同时重试
+func (e *RetryEmbedder) backoff(attempt int) time.Duration {
+	delay := e.opts.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > e.opts.MaxDelay {
+		delay = e.opts.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isTransientError 判断错误是否值得重试
+func isTransientError(ctx context.Context, err error) bool {
+	// 调用方主动取消或超时，不再重试
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// SDK 返回的错误没有统一的类型，只能根据错误信息中的状态码判断
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"401", "403", "unauthorized", "forbidden", "invalid api key"} {
+		if strings.Contains(msg, s) {
+			return false
+		}
+	}
+	for _, s := range []string{"429", "502", "503", "too many requests", "rate limit", "bad gateway", "service unavailable", "timeout"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 检索方式
+const (
+	SearchModeVector  = "vector"  // 向量检索（语义相似）
+	SearchModeKeyword = "keyword" // 关键词检索（content 字段全文检索），适合产品编号、错误码等精确匹配
+	SearchModeHybrid  = "hybrid"  // 向量 + 关键词，两路结果按倒数排名融合（RRF）
+)
+
+// RRF 融合常数，取常用值 60，用于削弱排名靠前文档之间的分差
+const rrfK = 60
+
+// search 按检索方式执行检索
+func (r *RAGQuery) search(ctx context.Context, query string, opts RetrieveOptions) ([]*schema.Document, error) {
+	var docs []*schema.Document
+	var err error
+	switch r.searchMode {
+	case SearchModeKeyword:
+		docs, err = r.keywordSearch(ctx, query, opts.Filter, opts.Highlight)
+	case SearchModeHybrid:
+		var vectorDocs, keywordDocs []*schema.Document
+		if vectorDocs, err = r.vectorSearch(ctx, query, opts); err != nil {
+			return nil, err
+		}
+		if keywordDocs, err = r.keywordSearch(ctx, query, opts.Filter, opts.Highlight); err != nil {
+			return nil, err
+		}
+		docs = fuseRRF(r.candidateLimit(), vectorDocs, keywordDocs)
+	default:
+		docs, err = r.vectorSearch(ctx, query, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Filter) > 0 {
+		docs = filterByMetadata(docs, opts.Filter)
+	}
+	return docs, nil
+}
+
+// vectorSearch 向量检索
+func (r *RAGQuery) vectorSearch(ctx context.Context, query string, opts RetrieveOptions) ([]*schema.Document, error) {
+	docs, err := r.store.Retrieve(ctx, query, r.candidateLimit(), opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+	if opts.MaxDistance > 0 {
+		docs = filterByDistance(docs, opts.MaxDistance)
+	}
+	return docs, nil
+}
+
+// keywordSearch 对 content 字段做全文检索，问题中的任意一个词命中即可；filters 为空表示不过滤
+// highlight 为 true 时在元数据 highlights 中返回命中的区间
+// 只有 Redis 存储支持关键词检索
+func (r *RAGQuery) keywordSearch(ctx context.Context, query string, filters map[string]string, highlight bool) ([]*schema.Document, error) {
+	if r.client == nil {
+		return nil, fmt.Errorf("keyword search: %w", ErrUnsupportedByStore)
+	}
+	filter, err := r.buildFilter(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return []*schema.Document{}, nil
+	}
+
+	searchQuery := fmt.Sprintf("@content:(%s)", strings.Join(terms, " | "))
+	if filter != "" {
+		searchQuery += " " + filter
+	}
+	args := []interface{}{
+		"FT.SEARCH", r.indexName,
+		searchQuery,
+		"WITHSCORES",
+		"RETURN", len(r.returnFields),
+	}
+	for _, f := range r.returnFields {
+		args = append(args, f)
+	}
+	if highlight {
+		args = append(args, highlightArgs()...)
+	}
+	args = append(args, "LIMIT", 0, r.candidateLimit(), "DIALECT", r.dialect)
+
+	res, err := r.client.Do(ctx, args...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keywords: %w", err)
+	}
+
+	results, err := parseSearchReply(res)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*schema.Document, 0, len(results))
+	for _, result := range results {
+		doc, err := r.convert(ctx, result)
+		if err != nil {
+			return nil, err
+		}
+		if highlight {
+			var highlights []Highlight
+			doc.Content, highlights = extractHighlights(doc.Content)
+			if len(highlights) > 0 {
+				doc.MetaData["highlights"] = highlights
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// keywordTerms 将问题拆分为关键词，并转义 RediSearch 查询语法中的特殊字符
+func keywordTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		var sb strings.Builder
+		for _, c := range word {
+			if strings.ContainsRune(",.<>{}[]\"':;!@#$%^&*()-+=~|/\\?", c) {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(c)
+		}
+		terms = append(terms, sb.String())
+	}
+	return terms
+}
+
+// parseSearchReply 解析 RESP2 格式的 FT.SEARCH ... WITHSCORES 返回值：
+// [总数, id1, score1, [field1, value1, ...], id2, score2, [...], ...]
+func parseSearchReply(res interface{}) ([]redisCli.Document, error) {
+	reply, ok := res.([]interface{})
+	if !ok || len(reply) == 0 {
+		return nil, fmt.Errorf("unexpected search reply type %T", res)
+	}
+
+	var docs []redisCli.Document
+	for i := 1; i+2 < len(reply); i += 3 {
+		doc := redisCli.Document{
+			ID:     fmt.Sprint(reply[i]),
+			Fields: map[string]string{},
+		}
+		if score, err := strconv.ParseFloat(fmt.Sprint(reply[i+1]), 64); err == nil {
+			doc.Score = &score
+		}
+		if fields, ok := reply[i+2].([]interface{}); ok {
+			for j := 0; j+1 < len(fields); j += 2 {
+				doc.Fields[fmt.Sprint(fields[j])] = fmt.Sprint(fields[j+1])
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// fuseRRF 使用倒数排名融合（Reciprocal Rank Fusion）合并多路检索结果
+// 每路结果中排名为 rank（从 1 开始）的文档得分 1/(rrfK+rank)，同一文档的得分累加，
+// 按总分从高到低取前 topK 个；融合得分写入 rrf_score 字段
+func fuseRRF(topK int, rankings ...[]*schema.Document) []*schema.Document {
+	scores := make(map[string]float64)
+	merged := make(map[string]*schema.Document)
+	var order []string
+
+	for _, docs := range rankings {
+		for rank, doc := range docs {
+			scores[doc.ID] += 1.0 / float64(rrfK+rank+1)
+			existing, ok := merged[doc.ID]
+			if !ok {
+				merged[doc.ID] = doc
+				order = append(order, doc.ID)
+				continue
+			}
+			// 同一文档在多路结果中出现时合并元数据（例如同时保留 distance 和 keyword_score）
+			for k, v := range doc.MetaData {
+				if _, exists := existing.MetaData[k]; !exists {
+					existing.MetaData[k] = v
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	if len(order) > topK {
+		order = order[:topK]
+	}
+
+	fused := make([]*schema.Document, 0, len(order))
+	for _, id := range order {
+		doc := merged[id]
+		doc.MetaData["rrf_score"] = scores[id]
+		fused = append(fused, doc)
+	}
+	return fused
+}
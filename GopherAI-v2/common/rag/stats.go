@@ -0,0 +1,90 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Stats 知识库索引的统计信息
+type Stats struct {
+	IndexName string `json:"index_name"` // Redis 中的索引名
+	NumDocs   int64  `json:"num_docs"`   // 已索引的文档块数量
+	SizeBytes int64  `json:"size_bytes"` // 索引占用的内存（字节）
+	Dimension int    `json:"dimension"`  // 向量维度
+}
+
+// FT.INFO 中表示各部分内存占用的字段（单位 MB）
+var indexSizeFields = []string{
+	"inverted_sz_mb",
+	"vector_index_sz_mb",
+	"offset_vectors_sz_mb",
+	"doc_table_size_mb",
+	"sortable_values_size_mb",
+	"key_table_size_mb",
+}
+
+// IndexStats 查询指定用户某个文件对应知识库的统计信息（文档数、内存占用、向量维度）
+func IndexStats(ctx context.Context, username, filename string) (*Stats, error) {
+	info, err := redisPkg.GetRedisIndexInfo(ctx, username, filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index stats for %s: %w", filename, err)
+	}
+
+	stats := &Stats{
+		IndexName: fmt.Sprint(info["index_name"]),
+		NumDocs:   int64(infoFloat(info["num_docs"])),
+		Dimension: vectorDimension(info["attributes"]),
+	}
+
+	// 新版本 RediSearch 直接给出总内存，旧版本需要把各部分加起来
+	sizeMB := infoFloat(info["total_index_memory_sz_mb"])
+	if sizeMB == 0 {
+		for _, field := range indexSizeFields {
+			sizeMB += infoFloat(info[field])
+		}
+	}
+	stats.SizeBytes = int64(sizeMB * 1024 * 1024)
+
+	// 无法从索引信息中读取维度时，使用配置中的维度
+	if stats.Dimension == 0 {
+		stats.Dimension = config.GetConfig().RagModelConfig.RagDimension
+	}
+	return stats, nil
+}
+
+// vectorDimension 从 FT.INFO 的 attributes 中找到向量字段的维度
+func vectorDimension(attributes interface{}) int {
+	list, ok := attributes.([]interface{})
+	if !ok {
+		return 0
+	}
+	for _, attr := range list {
+		fields, ok := attr.([]interface{})
+		if !ok {
+			continue
+		}
+		for i := 0; i+1 < len(fields); i++ {
+			if key, ok := fields[i].(string); ok && (key == "dim" || key == "DIM") {
+				return int(infoFloat(fields[i+1]))
+			}
+		}
+	}
+	return 0
+}
+
+// infoFloat 将 FT.INFO 中的数值（可能是字符串、整数或浮点数）统一转换为 float64
+func infoFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,125 @@
+package rag
+
+import (
+	redisPkg "GopherAI/common/redis"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+
+	redisIndexer "github.com/cloudwego/eino-ext/components/indexer/redis"
+	redisRetriever "github.com/cloudwego/eino-ext/components/retriever/redis"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 向量存储后端
+const (
+	VectorStoreRedis  = "redis"  // Redis + RediSearch（默认）
+	VectorStoreMemory = "memory" // 进程内存，暴力计算余弦相似度，适合测试和小规模部署
+)
+
+// ErrUnsupportedByStore 当前向量存储后端不支持该操作（例如内存存储不支持关键词检索），可通过 errors.Is 判断
+var ErrUnsupportedByStore = errors.New("operation is not supported by the vector store")
+
+// VectorStore 向量存储，RAGIndexer 和 RAGQuery 通过它写入、检索和删除文档块
+type VectorStore interface {
+	// Store 向量化并保存文档块，ID 相同的文档块会被覆盖，返回保存的文档块 ID
+	Store(ctx context.Context, docs []*schema.Document) ([]string, error)
+	// Retrieve 返回与 query 最相似的最多 topK 个文档块，按距离从小到大排序，
+	// MetaData["distance"] 为向量距离（越小越相似），MetaData["score"] 为换算后的相似度；filter 为元数据过滤条件，为空表示不过滤
+	Retrieve(ctx context.Context, query string, topK int, filter map[string]string) ([]*schema.Document, error)
+	// Delete 按文档块 ID 删除，返回实际删除的数量
+	Delete(ctx context.Context, ids ...string) (int, error)
+	// Get 按文档块 ID 读取文档块（不含向量），不存在的 ID 跳过；返回的文档 ID 与 Retrieve 的结果格式相同
+	Get(ctx context.Context, ids ...string) ([]*schema.Document, error)
+}
+
+// vectorStoreBackend 返回配置的向量存储后端
+func vectorStoreBackend() (string, error) {
+	switch backend := config.GetConfig().RagModelConfig.RagVectorStore; backend {
+	case "", VectorStoreRedis:
+		return VectorStoreRedis, nil
+	case VectorStoreMemory:
+		return VectorStoreMemory, nil
+	default:
+		return "", fmt.Errorf("unknown vector store: %s", backend)
+	}
+}
+
+// redisVectorStore 基于 RediSearch 的向量存储
+// NewRAGIndexer 创建的只带索引器（用于写入），NewRAGQuery 创建的只带检索器（用于检索），两者都可以删除
+type redisVectorStore struct {
+	indexer   *redisIndexer.Indexer
+	retriever retriever.Retriever
+	client    *redisCli.Client // 索引所在节点的客户端
+	indexName string
+	keyPrefix string // 文档块 key 的前缀，key = keyPrefix + 文档块 ID
+}
+
+func (s *redisVectorStore) Store(ctx context.Context, docs []*schema.Document) ([]string, error) {
+	if s.indexer == nil {
+		return nil, fmt.Errorf("redis vector store has no indexer: %w", ErrUnsupportedByStore)
+	}
+	return s.indexer.Store(ctx, docs)
+}
+
+func (s *redisVectorStore) Retrieve(ctx context.Context, query string, topK int, filter map[string]string) ([]*schema.Document, error) {
+	if s.retriever == nil {
+		return nil, fmt.Errorf("redis vector store has no retriever: %w", ErrUnsupportedByStore)
+	}
+	opts := []retriever.Option{retriever.WithTopK(topK)}
+	if len(filter) > 0 {
+		fieldTypes, err := redisPkg.GetIndexSchema(ctx, s.client, s.indexName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get index schema: %w", err)
+		}
+		filterQuery, err := buildFilterQuery(fieldTypes, filter)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, redisRetriever.WithFilterQuery(filterQuery))
+	}
+	return s.retriever.Retrieve(ctx, query, opts...)
+}
+
+func (s *redisVectorStore) Get(ctx context.Context, ids ...string) ([]*schema.Document, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	pipe := s.client.Pipeline()
+	cmds := make([]*redisCli.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, s.keyPrefix+id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	docs := make([]*schema.Document, 0, len(ids))
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			continue
+		}
+		delete(fields, "vector")
+		doc, err := convertDocument(ctx, redisCli.Document{ID: s.keyPrefix + ids[i], Fields: fields})
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func (s *redisVectorStore) Delete(ctx context.Context, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.keyPrefix + id
+	}
+	n, err := s.client.Del(ctx, keys...).Result()
+	return int(n), err
+}
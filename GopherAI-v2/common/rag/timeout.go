@@ -0,0 +1,48 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrRetrievalTimeout 检索超过 RetrieveOptions.Timeout 仍未完成，可通过 errors.Is 判断，具体的超时时间见 *RetrievalTimeoutError
+var ErrRetrievalTimeout = errors.New("retrieval timed out")
+
+// RetrievalTimeoutError 检索超时，Err 为超时时正在执行的步骤返回的错误
+type RetrievalTimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *RetrievalTimeoutError) Error() string {
+	return fmt.Sprintf("retrieval timed out after %s: %v", e.Timeout, e.Err)
+}
+
+// Is 使 errors.Is(err, ErrRetrievalTimeout) 成立
+func (e *RetrievalTimeoutError) Is(target error) bool {
+	return target == ErrRetrievalTimeout
+}
+
+func (e *RetrievalTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// withTimeout 按 opts.Timeout 派生带截止时间的 ctx，Timeout 为 0 时原样返回
+// 向量化、Redis 检索等子调用都使用派生出的 ctx，共用同一个截止时间
+func (o RetrieveOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.Timeout)
+}
+
+// retrievalTimeoutError 检索因为 opts.Timeout 派生的截止时间到达而失败时返回 *RetrievalTimeoutError，
+// 调用方自己的 ctx 被取消或超时时原样返回 err
+func retrievalTimeoutError(parent, ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || parent.Err() != nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &RetrievalTimeoutError{Timeout: timeout, Err: err}
+}
@@ -0,0 +1,92 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/schema"
+)
+
+// slowEmbedder 等待 delay 后返回向量，ctx 先结束时返回 ctx 的错误
+type slowEmbedder struct {
+	delay time.Duration
+}
+
+func (e slowEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) ([][]float64, error) {
+	select {
+	case <-time.After(e.delay):
+		return (&fakeEmbedder{}).EmbedStrings(ctx, texts, opts...)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRetrieveDocumentsTimeout(t *testing.T) {
+	SetLogger(nil)
+	config.SetConfig(&config.Config{})
+	newQuery := func(delay time.Duration) *RAGQuery {
+		store := NewMemoryVectorStore(&fakeEmbedder{})
+		if _, err := store.Store(context.Background(), []*schema.Document{{ID: "1", Content: "hello"}}); err != nil {
+			t.Fatal(err)
+		}
+		store.embedder = slowEmbedder{delay: delay}
+		return &RAGQuery{store: store, indexName: "test", searchMode: SearchModeVector, topK: 3}
+	}
+
+	tests := []struct {
+		name           string
+		delay          time.Duration
+		timeout        time.Duration
+		parentTimeout  time.Duration // 调用方 ctx 的超时，0 表示不设置
+		cancelParent   bool
+		wantTimeoutErr bool
+		wantErr        error
+	}{
+		{"no timeout", 0, 0, 0, false, false, nil},
+		{"within timeout", 0, time.Second, 0, false, false, nil},
+		{"exceeds timeout", time.Second, 20 * time.Millisecond, 0, false, true, context.DeadlineExceeded},
+		{"caller deadline first", time.Second, time.Second, 20 * time.Millisecond, false, false, context.DeadlineExceeded},
+		{"caller cancelled", time.Second, time.Second, 0, true, false, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuery(tt.delay)
+			ctx := context.Background()
+			if tt.parentTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parentTimeout)
+				defer cancel()
+			}
+			if tt.cancelParent {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(ctx)
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			start := time.Now()
+			docs, err := q.RetrieveDocuments(ctx, "hello", RetrieveOptions{Timeout: tt.timeout})
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("RetrieveDocuments took %s", elapsed)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrRetrievalTimeout) != tt.wantTimeoutErr {
+				t.Fatalf("errors.Is(%v, ErrRetrievalTimeout) = %v, want %v", err, !tt.wantTimeoutErr, tt.wantTimeoutErr)
+			}
+			if tt.wantTimeoutErr {
+				var timeoutErr *RetrievalTimeoutError
+				if !errors.As(err, &timeoutErr) || timeoutErr.Timeout != tt.timeout {
+					t.Errorf("err = %#v, want *RetrievalTimeoutError with timeout %s", err, tt.timeout)
+				}
+			}
+			if tt.wantErr == nil && len(docs) != 1 {
+				t.Errorf("got %d docs, want 1", len(docs))
+			}
+		})
+	}
+}
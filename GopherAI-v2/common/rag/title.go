@@ -0,0 +1,69 @@
+package rag
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 第一行超过该长度时更像正文段落而不是标题，不作为标题
+const maxTitleRunes = 100
+
+// detectTitle 从文档文本中识别标题：优先使用第一个 Markdown 标题，否则使用第一个非空行；
+// 都识别不到（或第一行太长）时退回文件名（网页为 URL 路径的最后一段）
+func detectTitle(source string, segments []textSegment) string {
+	firstLine := ""
+	for _, seg := range segments {
+		for _, line := range strings.Split(seg.Text, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "\uFEFF"))
+			if line == "" {
+				continue
+			}
+			if level, text := markdownHeading(line); level > 0 && text != "" {
+				return text
+			}
+			if firstLine == "" {
+				firstLine = line
+			}
+		}
+	}
+	if firstLine != "" && utf8.RuneCountInString(firstLine) <= maxTitleRunes {
+		return firstLine
+	}
+	return fallbackTitle(source)
+}
+
+// markdownHeading 解析 Markdown 标题行（"# 标题"），返回级别和标题文本，不是标题时级别为 0
+func markdownHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(line[level:], "# \t"))
+}
+
+// fallbackTitle 识别不到标题时使用的标题：文件名，网页为 URL 路径的最后一段（没有路径时为域名）
+func fallbackTitle(source string) string {
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			return base
+		}
+		return u.Host
+	}
+	return filepath.Base(source)
+}
+
+// tagTitles 在每个文档块的元数据中记录所属文档的标题（title）
+func tagTitles(docs []*schema.Document, title string) []*schema.Document {
+	for _, doc := range docs {
+		doc.MetaData["title"] = title
+	}
+	return docs
+}
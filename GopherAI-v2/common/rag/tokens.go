@@ -0,0 +1,48 @@
+package rag
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// 这里没有引入具体模型的分词器，而是按经验粗略估算 token 数：
+// 中日韩文字基本是一字一个 token，其它文本（英文、数字、符号）大约每 4 个字节一个 token。
+// 估算结果用于控制提示词长度，不要求精确，宁可偏多。
+// 对话模型可以配置为任意 OpenAI 兼容接口、方舟或 Ollama，各家分词器不同，tiktoken 的词表只对 OpenAI 模型准确，
+// 还会让二进制文件增大数十 MB，因此使用这个预算的选项都以 Estimate 结尾。
+
+// estimateTokens 粗略估算文本的 token 数
+func estimateTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// truncateToTokens 截断文本，使估算的 token 数不超过 maxTokens
+func truncateToTokens(s string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	cjk, other := 0, 0
+	for i, r := range s {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+		if cjk+(other+3)/4 > maxTokens {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
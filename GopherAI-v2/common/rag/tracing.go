@@ -0,0 +1,66 @@
+package rag
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/eino/components/embedding"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName rag 包创建 span 使用的 instrumentation 名称
+const tracerName = "GopherAI/rag"
+
+// tracer rag 包使用的链路追踪器，默认使用 OpenTelemetry 全局的 TracerProvider，
+// 没有调用 otel.SetTracerProvider 时不做任何事
+var tracer trace.Tracer = otel.Tracer(tracerName)
+
+// SetTracerProvider 让 rag 包使用指定的 TracerProvider 创建 span，传入 nil 时恢复为全局的 TracerProvider
+// 应在初始化阶段调用，不要与索引、检索并发调用
+func SetTracerProvider(tp trace.TracerProvider) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer = tp.Tracer(tracerName)
+}
+
+// startSpan 创建 span 并设置初始属性
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束 span，err 不为 nil 时记录错误并将 span 状态设为 Error
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// instrumentedEmbedder 为每次向量化调用创建一个 span 并记录指标
+type instrumentedEmbedder struct {
+	embedder embedding.Embedder
+	labels   metricLabels
+}
+
+// withInstrumentation 为向量生成器加上链路追踪和指标（放在最外层，缓存命中的调用同样可见）
+// operation 为 OperationIndex 或 OperationQuery
+func withInstrumentation(embedder embedding.Embedder, index, operation string) embedding.Embedder {
+	return &instrumentedEmbedder{embedder: embedder, labels: metricLabels{index: index, operation: operation}}
+}
+
+func (e *instrumentedEmbedder) EmbedStrings(ctx context.Context, texts []string, opts ...embedding.Option) (vectors [][]float64, err error) {
+	start := time.Now()
+	ctx, span := startSpan(ctx, "rag.embed",
+		attribute.String("rag.index", e.labels.index),
+		attribute.Int("rag.embed.texts", len(texts)))
+	defer func() {
+		observeEmbedding(e.labels, start, err)
+		endSpan(span, err)
+	}()
+	return e.embedder.EmbedStrings(ctx, texts, opts...)
+}
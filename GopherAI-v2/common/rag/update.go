@@ -0,0 +1,202 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// UpdateResult 增量更新的结果统计
+type UpdateResult struct {
+	Added   int `json:"added"`   // 新增的文档块数
+	Updated int `json:"updated"` // 内容变化、重新向量化的文档块数
+	Moved   int `json:"moved"`   // 内容未变化、只更新了位置（chunk_index、页码、相邻块）的文档块数
+	Deleted int `json:"deleted"` // 文件中已不存在、被删除的文档块数
+}
+
+// UpdateFile 增量更新文件的向量索引
+// 文件重新切块后，与 Redis 中已存储的内容哈希比较，只对新增和内容变化的块重新向量化，
+// 并删除文件中已不存在的块；内容未变化的块不会再调用向量模型。
+// 文档块 ID 由内容生成（见 newChunkID），前面插入或删除内容后，后面内容没变的块只更新位置字段
+// 旧版本按序号生成 ID 的知识库第一次增量更新时会全部重新向量化
+// 只有 Redis 存储支持增量更新
+func (r *RAGIndexer) UpdateFile(ctx context.Context, filePath string, opts ChunkOptions) (*UpdateResult, error) {
+	if r.client == nil {
+		return nil, fmt.Errorf("incremental update: %w", ErrUnsupportedByStore)
+	}
+	docs, err := buildDocuments(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	quota, err := r.reserveQuota(ctx, filePath, info.Size(), int64(len(docs)))
+	if err != nil {
+		return nil, err
+	}
+	// 中途失败时归还预占的配额
+	committed := false
+	defer func() {
+		if !committed {
+			quota.release(context.WithoutCancel(ctx))
+		}
+	}()
+
+	stored, err := r.storedChunks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpdateResult{}
+	var changed []*schema.Document
+	moved := r.client.Pipeline()
+	for _, doc := range docs {
+		key := r.keyPrefix + doc.ID
+		old, exists := stored[key]
+		delete(stored, key)
+
+		switch {
+		case !exists:
+			result.Added++
+		case old[0] != contentHash(doc.Content):
+			result.Updated++
+		default:
+			if queuePositionUpdate(ctx, moved, key, old[1:], doc) {
+				result.Moved++
+			}
+			continue
+		}
+		changed = append(changed, doc)
+	}
+
+	if len(changed) > 0 {
+		if _, err := r.store.Store(ctx, changed); err != nil {
+			return nil, fmt.Errorf("failed to store document: %w", err)
+		}
+	}
+	if result.Moved > 0 {
+		if _, err := moved.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to update chunk positions: %w", err)
+		}
+	}
+
+	// 剩下的都是文件中已不存在的块
+	if len(stored) > 0 {
+		keys := make([]string, 0, len(stored))
+		for key := range stored {
+			keys = append(keys, key)
+		}
+		if err := r.client.Del(ctx, keys...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to delete stale chunks: %w", err)
+		}
+		result.Deleted = len(keys)
+	}
+	// 知识库内容有变化时让检索结果缓存失效
+	if len(changed) > 0 || result.Moved > 0 || result.Deleted > 0 {
+		invalidateResultCache(ctx, r.indexName)
+	}
+	committed = true
+	quota.commit(context.WithoutCancel(ctx), info.Size(), int64(len(docs)))
+	return result, nil
+}
+
+// positionFields 文档块中与位置有关的元数据，块内容不变、位置变化时只更新这些字段
+var positionFields = []string{"chunk_index", "page", "prev_id", "next_id"}
+
+// storedChunks 读取知识库中已存储的所有文档块的内容哈希和位置字段（key -> [content_hash, positionFields...]）
+// 旧版本索引的文档块没有内容哈希，值为空字符串，会被视为内容已变化；不存在的字段也是空字符串
+func (r *RAGIndexer) storedChunks(ctx context.Context) (map[string][]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := r.client.Scan(ctx, cursor, escapeGlob(r.keyPrefix)+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stored chunks: %w", err)
+		}
+		keys = append(keys, batch...)
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	chunks := make(map[string][]string, len(keys))
+	if len(keys) == 0 {
+		return chunks, nil
+	}
+
+	fields := append([]string{"content_hash"}, positionFields...)
+	pipe := r.client.Pipeline()
+	cmds := make([]*redisCli.SliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HMGet(ctx, key, fields...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redisCli.Nil {
+		return nil, fmt.Errorf("failed to read chunk hashes: %w", err)
+	}
+	for i, key := range keys {
+		values := make([]string, len(fields))
+		for j, v := range cmds[i].Val() {
+			if s, ok := v.(string); ok {
+				values[j] = s
+			}
+		}
+		chunks[key] = values
+	}
+	return chunks, nil
+}
+
+// queuePositionUpdate 比较已存储的位置字段（顺序同 positionFields）和重新切块后的值，
+// 有变化时在 pipe 中加入更新命令（新值为空的字段删除），返回是否有变化
+func queuePositionUpdate(ctx context.Context, pipe redisCli.Pipeliner, key string, old []string, doc *schema.Document) bool {
+	var set []any
+	var del []string
+	for i, field := range positionFields {
+		value := ""
+		if v, ok := doc.MetaData[field]; ok {
+			value = fmt.Sprint(v)
+		}
+		if value == old[i] {
+			continue
+		}
+		if value == "" {
+			del = append(del, field)
+		} else {
+			set = append(set, field, value)
+		}
+	}
+	if len(set) > 0 {
+		pipe.HSet(ctx, key, set...)
+	}
+	if len(del) > 0 {
+		pipe.HDel(ctx, key, del...)
+	}
+	return len(set) > 0 || len(del) > 0
+}
+
+// contentHash 计算文档块内容的 sha256
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// escapeGlob 转义 SCAN MATCH 模式中的通配符
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,257 @@
+package rag
+
+import (
+	"GopherAI/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultFetchTimeout  = 15 * time.Second
+	defaultFetchMaxBytes = 5 << 20
+	fetchUserAgent       = "Mozilla/5.0 (compatible; GopherAI-RAG/1.0)"
+)
+
+// ErrUnsupportedContentType 网页不是 HTML，无法提取正文
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// 正文之外的元素，其中的文本全部丢弃
+var skippedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true, "iframe": true,
+	"nav": true, "header": true, "footer": true, "aside": true, "form": true, "button": true,
+}
+
+// 块级元素，前后换行，保证段落之间的分隔
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "li": true, "ul": true, "ol": true,
+	"table": true, "tr": true, "pre": true, "blockquote": true, "br": true, "hr": true, "dd": true, "dt": true,
+}
+
+// IndexURL 抓取网页，提取正文后切块并存入知识库，文档的 source 为网页 URL
+// 标题（h1-h6）会转换为 Markdown 标题，未指定切块策略时按 Markdown 标题切块
+// 只支持 http / https 的 HTML 页面；不允许访问内网地址；返回实际存储的文档块数
+// 网页没有提取出正文时返回 ErrEmptyDocument
+func (r *RAGIndexer) IndexURL(ctx context.Context, rawURL string, opts IndexOptions) (int, error) {
+	if opts.MaxConcurrency == 0 {
+		opts.MaxConcurrency = defaultMaxConcurrency
+	}
+	if opts.MaxConcurrency < 1 {
+		return 0, fmt.Errorf("invalid max concurrency %d: must be >= 1", opts.MaxConcurrency)
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = ChunkStrategyMarkdown
+	}
+	chunkOpts, err := opts.ChunkOptions.withDefaults()
+	if err != nil {
+		return 0, err
+	}
+
+	text, err := fetchPageText(ctx, rawURL)
+	if err != nil {
+		return 0, err
+	}
+	docs := linkChunks(tagLanguages(normalizeDocuments(chunkSegments(rawURL, []textSegment{{Text: text}}, chunkOpts), chunkOpts.Normalize)))
+	if len(docs) == 0 {
+		return 0, fmt.Errorf("%s: %w", rawURL, ErrEmptyDocument)
+	}
+	if opts.Dedup {
+		docs = dedupDocuments(docs)
+	}
+	size := int64(len(text))
+	quota, err := r.reserveQuota(ctx, rawURL, size, int64(len(docs)))
+	if err != nil {
+		return 0, err
+	}
+	stored, err := r.storeBatches(ctx, docs, opts.MaxConcurrency, opts.Progress)
+	quota.commit(context.WithoutCancel(ctx), size, int64(stored))
+	return stored, err
+}
+
+// fetchPageText 下载网页并提取正文
+func fetchPageText(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid url %q: only http and https urls are supported", rawURL)
+	}
+
+	conf := config.GetConfig().RagModelConfig
+	timeout := defaultFetchTimeout
+	if conf.RagFetchTimeout > 0 {
+		timeout = time.Duration(conf.RagFetchTimeout) * time.Millisecond
+	}
+	maxBytes := int64(defaultFetchMaxBytes)
+	if conf.RagFetchMaxBytes > 0 {
+		maxBytes = conf.RagFetchMaxBytes
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", fetchUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := fetchClient(timeout).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: status %d", rawURL, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("%w %q: only html pages can be indexed", ErrUnsupportedContentType, mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", rawURL, err)
+	}
+	if int64(len(body)) > maxBytes {
+		return "", fmt.Errorf("page %s is larger than %d bytes", rawURL, maxBytes)
+	}
+
+	text := extractHTMLText(strings.NewReader(string(body)))
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("no readable text found in %s", rawURL)
+	}
+	return text, nil
+}
+
+// fetchClient 抓取网页用的 HTTP 客户端，拒绝连接回环、内网等地址，避免被用来探测服务器所在的内网
+func fetchClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// extractHTMLText 提取 HTML 的正文文本
+// 丢弃脚本、样式、导航栏等元素；页面中有 <main> 或 <article> 时只保留其中的内容；
+// 块级元素之间换行，h1-h6 转换为 Markdown 标题
+func extractHTMLText(r io.Reader) string {
+	z := html.NewTokenizer(r)
+	var all, main strings.Builder
+	skipDepth := 0 // 位于需要丢弃的元素中时大于 0
+	mainDepth := 0 // 位于 <main> / <article> 中时大于 0
+
+	write := func(s string) {
+		all.WriteString(s)
+		if mainDepth > 0 {
+			main.WriteString(s)
+		}
+	}
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if skippedTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if (tok.Data == "main" || tok.Data == "article") && tt == html.StartTagToken {
+				mainDepth++
+			}
+			if level := headingLevel(tok.Data); level > 0 {
+				write("\n\n" + strings.Repeat("#", level) + " ")
+			} else if blockTags[tok.Data] {
+				write("\n")
+			}
+		case html.EndTagToken:
+			if skippedTags[tok.Data] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if headingLevel(tok.Data) > 0 || blockTags[tok.Data] {
+				write("\n")
+			}
+			if (tok.Data == "main" || tok.Data == "article") && mainDepth > 0 {
+				mainDepth--
+			}
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			if text := strings.Join(strings.Fields(tok.Data), " "); text != "" {
+				write(text + " ")
+			}
+		}
+	}
+
+	text := all.String()
+	if strings.TrimSpace(main.String()) != "" {
+		text = main.String()
+	}
+	return cleanLines(text)
+}
+
+// headingLevel 返回 h1-h6 的级别，其它元素返回 0
+func headingLevel(tag string) int {
+	if len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6' {
+		return int(tag[1] - '0')
+	}
+	return 0
+}
+
+// cleanLines 去掉行首尾空白，合并连续的空行
+func cleanLines(text string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,40 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 预热时向量化和检索使用的文本
+const warmupText = "warmup"
+
+// Warmup 预热用户的 RAG 查询：创建（并放入查询器池）查询器、调用一次向量模型、执行一次检索，
+// 让向量模型的连接、Redis 连接和索引在用户第一次提问前就绪。
+// 预热失败不影响调用方，错误只记录日志；用户还没有上传文件时直接返回。ctx 的超时由调用方决定
+func Warmup(ctx context.Context, username string) {
+	start := time.Now()
+	q, release, err := AcquireQuery(ctx, username, QueryOptions{})
+	if err != nil {
+		if errors.Is(err, ErrIndexNotFound) {
+			return
+		}
+		logger.Warn("rag warmup: create query failed", "username", username, "error", err)
+		return
+	}
+	defer release()
+
+	// 跳过向量缓存，否则缓存命中时不会真正连接向量模型
+	vectors, err := q.embedding.EmbedStrings(withoutEmbeddingCache(ctx), []string{warmupText})
+	if err != nil || len(vectors) != 1 {
+		logger.Warn("rag warmup: embedding failed", "index", q.indexName, "error", err)
+		return
+	}
+	// 检索直接使用上面的向量，不再调用向量模型
+	ctx = withQueryVectors(ctx, map[string][]float64{normalizeText(warmupText, q.normalize): vectors[0]})
+	if _, err := q.RetrieveDocuments(ctx, warmupText, RetrieveOptions{BypassCache: true}); err != nil {
+		logger.Warn("rag warmup: retrieval failed", "index", q.indexName, "error", err)
+		return
+	}
+	logger.Info("rag warmup done", "index", q.indexName, "latency_ms", time.Since(start).Milliseconds())
+}
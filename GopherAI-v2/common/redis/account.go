@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeleteUserKeys 删除账号相关的验证码、密码重置、更换邮箱、登录失败计数、配额和限流 key（注销账号时调用）
+// email 为空时只删除按账号记录的 key；key 不存在时不报错
+func DeleteUserKeys(ctx context.Context, username, email string) error {
+	keys := []string{
+		GenerateLoginFail(username),
+		GenerateLoginLock(username),
+		GenerateQuotaUser(username),
+		GenerateRateLimit("query", username),
+		GenerateRateLimit("index", username),
+		GenerateEmailChange(username),
+		GenerateEmailChangeAttempts(username),
+	}
+	if email != "" {
+		keys = append(keys,
+			GenerateCaptcha(email),
+			GenerateCaptchaCooldown(email),
+			GenerateCaptchaHourly(email),
+			GeneratePasswordReset(email),
+			GeneratePasswordResetAttempts(email),
+			// 邮箱也可以作为登录账号
+			GenerateLoginFail(email),
+			GenerateLoginLock(email),
+		)
+	}
+	// 集群模式下这些 key 不在同一个 slot，逐个删除
+	pipe := Rdb.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("删除账号数据失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteRAGHistories 删除会话的 RAG 多轮对话历史
+func DeleteRAGHistories(ctx context.Context, sessionIDs []string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	pipe := Rdb.Pipeline()
+	for _, id := range sessionIDs {
+		pipe.Del(ctx, GenerateRAGHistory(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("删除对话历史失败: %w", err)
+	}
+	return nil
+}
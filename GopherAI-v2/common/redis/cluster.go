@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"GopherAI/config"
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// ClusterEnabled 是否开启了 Redis 集群模式（需要同时配置 clusterMode 和 clusterAddrs）
+func ClusterEnabled() bool {
+	conf := config.GetConfig().RedisConfig
+	return conf.RedisClusterMode && len(conf.RedisClusterAddrs) > 0
+}
+
+// newClusterClient 根据配置创建集群客户端
+func newClusterClient(conf config.RedisConfig, tlsConfig *tls.Config) *redisCli.ClusterClient {
+	return redisCli.NewClusterClient(&redisCli.ClusterOptions{
+		Addrs:     conf.RedisClusterAddrs,
+		Username:  conf.RedisUsername,
+		Password:  conf.RedisPassword,
+		Protocol:  2,
+		TLSConfig: tlsConfig,
+
+		PoolSize:     conf.RedisPoolSize,
+		DialTimeout:  millis(conf.RedisDialTimeout),
+		ReadTimeout:  millis(conf.RedisReadTimeout),
+		WriteTimeout: millis(conf.RedisWriteTimeout),
+		MaxRetries:   conf.RedisMaxRetries,
+	})
+}
+
+// IndexClient 返回某个知识库索引所在节点的客户端
+// 单机模式下就是 Rdb；集群模式下 RediSearch 的索引只覆盖本节点的数据，
+// 知识库的 key 前缀带有 hash tag，所以 FT.* 命令和文档读写都要发到该 hash tag 所在的主节点
+func IndexClient(ctx context.Context, username, filename string) (*redisCli.Client, error) {
+	switch c := Rdb.(type) {
+	case *redisCli.Client:
+		return c, nil
+	case *redisCli.ClusterClient:
+		node, err := c.MasterForKey(ctx, GenerateIndexNamePrefix(username, filename))
+		if err != nil {
+			return nil, fmt.Errorf("获取索引所在节点失败: %w", err)
+		}
+		return node, nil
+	default:
+		return nil, fmt.Errorf("Redis 未初始化")
+	}
+}
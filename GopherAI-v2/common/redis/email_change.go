@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"crypto/subtle"
+	"time"
+)
+
+const (
+	// 更换邮箱验证码的有效期
+	emailChangeExpire = 15 * time.Minute
+	// 同一个更换邮箱验证码最多允许输错的次数，超过后验证码作废，需要重新申请
+	emailChangeMaxAttempts = 5
+)
+
+// GenerateEmailChangeCode 为账号生成更换到 newEmail 的 6 位数字验证码（有效期 15 分钟）
+// 验证码和新邮箱一起保存，确认时以保存的新邮箱为准；有效期内为同一个新邮箱重复申请时复用验证码，
+// 换成另一个邮箱时生成新的验证码，之前的验证码作废
+func GenerateEmailChangeCode(username, newEmail string) (string, error) {
+	key := GenerateEmailChange(username)
+	pending, err := Rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return "", err
+	}
+	if pending["email"] == newEmail && pending["code"] != "" {
+		if err := Rdb.Expire(ctx, key, emailChangeExpire).Err(); err != nil {
+			return "", err
+		}
+		return pending["code"], nil
+	}
+
+	code, err := randomDigits(6)
+	if err != nil {
+		return "", err
+	}
+	// 两个 key 在集群模式下不在同一个 slot，输错次数单独删除
+	if err := Rdb.Del(ctx, GenerateEmailChangeAttempts(username)).Err(); err != nil {
+		return "", err
+	}
+	pipe := Rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "email", newEmail, "code", code)
+	pipe.Expire(ctx, key, emailChangeExpire)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// CheckEmailChangeCode 校验更换邮箱验证码，成功时返回申请时的新邮箱；校验成功时不删除验证码，
+// 需要在邮箱真正更新后调用 DeleteEmailChangeCode 使其失效
+// 输错次数达到上限后验证码作废，需要重新申请
+func CheckEmailChangeCode(username, code string) (string, bool, error) {
+	key := GenerateEmailChange(username)
+	pending, err := Rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if pending["code"] == "" || pending["email"] == "" {
+		return "", false, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(pending["code"]), []byte(code)) == 1 {
+		return pending["email"], true, nil
+	}
+
+	attemptsKey := GenerateEmailChangeAttempts(username)
+	attempts, err := Rdb.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if attempts == 1 {
+		if err := Rdb.Expire(ctx, attemptsKey, emailChangeExpire).Err(); err != nil {
+			return "", false, err
+		}
+	}
+	if attempts >= emailChangeMaxAttempts {
+		if err := delKeys(key, attemptsKey); err != nil {
+			return "", false, err
+		}
+	}
+	return "", false, nil
+}
+
+// DeleteEmailChangeCode 邮箱更换成功后使验证码失效
+func DeleteEmailChangeCode(username string) error {
+	return delKeys(GenerateEmailChange(username), GenerateEmailChangeAttempts(username))
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 知识库的“代”：重建索引（例如换用新的向量模型）时，新数据写入一组新的 key 和一个新的索引，
+// 全部写完后再把知识库的索引名（别名）原子地切换到新索引，检索期间不会看到建了一半的索引。
+//   - 第 0 代：索引名为 GenerateIndexName，文档块前缀为 GenerateIndexNamePrefix（重建前的知识库都是第 0 代）
+//   - 第 N 代：索引名为 GenerateIndexName + "#gN"，文档块前缀为 rag_docs:用户名:文件名#gN:，
+//     与第 0 代的前缀互不包含，两代的索引不会收录对方的数据；GenerateIndexName 成为指向该索引的别名
+// 当前代数记录在知识库前缀下的一个字符串 key 中（与文档块在同一个 slot，可以和别名切换放在同一个事务里）
+
+// GenerationIndexName 第 gen 代的实际索引名
+func GenerationIndexName(username, filename string, gen int) string {
+	if gen == 0 {
+		return GenerateIndexName(username, filename)
+	}
+	return fmt.Sprintf("%s#g%d", GenerateIndexName(username, filename), gen)
+}
+
+// GenerationKeyPrefix 第 gen 代文档块的 key 前缀
+func GenerationKeyPrefix(username, filename string, gen int) string {
+	prefix := GenerateIndexNamePrefix(username, filename)
+	if gen == 0 {
+		return prefix
+	}
+	return fmt.Sprintf("%s#g%d:", strings.TrimSuffix(prefix, ":"), gen)
+}
+
+// IndexGeneration 读取知识库当前的代数，没有重建过的知识库为 0
+func IndexGeneration(ctx context.Context, username, filename string) (int, error) {
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return 0, err
+	}
+	gen, err := client.Get(ctx, GenerateIndexGeneration(username, filename)).Int()
+	if err == redisCli.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取索引代数失败: %w", err)
+	}
+	return gen, nil
+}
+
+// CreateGenerationIndex 为第 gen 代创建新的向量索引（gen 必须大于 0）
+// 上一次重建失败留下的同名索引会连同数据一起删除后重新创建
+func CreateGenerationIndex(ctx context.Context, username, filename string, gen, dimension int, opts VectorIndexOptions) error {
+	if gen <= 0 {
+		return fmt.Errorf("索引代数必须大于 0: %d", gen)
+	}
+	opts, err := opts.normalize()
+	if err != nil {
+		return err
+	}
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return err
+	}
+	indexName := GenerationIndexName(username, filename, gen)
+	if err := DropGenerationIndex(ctx, username, filename, gen); err != nil {
+		return err
+	}
+	return createVectorIndex(ctx, client, indexName, GenerationKeyPrefix(username, filename, gen), dimension, opts)
+}
+
+// DropGenerationIndex 删除第 gen 代的索引及其全部文档块，索引不存在时不报错（用于重建失败后回滚）
+func DropGenerationIndex(ctx context.Context, username, filename string, gen int) error {
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return err
+	}
+	err = client.Do(ctx, "FT.DROPINDEX", GenerationIndexName(username, filename, gen), "DD").Err()
+	if err != nil && !isUnknownIndexErr(err) {
+		return fmt.Errorf("删除索引失败: %w", err)
+	}
+	return nil
+}
+
+// SwapIndexGeneration 把知识库的索引名原子地切换到第 newGen 代的索引，并删除第 oldGen 代的索引和数据
+// 第 0 代的索引名本身就是实际索引，第一次切换时在同一个事务中删除它并创建同名别名
+func SwapIndexGeneration(ctx context.Context, username, filename string, oldGen, newGen int) error {
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return err
+	}
+	alias := GenerateIndexName(username, filename)
+	target := GenerationIndexName(username, filename, newGen)
+
+	_, err = client.TxPipelined(ctx, func(pipe redisCli.Pipeliner) error {
+		if oldGen == 0 {
+			pipe.Do(ctx, "FT.DROPINDEX", alias, "DD")
+			pipe.Do(ctx, "FT.ALIASADD", alias, target)
+		} else {
+			pipe.Do(ctx, "FT.ALIASUPDATE", alias, target)
+		}
+		pipe.Set(ctx, GenerateIndexGeneration(username, filename), newGen, 0)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("切换索引失败: %w", err)
+	}
+	if oldGen > 0 {
+		// 别名已经指向新索引，旧索引删除失败只会留下无用的数据
+		if err := DropGenerationIndex(ctx, username, filename, oldGen); err != nil {
+			logger.Warn("drop old index generation failed", "index", GenerationIndexName(username, filename, oldGen),
+				"generation", oldGen, "error", err)
+		}
+	}
+	return nil
+}
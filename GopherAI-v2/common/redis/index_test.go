@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// useTestRedis 把 Rdb 替换为 miniredis 的客户端，ftInfo 为 FT.INFO 的实现（miniredis 不支持 RediSearch）
+func useTestRedis(t *testing.T, ftInfo server.Cmd) *redisCli.Client {
+	t.Helper()
+	m := miniredis.RunT(t)
+	if ftInfo != nil {
+		if err := m.Server().Register("FT.INFO", ftInfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := redisCli.NewClient(&redisCli.Options{Addr: m.Addr()})
+	old := Rdb
+	Rdb = client
+	t.Cleanup(func() {
+		Rdb = old
+		client.Close()
+	})
+	return client
+}
+
+func TestIndexInfoNotFound(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        string // FT.INFO 返回的错误，空表示索引存在
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{"exists", "", false, false},
+		{"unknown index name", "Unknown index name", true, true},
+		{"unknown index name with prefix", "ERR Unknown Index name", true, true},
+		{"no such index", "idx: no such index", true, true},
+		{"other error", "ERR wrong number of arguments", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := useTestRedis(t, func(c *server.Peer, cmd string, args []string) {
+				if tt.reply != "" {
+					c.WriteError(tt.reply)
+					return
+				}
+				c.WriteStrings([]string{"index_name", args[0]})
+			})
+
+			_, err := GetIndexSchema(context.Background(), client, "idx")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetIndexSchema err = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrIndexNotFound) != tt.wantNotFound {
+				t.Errorf("errors.Is(%v, ErrIndexNotFound) = %v, want %v", err, !tt.wantNotFound, tt.wantNotFound)
+			}
+
+			exists, err := IndexExists(context.Background(), "idx")
+			if tt.wantErr && !tt.wantNotFound {
+				if err == nil {
+					t.Error("IndexExists: expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("IndexExists: %v", err)
+			}
+			if exists == tt.wantNotFound {
+				t.Errorf("IndexExists = %v, want %v", exists, !tt.wantNotFound)
+			}
+		})
+	}
+}
+
+// 不同版本的 RediSearch 对不存在的索引返回不同的错误，都应该创建新索引
+func TestInitRedisIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr bool
+	}{
+		{"unknown index name", "Unknown index name", false},
+		{"unknown index name with prefix", "ERR Unknown Index name", false},
+		{"no such index", "idx: no such index", false},
+		{"other error", "ERR wrong number of arguments", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useIndexServer(t)
+			s.UnknownIndexReply = tt.reply
+			ctx := context.Background()
+
+			err := InitRedisIndex(ctx, "alice", "shared.md", 4, VectorIndexOptions{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if got := s.Indexes(); len(got) != 0 {
+					t.Errorf("indexes = %v, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InitRedisIndex: %v", err)
+			}
+			want := []string{GenerateIndexName("alice", "shared.md")}
+			if got := s.Indexes(); !slices.Equal(got, want) {
+				t.Fatalf("indexes = %v, want %v", got, want)
+			}
+
+			// 索引已存在时跳过创建
+			if err := InitRedisIndex(ctx, "alice", "shared.md", 4, VectorIndexOptions{}); err != nil {
+				t.Fatalf("second InitRedisIndex: %v", err)
+			}
+			if got := s.Indexes(); !slices.Equal(got, want) {
+				t.Errorf("indexes = %v, want %v", got, want)
+			}
+		})
+	}
+}
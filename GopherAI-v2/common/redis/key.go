@@ -2,7 +2,12 @@ package redis
 
 import (
 	"GopherAI/config"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // key:特定邮箱-> 验证码
@@ -10,12 +15,223 @@ func GenerateCaptcha(email string) string {
 	return fmt.Sprintf(config.DefaultRedisKeyConfig.CaptchaPrefix, email)
 }
 
-func GenerateIndexName(filename string) string {
-	indexName := fmt.Sprintf(config.DefaultRedisKeyConfig.IndexName, filename)
+// key:特定邮箱-> 发送验证码冷却标记
+func GenerateCaptchaCooldown(email string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.CaptchaCooldownPrefix, email)
+}
+
+// key:特定邮箱-> 一小时内发送验证码的次数
+func GenerateCaptchaHourly(email string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.CaptchaHourlyPrefix, email)
+}
+
+// key:用户 + 文件名 -> 向量索引名，不同用户的同名文件互不冲突
+// 用户名和文件名先经过 EscapeKeyPart 转义，其中的 ":"、空格等字符不会破坏 key 的结构
+func GenerateIndexName(username, filename string) string {
+	indexName := fmt.Sprintf(config.DefaultRedisKeyConfig.IndexName, EscapeKeyPart(username), EscapeKeyPart(filename))
 	return indexName
 }
 
-func GenerateIndexNamePrefix(filename string) string {
-	prefix := fmt.Sprintf(config.DefaultRedisKeyConfig.IndexNamePrefix, filename)
+// key:用户 + 文件名 -> 向量数据的 key 前缀
+// 集群模式下前缀中带有 hash tag，同一知识库的所有文档块都在同一个节点上，索引才能覆盖全部数据
+func GenerateIndexNamePrefix(username, filename string) string {
+	format := config.DefaultRedisKeyConfig.IndexNamePrefix
+	if ClusterEnabled() {
+		format = config.DefaultRedisKeyConfig.ClusterIndexPrefix
+	}
+	prefix := fmt.Sprintf(format, EscapeKeyPart(username), EscapeKeyPart(filename))
 	return prefix
 }
+
+// key:用户 + 文件名 -> 知识库当前的代数（重建索引时递增），位于知识库前缀下，与文档块在同一个 slot
+func GenerateIndexGeneration(username, filename string) string {
+	return GenerateIndexNamePrefix(username, filename) + "generation"
+}
+
+// GenerateLegacyIndexName 旧版本只按文件名生成的索引名，用于查找升级前创建的索引
+// 旧版本的索引名中文件名没有转义，这里保持原样才能找到
+func GenerateLegacyIndexName(filename string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.LegacyIndexName, filename)
+}
+
+// key:模型名 + 文本的 sha256 -> 向量缓存
+func GenerateEmbeddingCacheKey(model, text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.EmbeddingCachePrefix, model, hex.EncodeToString(sum[:]))
+}
+
+// key:索引名 -> 建立该索引时使用的向量模型
+func GenerateIndexEmbedder(indexName string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.IndexEmbedderPrefix, indexName)
+}
+
+// key:索引名 + 检索参数的哈希 -> 缓存的检索结果
+func GenerateResultCacheKey(indexName, hash string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.ResultCachePrefix, indexName, hash)
+}
+
+// key:索引名 -> 检索结果缓存的版本号，知识库内容变化时递增，使旧的缓存失效
+func GenerateResultCacheVersion(indexName string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.ResultCacheVersionPrefix, indexName)
+}
+
+// key:操作 + 用户名 -> 限流令牌桶，不同操作分开计数
+func GenerateRateLimit(operation, username string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.RateLimitPrefix, operation, username)
+}
+
+// key:用户名 -> 知识库配额的使用量
+func GenerateQuotaUser(username string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.QuotaUserPrefix, username)
+}
+
+// key:索引名 -> 索引中每个来源文件的配额使用量
+func GenerateQuotaIndex(indexName string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.QuotaIndexPrefix, indexName)
+}
+
+// ParseIndexName 从索引名中解析出用户名和文件名，不符合 GopherAI 命名规则的索引返回 false
+// 旧版本的索引没有用户名，username 为空；重建后第 N 代的索引（索引名带 #gN 后缀）解析为同一个知识库
+func ParseIndexName(indexName string) (username, filename string, ok bool) {
+	if base, gen, found := strings.Cut(indexName, "#g"); found && gen != "" && strings.Trim(gen, "0123456789") == "" {
+		indexName = base
+	}
+	if parts, ok := matchKeyFormat(config.DefaultRedisKeyConfig.IndexName, indexName); ok {
+		username, err1 := UnescapeKeyPart(parts[0])
+		filename, err2 := UnescapeKeyPart(parts[1])
+		if err1 != nil || err2 != nil {
+			return "", "", false
+		}
+		return username, filename, true
+	}
+	if parts, ok := matchKeyFormat(config.DefaultRedisKeyConfig.LegacyIndexName, indexName); ok {
+		return "", parts[0], true
+	}
+	return "", "", false
+}
+
+// EscapeKeyPart 转义 key 和索引名中的用户名、文件名，结果只包含字母、数字（含中文等 Unicode 字母）、"."、"_"、"-" 和 "%"
+// 其它字符（key 分隔符 ":"、集群 hash tag 的 "{}"、SCAN 通配符、"#"、空白和控制字符等）按 UTF-8 字节编码为 %XX，
+// 可以用 UnescapeKeyPart 还原；只含安全字符的名字（例如上传时生成的 UUID 文件名）转义前后相同，已有的 key 不受影响
+func EscapeKeyPart(s string) string {
+	if strings.IndexFunc(s, needsKeyEscape) < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if !needsKeyEscape(r) {
+			sb.WriteRune(r)
+			continue
+		}
+		var buf [utf8.UTFMax]byte
+		for _, b := range buf[:utf8.EncodeRune(buf[:], r)] {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+// UnescapeKeyPart 还原 EscapeKeyPart 转义的名字，% 后面不是两位十六进制数时返回错误
+func UnescapeKeyPart(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out = append(out, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("无效的转义序列: %q", s)
+		}
+		b, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("无效的转义序列: %q", s)
+		}
+		out = append(out, b[0])
+		i += 2
+	}
+	return string(out), nil
+}
+
+// needsKeyEscape 判断字符是否需要转义
+func needsKeyEscape(r rune) bool {
+	if r == '.' || r == '_' || r == '-' {
+		return false
+	}
+	return r == utf8.RuneError || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// matchKeyFormat 按 key 格式（以 %s 作为占位符）拆解 key，返回各占位符对应的值
+// 占位符对应的值不能为空；对于用户名等中间占位符，遇到第一个分隔符即截断
+func matchKeyFormat(format, key string) ([]string, bool) {
+	literals := strings.Split(format, "%s")
+	if len(literals) < 2 {
+		return nil, false
+	}
+	first, last := literals[0], literals[len(literals)-1]
+	if len(key) < len(first)+len(last) || !strings.HasPrefix(key, first) || !strings.HasSuffix(key, last) {
+		return nil, false
+	}
+	rest := key[len(first) : len(key)-len(last)]
+
+	values := make([]string, 0, len(literals)-1)
+	for _, sep := range literals[1 : len(literals)-1] {
+		value, remain, found := strings.Cut(rest, sep)
+		if !found || value == "" {
+			return nil, false
+		}
+		values = append(values, value)
+		rest = remain
+	}
+	if rest == "" {
+		return nil, false
+	}
+	return append(values, rest), true
+}
+
+// key:账号 -> 时间窗口内登录失败的次数
+func GenerateLoginFail(id string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.LoginFailPrefix, id)
+}
+
+// key:账号 -> 登录锁定标记
+func GenerateLoginLock(id string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.LoginLockPrefix, id)
+}
+
+// key:邮箱 -> 重置密码验证码
+func GeneratePasswordReset(email string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.PasswordResetPrefix, email)
+}
+
+// key:邮箱 -> 重置密码验证码输错的次数
+func GeneratePasswordResetAttempts(email string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.PasswordResetAttemptsPrefix, email)
+}
+
+// key:账号 -> 待确认的新邮箱和更换邮箱验证码
+func GenerateEmailChange(username string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.EmailChangePrefix, username)
+}
+
+// key:账号 -> 更换邮箱验证码输错的次数
+func GenerateEmailChangeAttempts(username string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.EmailChangeAttemptsPrefix, username)
+}
+
+// key:刷新令牌链ID -> 账号与当前有效的刷新令牌
+func GenerateRefreshToken(chainID string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.RefreshTokenPrefix, chainID)
+}
+
+// key:账号 -> 该账号所有刷新令牌链ID的集合
+func GenerateRefreshTokenUser(username string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.RefreshTokenUserPrefix, username)
+}
+
+// key:会话ID -> RAG 多轮对话历史
+func GenerateRAGHistory(sessionID string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.RAGHistoryPrefix, sessionID)
+}
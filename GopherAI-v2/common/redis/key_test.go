@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"GopherAI/config"
+	"strings"
+	"testing"
+)
+
+func TestEscapeKeyPart(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // 空表示只检查不含特殊字符和可以还原
+	}{
+		{"safe name unchanged", "3f2a-uuid_v4.pdf", "3f2a-uuid_v4.pdf"},
+		{"chinese unchanged", "产品手册.md", "产品手册.md"},
+		{"space", "my notes.md", "my%20notes.md"},
+		{"colon", "a:b.md", "a%3Ab.md"},
+		{"percent", "100%.md", "100%25.md"},
+		{"hash tag braces", "{tag}.md", "%7Btag%7D.md"},
+		{"glob characters", "*?[x].md", ""},
+		{"generation suffix", "report#g1.md", "report%23g1.md"},
+		{"emoji", "📄 notes.md", ""},
+		{"full width colon", "会议：纪要.md", ""},
+		{"windows path", `C:\docs\a.md`, ""},
+		{"newline", "a\nb", "a%0Ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EscapeKeyPart(tt.in)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("EscapeKeyPart(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if strings.ContainsAny(got, ": {}*?[]#\\\n") {
+				t.Errorf("EscapeKeyPart(%q) = %q contains a special character", tt.in, got)
+			}
+			back, err := UnescapeKeyPart(got)
+			if err != nil || back != tt.in {
+				t.Errorf("UnescapeKeyPart(%q) = %q, %v, want %q", got, back, err, tt.in)
+			}
+		})
+	}
+}
+
+func TestUnescapeKeyPartInvalid(t *testing.T) {
+	for _, in := range []string{"a%", "a%2", "a%zz", "%g0"} {
+		if got, err := UnescapeKeyPart(in); err == nil {
+			t.Errorf("UnescapeKeyPart(%q) = %q, want error", in, got)
+		}
+	}
+}
+
+func TestIndexNameRoundTrip(t *testing.T) {
+	config.SetConfig(&config.Config{})
+	tests := []struct {
+		username string
+		filename string
+	}{
+		{"alice", "notes.md"},
+		{"alice", "my notes (final).md"},
+		{"alice", "a:b:c.md"},
+		{"alice:admin", "notes.md"},
+		{"张三", "产品 手册：第一版.pdf"},
+		{"bob", "report#g2.md"},
+		{"bob", "50% off {sale}.txt"},
+	}
+	seen := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.username+"/"+tt.filename, func(t *testing.T) {
+			indexName := GenerateIndexName(tt.username, tt.filename)
+			if prev, ok := seen[indexName]; ok {
+				t.Fatalf("index name %q shared with %s", indexName, prev)
+			}
+			seen[indexName] = tt.username + "/" + tt.filename
+
+			username, filename, ok := ParseIndexName(indexName)
+			if !ok || username != tt.username || filename != tt.filename {
+				t.Errorf("ParseIndexName(%q) = %q, %q, %v, want %q, %q", indexName, username, filename, ok, tt.username, tt.filename)
+			}
+			// 知识库的 key 前缀中，用户名和文件名各占一段，不会与其它知识库的前缀重叠；
+			// 集群模式下 hash tag 只有一对花括号，同一知识库的 key 落在同一个 slot
+			for _, cluster := range []bool{false, true} {
+				conf := &config.Config{}
+				conf.RedisConfig.RedisClusterMode = cluster
+				conf.RedisConfig.RedisClusterAddrs = []string{"127.0.0.1:7000"}
+				config.SetConfig(conf)
+				prefix := GenerateIndexNamePrefix(tt.username, tt.filename)
+				if got := strings.Count(prefix, ":"); got != 3 {
+					t.Errorf("cluster=%v: prefix %q has %d separators, want 3", cluster, prefix, got)
+				}
+				if braces := strings.Count(prefix, "{") + strings.Count(prefix, "}"); cluster && braces != 2 || !cluster && braces != 0 {
+					t.Errorf("cluster=%v: prefix %q has %d braces", cluster, prefix, braces)
+				}
+			}
+			config.SetConfig(&config.Config{})
+		})
+	}
+
+	// 转义之前 "x" + "a:b" 与 "x:a" + "b" 会得到同一个索引名
+	if GenerateIndexName("x", "a:b") == GenerateIndexName("x:a", "b") {
+		t.Error("different users and files share an index name")
+	}
+}
@@ -0,0 +1,18 @@
+package redis
+
+import (
+	"io"
+	"log/slog"
+)
+
+// logger redis 包使用的日志记录器，默认使用 slog.Default()
+var logger = slog.Default()
+
+// SetLogger 替换 redis 包使用的日志记录器，传入 nil 时不输出任何日志（便于测试）
+// 应在初始化阶段调用
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	logger = l
+}
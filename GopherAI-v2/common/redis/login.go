@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"GopherAI/config"
+	"log"
+	"time"
+)
+
+// 登录失败锁定的默认配置
+const (
+	defaultMaxFailedLogins   = 5
+	defaultFailedLoginWindow = 15 * time.Minute
+	defaultLoginLockDuration = 30 * time.Minute
+)
+
+// loginLockPolicy 读取登录锁定配置，未配置的项使用默认值
+func loginLockPolicy() (maxFailed int64, window, lock time.Duration) {
+	conf := config.GetConfig().LoginSecurityConfig
+	maxFailed, window, lock = defaultMaxFailedLogins, defaultFailedLoginWindow, defaultLoginLockDuration
+	if conf.MaxFailedAttempts > 0 {
+		maxFailed = int64(conf.MaxFailedAttempts)
+	}
+	if conf.FailWindowMinutes > 0 {
+		window = time.Duration(conf.FailWindowMinutes) * time.Minute
+	}
+	if conf.LockMinutes > 0 {
+		lock = time.Duration(conf.LockMinutes) * time.Minute
+	}
+	return maxFailed, window, lock
+}
+
+// RecordFailedLogin 记录一次密码错误
+// 时间窗口（默认 15 分钟）内失败次数达到上限（默认 5 次）时锁定账号（默认 30 分钟），并清空失败计数
+func RecordFailedLogin(id string) error {
+	maxFailed, window, lock := loginLockPolicy()
+
+	failKey := GenerateLoginFail(id)
+	count, err := Rdb.Incr(ctx, failKey).Result()
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		if err := Rdb.Expire(ctx, failKey, window).Err(); err != nil {
+			return err
+		}
+	}
+	if count < maxFailed {
+		return nil
+	}
+
+	if err := Rdb.Set(ctx, GenerateLoginLock(id), 1, lock).Err(); err != nil {
+		return err
+	}
+	return Rdb.Del(ctx, failKey).Err()
+}
+
+// IsLocked 判断账号是否因多次登录失败被锁定，锁定时同时返回剩余锁定时间
+// 查询 Redis 失败时不锁定，避免 Redis 故障导致所有用户无法登录
+func IsLocked(id string) (bool, time.Duration) {
+	_, _, lock := loginLockPolicy()
+
+	lockKey := GenerateLoginLock(id)
+	n, err := Rdb.Exists(ctx, lockKey).Result()
+	if err != nil {
+		log.Printf("check login lock for %s failed: %v", id, err)
+		return false, 0
+	}
+	if n == 0 {
+		return false, 0
+	}
+	return true, remainingTTL(lockKey, lock)
+}
+
+// ResetFailedLogin 登录成功后清空失败计数
+func ResetFailedLogin(id string) error {
+	return Rdb.Del(ctx, GenerateLoginFail(id)).Err()
+}
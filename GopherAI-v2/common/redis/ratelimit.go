@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 令牌桶：桶中最多 capacity 个令牌，每 interval 毫秒补充一个，每次请求取走 cost 个
+// 使用 Redis 服务器的时间，多个实例之间的时钟偏差不影响计数；返回 0 表示放行，否则为需要等待的毫秒数
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) / interval)
+if tokens < cost then
+	return math.max(math.ceil((cost - tokens) * interval), 1)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens - cost), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity * interval))
+return 0
+`
+
+// TakeRateLimitToken 从 key 对应的令牌桶中取走 cost 个令牌，桶容量为 perMinute，每分钟补满
+// 令牌不足时不扣减，返回需要等待的时间；令牌足够时返回 0
+// 桶在补满所需的时间后过期，长时间不请求的用户不占用内存
+func TakeRateLimitToken(ctx context.Context, key string, perMinute, cost int) (time.Duration, error) {
+	if perMinute <= 0 {
+		return 0, fmt.Errorf("限流速率必须大于 0: %d", perMinute)
+	}
+	if cost > perMinute {
+		// 超过桶容量的请求永远无法满足
+		return 0, fmt.Errorf("请求的令牌数 %d 超过每分钟上限 %d", cost, perMinute)
+	}
+	interval := float64(time.Minute.Milliseconds()) / float64(perMinute)
+	wait, err := Rdb.Eval(ctx, tokenBucketScript, []string{key}, perMinute, interval, cost).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("限流检查失败: %w", err)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
@@ -3,19 +3,30 @@ package redis
 import (
 	"GopherAI/config"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	redisCli "github.com/redis/go-redis/v9"
 )
 
-var Rdb *redisCli.Client
+// Rdb 全局 Redis 客户端，单机模式下为 *redisCli.Client，集群模式下为 *redisCli.ClusterClient
+var Rdb redisCli.UniversalClient
 
 var ctx = context.Background()
 
-func Init() {
+// Init 初始化 Redis 客户端，并通过 PING 验证连接、认证和 TLS 配置是否正确
+func Init() error {
 	conf := config.GetConfig()
 	host := conf.RedisConfig.RedisHost
 	port := conf.RedisConfig.RedisPort
@@ -23,100 +34,666 @@ func Init() {
 	db := conf.RedisDb
 	addr := host + ":" + strconv.Itoa(port)
 
+	tlsConfig, err := newTLSConfig(conf.RedisConfig)
+	if err != nil {
+		return err
+	}
+
+	if ClusterEnabled() {
+		Rdb = newClusterClient(conf.RedisConfig, tlsConfig)
+		return ping(strings.Join(conf.RedisClusterAddrs, ","))
+	}
+
 	Rdb = redisCli.NewClient(&redisCli.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-		Protocol: 2, // 使用 Protocol 2 避免 maint_notifications 警告
+		Addr:      addr,
+		Username:  conf.RedisUsername,
+		Password:  password,
+		DB:        db,
+		Protocol:  2, // 使用 Protocol 2 避免 maint_notifications 警告
+		TLSConfig: tlsConfig,
+
+		PoolSize:     conf.RedisPoolSize,
+		DialTimeout:  millis(conf.RedisDialTimeout),
+		ReadTimeout:  millis(conf.RedisReadTimeout),
+		WriteTimeout: millis(conf.RedisWriteTimeout),
+		MaxRetries:   conf.RedisMaxRetries,
+		// 调用方传入的 ctx 带有超时时，以 ctx 的超时为准，避免 Redis 卡住时请求一直阻塞
+		ContextTimeoutEnabled: true,
 	})
 
+	return ping(addr)
+}
+
+// Redis 初始化时 PING 的超时时间
+const pingTimeout = 5 * time.Second
+
+// ping 验证 Redis 连接，并将常见的认证、TLS 错误转换为可操作的提示
+func ping(addr string) error {
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	err := Rdb.Ping(pingCtx).Err()
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "WRONGPASS") || strings.Contains(msg, "NOAUTH") || strings.Contains(msg, "invalid password"):
+		return fmt.Errorf("Redis 认证失败，请检查 redisConfig 中的 username / password: %w", err)
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "x509:"):
+		return fmt.Errorf("Redis TLS 握手失败，请检查 useTLS / caCert 配置以及服务端是否开启 TLS: %w", err)
+	default:
+		return fmt.Errorf("连接 Redis(%s) 失败: %w", addr, err)
+	}
+}
+
+// newTLSConfig 根据配置构建 TLS 配置，未开启 TLS 时返回 nil
+func newTLSConfig(conf config.RedisConfig) (*tls.Config, error) {
+	if !conf.RedisUseTLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: conf.RedisHost,
+	}
+	if conf.RedisCACert != "" {
+		pem, err := os.ReadFile(conf.RedisCACert)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Redis CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("解析 Redis CA 证书失败: %s 中没有有效的 PEM 证书", conf.RedisCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// CloseRedis 关闭 Redis 连接池，用于服务退出时释放连接
+func CloseRedis() error {
+	if Rdb == nil {
+		return nil
+	}
+	return Rdb.Close()
+}
+
+// millis 将毫秒数转换为 time.Duration
+func millis(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
 }
 
-func SetCaptchaForEmail(email, captcha string) error {
-	key := GenerateCaptcha(email)
-	expire := 2 * time.Minute
-	return Rdb.Set(ctx, key, captcha, expire).Err()
+// 邮箱验证码有效期，与邮件中提示的“2分钟有效”保持一致
+const captchaExpire = 2 * time.Minute
+
+// GenerateVerificationCode 为邮箱生成 6 位数字验证码并存入 Redis（有效期 2 分钟）
+// 有效期内重复请求时不会生成新验证码，而是返回同一个验证码并将有效期重新计为 2 分钟，
+// 这样用户无论使用哪一封邮件中的验证码都可以通过校验
+func GenerateVerificationCode(email string) (string, error) {
+	return generateCode(GenerateCaptcha(email), captchaExpire)
 }
 
-func CheckCaptchaForEmail(email, userInput string) (bool, error) {
-	key := GenerateCaptcha(email)
+// VerifyCode 校验邮箱验证码，校验成功后删除验证码，保证只能使用一次
+func VerifyCode(email, code string) (bool, error) {
+	return verifyCode(GenerateCaptcha(email), code, true)
+}
 
-	storedCaptcha, err := Rdb.Get(ctx, key).Result()
+// generateCode 生成 6 位数字验证码并以 key 存入 Redis，已存在时复用并重置有效期
+func generateCode(key string, expire time.Duration) (string, error) {
+	code, err := Rdb.Get(ctx, key).Result()
+	if err == nil {
+		if err := Rdb.Expire(ctx, key, expire).Err(); err != nil {
+			return "", err
+		}
+		return code, nil
+	}
+	if err != redisCli.Nil {
+		return "", err
+	}
+
+	code, err = randomDigits(6)
 	if err != nil {
-		if err == redisCli.Nil {
+		return "", err
+	}
+	// SetNX 防止并发请求互相覆盖验证码，写入失败说明已有其它请求生成了验证码
+	ok, err := Rdb.SetNX(ctx, key, code, expire).Result()
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return Rdb.Get(ctx, key).Result()
+	}
+	return code, nil
+}
 
+// verifyCode 校验 key 中存储的验证码，consume 为 true 时校验成功后删除验证码
+func verifyCode(key, code string, consume bool) (bool, error) {
+	storedCode, err := Rdb.Get(ctx, key).Result()
+	if err != nil {
+		if err == redisCli.Nil {
 			return false, nil
 		}
-
 		return false, err
 	}
 
-	if strings.EqualFold(storedCaptcha, userInput) {
+	if subtle.ConstantTimeCompare([]byte(storedCode), []byte(code)) != 1 {
+		return false, nil
+	}
 
-		// 验证成功后删除 key
+	// 验证成功后删除 key
+	if consume {
 		if err := Rdb.Del(ctx, key).Err(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+const (
+	// 同一邮箱两次发送验证码的最小间隔
+	captchaCooldown = 60 * time.Second
+	// 同一邮箱每小时最多发送的验证码次数
+	captchaHourlyLimit = 5
+)
 
-		} else {
+// ErrRateLimited 请求过于频繁，可通过 errors.Is 判断
+var ErrRateLimited = errors.New("rate limited")
 
+// RateLimitError 限流错误，RetryAfter 为距离下次允许请求的等待时间
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// CheckCaptchaRateLimit 检查邮箱是否可以发送验证码：
+// 每 60 秒最多一次，每小时最多 5 次，超出时返回 *RateLimitError
+// 计数器都带有过期时间，到期后自动失效
+func CheckCaptchaRateLimit(email string) error {
+	// 1：冷却时间内不允许再次发送
+	cooldownKey := GenerateCaptchaCooldown(email)
+	ok, err := Rdb.SetNX(ctx, cooldownKey, 1, captchaCooldown).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &RateLimitError{RetryAfter: remainingTTL(cooldownKey, captchaCooldown)}
+	}
+
+	// 2：一小时内的发送次数
+	hourlyKey := GenerateCaptchaHourly(email)
+	count, err := Rdb.Incr(ctx, hourlyKey).Result()
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		if err := Rdb.Expire(ctx, hourlyKey, time.Hour).Err(); err != nil {
+			return err
 		}
-		return true, nil
 	}
+	if count > captchaHourlyLimit {
+		return &RateLimitError{RetryAfter: remainingTTL(hourlyKey, time.Hour)}
+	}
+	return nil
+}
+
+// remainingTTL 获取 key 的剩余有效期，获取失败时返回 fallback
+func remainingTTL(key string, fallback time.Duration) time.Duration {
+	ttl, err := Rdb.TTL(ctx, key).Result()
+	if err != nil || ttl <= 0 {
+		return fallback
+	}
+	return ttl
+}
 
-	return false, nil
+// randomDigits 使用密码学安全的随机数生成 n 位数字
+func randomDigits(n int) (string, error) {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	return string(digits), nil
 }
 
-// InitRedisIndex 初始化 Redis 索引，支持按文件名区分
-func InitRedisIndex(ctx context.Context, filename string, dimension int) error {
-	indexName := GenerateIndexName(filename)
+// ErrIndexNotFound 索引不存在，可通过 errors.Is 判断
+var ErrIndexNotFound = errors.New("index not found")
+
+// isUnknownIndexErr 判断 RediSearch 返回的错误是否为“索引不存在”
+func isUnknownIndexErr(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unknown index name") || strings.Contains(msg, "no such index")
+}
 
-	// 检查索引是否存在
-	_, err := Rdb.Do(ctx, "FT.INFO", indexName).Result()
+// ResolveIndexName 查找用户某个文件实际使用的索引名
+// 优先使用按用户区分的新索引；不存在时兼容旧版本只按文件名创建的索引，
+// 但只有文件在该用户的上传目录中时才使用旧索引，避免通过文件名访问其他用户的知识库；
+// 都不满足时返回新索引名
+func ResolveIndexName(ctx context.Context, username, filename string) (string, error) {
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return "", err
+	}
+
+	indexName := GenerateIndexName(username, filename)
+	exists, err := indexExists(ctx, client, indexName)
+	if err != nil || exists {
+		return indexName, err
+	}
+
+	if !ownsUploadedFile(username, filename) {
+		return indexName, nil
+	}
+	legacyName := GenerateLegacyIndexName(filename)
+	exists, err = indexExists(ctx, client, legacyName)
+	if err != nil {
+		return indexName, err
+	}
+	if exists {
+		return legacyName, nil
+	}
+	return indexName, nil
+}
+
+// ownsUploadedFile 判断 filename 是否是用户上传目录中的文件
+// 旧索引名不含用户名，只能通过上传目录确认文件属于该用户
+func ownsUploadedFile(username, filename string) bool {
+	if filename == "" || filename != filepath.Base(filename) || filename == "." || filename == ".." {
+		return false
+	}
+	dir, err := config.GetConfig().UserUploadDir(username)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, filename))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// IndexExists 判断索引是否存在，集群模式下到索引所在的节点查询
+func IndexExists(ctx context.Context, indexName string) (bool, error) {
+	var client redisCli.UniversalClient = Rdb
+	if username, filename, ok := ParseIndexName(indexName); ok {
+		nodeClient, err := IndexClient(ctx, username, filename)
+		if err != nil {
+			return false, err
+		}
+		client = nodeClient
+	}
+	return indexExists(ctx, client, indexName)
+}
+
+// indexExists 通过 FT.INFO 判断索引是否存在
+func indexExists(ctx context.Context, client redisCli.UniversalClient, indexName string) (bool, error) {
+	err := client.Do(ctx, "FT.INFO", indexName).Err()
 	if err == nil {
-		fmt.Println("索引已存在，跳过创建")
+		return true, nil
+	}
+	if isUnknownIndexErr(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("检查索引失败: %w", err)
+}
+
+// GetRedisIndexInfo 执行 FT.INFO 并将结果解析为 字段名 -> 值
+// 索引不存在时返回 ErrIndexNotFound
+func GetRedisIndexInfo(ctx context.Context, username, filename string) (map[string]interface{}, error) {
+	indexName, err := ResolveIndexName(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return nil, err
+	}
+	return indexInfo(ctx, client, indexName)
+}
+
+// GetIndexSchema 返回索引中各字段的类型（字段名 -> TEXT / TAG / NUMERIC / VECTOR）
+// client 为索引所在节点的客户端（见 IndexClient）
+func GetIndexSchema(ctx context.Context, client redisCli.UniversalClient, indexName string) (map[string]string, error) {
+	info, err := indexInfo(ctx, client, indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldTypes := make(map[string]string)
+	attributes, _ := info["attributes"].([]interface{})
+	for _, attr := range attributes {
+		fields := toFieldMap(attr)
+		name := fmt.Sprint(fields["attribute"])
+		if name == "" || name == "<nil>" {
+			name = fmt.Sprint(fields["identifier"])
+		}
+		fieldTypes[name] = strings.ToUpper(fmt.Sprint(fields["type"]))
+	}
+	return fieldTypes, nil
+}
+
+// AddIndexTagFields 为索引添加 TAG 字段，已经在索引结构中的字段跳过
+// 添加后检索时可以按这些字段过滤，已有的文档块会由 RediSearch 在后台重新索引；indexName 可以是别名
+func AddIndexTagFields(ctx context.Context, client redisCli.UniversalClient, indexName string, fields []string) error {
+	info, err := indexInfo(ctx, client, indexName)
+	if err != nil {
+		return err
+	}
+	existing, err := GetIndexSchema(ctx, client, indexName)
+	if err != nil {
+		return err
+	}
+	// FT.ALTER 需要实际的索引名，别名要先解析
+	name := indexName
+	if n, ok := info["index_name"]; ok {
+		name = fmt.Sprint(n)
+	}
+
+	args := []interface{}{"FT.ALTER", name, "SCHEMA", "ADD"}
+	for _, field := range fields {
+		if _, ok := existing[field]; ok {
+			continue
+		}
+		existing[field] = "TAG"
+		args = append(args, field, "TAG")
+	}
+	if len(args) == 4 {
 		return nil
 	}
+	if err := client.Do(ctx, args...).Err(); err != nil {
+		return fmt.Errorf("添加索引字段失败: %w", err)
+	}
+	return nil
+}
+
+// GetIndexDistanceMetric 从索引结构中读取向量字段的距离度量（COSINE / L2 / IP）
+// 读不到时按 COSINE 处理（早期版本创建的索引都使用余弦距离）
+func GetIndexDistanceMetric(ctx context.Context, client redisCli.UniversalClient, indexName string) (string, error) {
+	info, err := indexInfo(ctx, client, indexName)
+	if err != nil {
+		return "", err
+	}
+	attributes, _ := info["attributes"].([]interface{})
+	for _, attr := range attributes {
+		fields := toFieldMap(attr)
+		if strings.ToUpper(fmt.Sprint(fields["type"])) != "VECTOR" {
+			continue
+		}
+		if metric, ok := fields["distance_metric"]; ok {
+			return strings.ToUpper(fmt.Sprint(metric)), nil
+		}
+	}
+	return DistanceCosine, nil
+}
+
+// indexInfo 执行 FT.INFO，索引不存在时返回 ErrIndexNotFound
+func indexInfo(ctx context.Context, client redisCli.UniversalClient, indexName string) (map[string]interface{}, error) {
+	res, err := client.Do(ctx, "FT.INFO", indexName).Result()
+	if err != nil {
+		if isUnknownIndexErr(err) {
+			return nil, fmt.Errorf("%s: %w", indexName, ErrIndexNotFound)
+		}
+		return nil, fmt.Errorf("查询索引信息失败: %w", err)
+	}
+	return toFieldMap(res), nil
+}
+
+// toFieldMap 将 RESP2 的 [k1, v1, k2, v2, ...] 或 RESP3 的 map 结构统一转换为 map
+func toFieldMap(res interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	switch v := res.(type) {
+	case []interface{}:
+		for i := 0; i+1 < len(v); i += 2 {
+			fields[fmt.Sprint(v[i])] = v[i+1]
+		}
+	case map[interface{}]interface{}:
+		for k, val := range v {
+			fields[fmt.Sprint(k)] = val
+		}
+	}
+	return fields
+}
+
+// ListRedisIndexes 通过 FT._LIST 列出 Redis 中所有的索引名
+// 集群模式下索引分布在各个主节点上，需要逐个节点查询后合并
+func ListRedisIndexes(ctx context.Context) ([]string, error) {
+	cluster, ok := Rdb.(*redisCli.ClusterClient)
+	if !ok {
+		names, err := Rdb.Do(ctx, "FT._LIST").StringSlice()
+		if err != nil {
+			return nil, fmt.Errorf("列出索引失败: %w", err)
+		}
+		return names, nil
+	}
+
+	var (
+		mu    sync.Mutex
+		names []string
+		seen  = make(map[string]bool)
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redisCli.Client) error {
+		nodeNames, err := client.Do(ctx, "FT._LIST").StringSlice()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, name := range nodeNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列出索引失败: %w", err)
+	}
+	return names, nil
+}
+
+// 向量索引算法
+const (
+	VectorAlgorithmFLAT = "FLAT" // 暴力检索，结果精确，适合小知识库
+	VectorAlgorithmHNSW = "HNSW" // 近似最近邻检索，大知识库检索延迟低得多
+)
+
+// 向量距离度量，RediSearch 返回的距离都是越小越相似
+const (
+	DistanceCosine = "COSINE" // 1 - 余弦相似度，取值 0-2
+	DistanceL2     = "L2"     // 欧氏距离的平方
+	DistanceIP     = "IP"     // 1 - 内积，只有向量已归一化时才等价于余弦距离
+)
+
+// HNSW 参数的默认值（与 RediSearch 的默认值一致）
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEFConstruction = 200
+	defaultHNSWEFRuntime      = 10
+)
+
+// VectorIndexOptions 向量索引的算法和参数，零值表示使用 FLAT 算法和余弦距离
+// M / EFConstruction / EFRuntime 只对 HNSW 有效，为 0 时使用默认值 16 / 200 / 10
+type VectorIndexOptions struct {
+	Algorithm      string // FLAT（默认）/ HNSW，不区分大小写
+	DistanceMetric string // COSINE（默认）/ L2 / IP，不区分大小写
+	M              int    // 每个节点最多的邻居数，取值 2-512
+	EFConstruction int    // 建图时的候选数，取值 1-4096，越大召回率越高、建索引越慢
+	EFRuntime      int    // 检索时的候选数，取值 1-4096，越大召回率越高、检索越慢
+	// NoContentText content 字段不建全文索引（原文压缩存储时），这样的索引不支持按 content 做关键词检索
+	NoContentText bool
+}
+
+// normalize 校验参数并填充默认值
+func (o VectorIndexOptions) normalize() (VectorIndexOptions, error) {
+	o.DistanceMetric = strings.ToUpper(strings.TrimSpace(o.DistanceMetric))
+	switch o.DistanceMetric {
+	case "":
+		o.DistanceMetric = DistanceCosine
+	case DistanceCosine, DistanceL2, DistanceIP:
+	default:
+		return o, fmt.Errorf("未知的向量距离度量: %s", o.DistanceMetric)
+	}
+
+	o.Algorithm = strings.ToUpper(strings.TrimSpace(o.Algorithm))
+	switch o.Algorithm {
+	case "":
+		o.Algorithm = VectorAlgorithmFLAT
+		fallthrough
+	case VectorAlgorithmFLAT:
+		if o.M != 0 || o.EFConstruction != 0 || o.EFRuntime != 0 {
+			return o, fmt.Errorf("FLAT 索引不支持 HNSW 参数")
+		}
+		return o, nil
+	case VectorAlgorithmHNSW:
+	default:
+		return o, fmt.Errorf("未知的向量索引算法: %s", o.Algorithm)
+	}
+
+	if o.M == 0 {
+		o.M = defaultHNSWM
+	}
+	if o.EFConstruction == 0 {
+		o.EFConstruction = defaultHNSWEFConstruction
+	}
+	if o.EFRuntime == 0 {
+		o.EFRuntime = defaultHNSWEFRuntime
+	}
+	if o.M < 2 || o.M > 512 {
+		return o, fmt.Errorf("HNSW 参数 M=%d 不合法，取值范围 2-512", o.M)
+	}
+	if o.EFConstruction < 1 || o.EFConstruction > 4096 {
+		return o, fmt.Errorf("HNSW 参数 EF_CONSTRUCTION=%d 不合法，取值范围 1-4096", o.EFConstruction)
+	}
+	if o.EFRuntime < 1 || o.EFRuntime > 4096 {
+		return o, fmt.Errorf("HNSW 参数 EF_RUNTIME=%d 不合法，取值范围 1-4096", o.EFRuntime)
+	}
+	return o, nil
+}
 
-	// 如果索引不存在，创建新索引
-	if !strings.Contains(err.Error(), "Unknown index name") {
-		return fmt.Errorf("检查索引失败: %w", err)
+// vectorFieldArgs 生成 FT.CREATE 中向量字段的定义
+func (o VectorIndexOptions) vectorFieldArgs(dimension int) []interface{} {
+	attrs := []interface{}{
+		"TYPE", "FLOAT32",
+		"DIM", dimension,
+		"DISTANCE_METRIC", o.DistanceMetric,
+	}
+	if o.Algorithm == VectorAlgorithmHNSW {
+		attrs = append(attrs,
+			"M", o.M,
+			"EF_CONSTRUCTION", o.EFConstruction,
+			"EF_RUNTIME", o.EFRuntime,
+		)
 	}
+	args := []interface{}{"vector", "VECTOR", o.Algorithm, len(attrs)}
+	return append(args, attrs...)
+}
 
-	fmt.Println("正在创建 Redis 索引...")
+// InitRedisIndex 初始化 Redis 索引，按用户 + 文件名区分
+// opts 指定向量索引的算法和参数，只在创建新索引时生效；已有索引不会被修改
+func InitRedisIndex(ctx context.Context, username, filename string, dimension int, opts VectorIndexOptions) error {
+	opts, err := opts.normalize()
+	if err != nil {
+		return err
+	}
+	indexName := GenerateIndexName(username, filename)
 
-	prefix := GenerateIndexNamePrefix(filename)
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return err
+	}
 
-	// 创建索引
+	// 检查索引是否存在，不存在时创建新索引
+	exists, err := indexExists(ctx, client, indexName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logger.Debug("index already exists, skip creating", "index", indexName)
+		return nil
+	}
+
+	if err := createVectorIndex(ctx, client, indexName, GenerateIndexNamePrefix(username, filename), dimension, opts); err != nil {
+		return err
+	}
+	logger.Info("index created", "index", indexName, "dimension", dimension, "algorithm", opts.Algorithm)
+	return nil
+}
+
+// createVectorIndex 创建知识库的向量索引，prefix 为索引覆盖的文档块 key 前缀，opts 需要已经校验过
+func createVectorIndex(ctx context.Context, client *redisCli.Client, indexName, prefix string, dimension int, opts VectorIndexOptions) error {
 	createArgs := []interface{}{
 		"FT.CREATE", indexName,
 		"ON", "HASH",
 		"PREFIX", "1", prefix,
 		"SCHEMA",
-		"content", "TEXT",
-		"metadata", "TEXT",
-		"vector", "VECTOR", "FLAT",
-		"6",
-		"TYPE", "FLOAT32",
-		"DIM", dimension,
-		"DISTANCE_METRIC", "COSINE",
 	}
+	if !opts.NoContentText {
+		createArgs = append(createArgs, "content", "TEXT")
+	}
+	createArgs = append(createArgs,
+		"metadata", "TEXT",
+		"heading", "TEXT",
+		"title", "TEXT",
+		"lang", "TAG",
+		"chunk_index", "NUMERIC",
+		"page", "NUMERIC",
+	)
+	createArgs = append(createArgs, opts.vectorFieldArgs(dimension)...)
 
-	if err := Rdb.Do(ctx, createArgs...).Err(); err != nil {
+	if err := client.Do(ctx, createArgs...).Err(); err != nil {
 		return fmt.Errorf("创建索引失败: %w", err)
 	}
-
-	fmt.Println("索引创建成功！")
 	return nil
 }
 
-// DeleteRedisIndex 删除 Redis 索引，支持按文件名区分
-func DeleteRedisIndex(ctx context.Context, filename string) error {
-	indexName := GenerateIndexName(filename)
+// DeleteRedisIndex 删除 Redis 索引及其全部文档块，按用户 + 文件名区分（兼容旧版本只按文件名创建的索引）
+// 索引不存在时返回 ErrIndexNotFound
+func DeleteRedisIndex(ctx context.Context, username, filename string) error {
+	indexName, err := ResolveIndexName(ctx, username, filename)
+	if err != nil {
+		return err
+	}
+	client, err := IndexClient(ctx, username, filename)
+	if err != nil {
+		return err
+	}
+
+	// 重建过的知识库，索引名是指向第 N 代索引的别名
+	gen, err := IndexGeneration(ctx, username, filename)
+	if err != nil {
+		return err
+	}
+	if gen > 0 {
+		// 上一次删除在别名删除后失败时，别名已经不存在，继续删除实际索引
+		err := client.Do(ctx, "FT.ALIASDEL", indexName).Err()
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "alias does not exist") {
+			return fmt.Errorf("删除索引别名失败: %w", err)
+		}
+		indexName = GenerationIndexName(username, filename, gen)
+	}
 
-	// 删除索引
-	if err := Rdb.Do(ctx, "FT.DROPINDEX", indexName).Err(); err != nil {
+	// 删除索引，DD 同时删除索引收录的全部文档块（内容、元数据和向量）
+	err = client.Do(ctx, "FT.DROPINDEX", indexName, "DD").Err()
+	notFound := err != nil && isUnknownIndexErr(err)
+	if err != nil && !notFound {
 		return fmt.Errorf("删除索引失败: %w", err)
 	}
+	if gen > 0 {
+		if err := client.Del(ctx, GenerateIndexGeneration(username, filename)).Err(); err != nil {
+			return fmt.Errorf("删除索引代数失败: %w", err)
+		}
+	}
+	if notFound {
+		return fmt.Errorf("%s: %w", indexName, ErrIndexNotFound)
+	}
 
-	fmt.Println("索引删除成功！")
+	logger.Info("index deleted", "index", indexName)
 	return nil
 }
@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"GopherAI/common/redis/redistest"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOwnsUploadedFile(t *testing.T) {
+	base := t.TempDir()
+	conf := &config.Config{}
+	conf.UploadDir = base
+	config.SetConfig(conf)
+
+	// alice 上传了 shared.md，bob 没有上传任何文件
+	if err := os.MkdirAll(filepath.Join(base, "alice"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "alice", "shared.md"), []byte("# alice"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(base, "bob", "dir.md"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		filename string
+		want     bool
+	}{
+		{"owner", "alice", "shared.md", true},
+		{"other user same filename", "bob", "shared.md", false},
+		{"missing file", "alice", "other.md", false},
+		{"directory is not a file", "bob", "dir.md", false},
+		{"path traversal in filename", "bob", "../alice/shared.md", false},
+		{"path traversal in username", "../alice", "shared.md", false},
+		{"empty filename", "alice", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownsUploadedFile(tt.username, tt.filename); got != tt.want {
+				t.Errorf("ownsUploadedFile(%q, %q) = %v, want %v", tt.username, tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+// useIndexServer 把 Rdb 替换为带 RediSearch 命令的 miniredis，测试结束后恢复
+func useIndexServer(t *testing.T) *redistest.Server {
+	t.Helper()
+	config.SetConfig(&config.Config{})
+	SetLogger(nil)
+	s := redistest.Run(t)
+	old := Rdb
+	Rdb = s.Client(t)
+	t.Cleanup(func() { Rdb = old })
+	return s
+}
+
+// addChunks 在 prefix 下写入 n 个文档块
+func addChunks(t *testing.T, s *redistest.Server, prefix string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		s.HSet(prefix+string(rune('a'+i)), "content", "chunk", "vector", "\x00\x00")
+	}
+}
+
+func TestDeleteRedisIndex(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		gen  int // 0 表示没有重建过
+	}{
+		{"never rebuilt", 0},
+		{"rebuilt", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useIndexServer(t)
+			aliceIndex := GenerationIndexName("alice", "shared.md", tt.gen)
+			alicePrefix := GenerationKeyPrefix("alice", "shared.md", tt.gen)
+			s.AddIndex(aliceIndex, alicePrefix)
+			addChunks(t, s, alicePrefix, 3)
+			if tt.gen > 0 {
+				if err := Rdb.Do(ctx, "FT.ALIASADD", GenerateIndexName("alice", "shared.md"), aliceIndex).Err(); err != nil {
+					t.Fatal(err)
+				}
+				s.Set(GenerateIndexGeneration("alice", "shared.md"), "2")
+			}
+			// 另一个用户的同名知识库不受影响
+			bobPrefix := GenerateIndexNamePrefix("bob", "shared.md")
+			s.AddIndex(GenerateIndexName("bob", "shared.md"), bobPrefix)
+			addChunks(t, s, bobPrefix, 2)
+
+			if err := DeleteRedisIndex(ctx, "alice", "shared.md"); err != nil {
+				t.Fatalf("DeleteRedisIndex: %v", err)
+			}
+			if keys := s.KeysWithPrefix(alicePrefix); len(keys) != 0 {
+				t.Errorf("chunks left after delete: %v", keys)
+			}
+			if s.Exists(GenerateIndexGeneration("alice", "shared.md")) {
+				t.Error("generation key left after delete")
+			}
+			if got := s.Indexes(); len(got) != 1 || got[0] != GenerateIndexName("bob", "shared.md") {
+				t.Errorf("indexes = %v, want only bob's", got)
+			}
+			if keys := s.KeysWithPrefix(bobPrefix); len(keys) != 2 {
+				t.Errorf("bob's chunks = %v, want 2", keys)
+			}
+
+			// 重复删除返回 ErrIndexNotFound，调用方可以当作已经删除
+			if err := DeleteRedisIndex(ctx, "alice", "shared.md"); !errors.Is(err, ErrIndexNotFound) {
+				t.Errorf("second delete err = %v, want ErrIndexNotFound", err)
+			}
+		})
+	}
+}
+
+func TestDeleteRedisIndexNotFound(t *testing.T) {
+	for _, reply := range []string{"Unknown index name", "idx: no such index"} {
+		t.Run(reply, func(t *testing.T) {
+			s := useIndexServer(t)
+			s.UnknownIndexReply = reply
+			if err := DeleteRedisIndex(context.Background(), "alice", "missing.md"); !errors.Is(err, ErrIndexNotFound) {
+				t.Errorf("err = %v, want ErrIndexNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package redistest 在 miniredis 上模拟 GopherAI 用到的少量 RediSearch 命令，供其它包的测试使用
+// 只记录索引名和文档块前缀，不做真正的检索：
+//   - FT._LIST / FT.INFO / FT.CREATE（只解析 PREFIX）
+//   - FT.DROPINDEX [DD]：带 DD 时删除前缀下的全部 key
+//   - FT.ALIASADD / FT.ALIASUPDATE / FT.ALIASDEL
+package redistest
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// Server 带有 RediSearch 命令的 miniredis
+type Server struct {
+	*miniredis.Miniredis
+
+	// UnknownIndexReply 索引不存在时返回的错误，不同版本的 RediSearch 不一样
+	UnknownIndexReply string
+
+	mu      sync.Mutex
+	indexes map[string]string // 索引名 -> 文档块前缀
+	aliases map[string]string // 别名 -> 索引名
+}
+
+// Run 启动 Server，测试结束时自动关闭
+func Run(t *testing.T) *Server {
+	t.Helper()
+	s := &Server{
+		Miniredis:         miniredis.RunT(t),
+		UnknownIndexReply: "Unknown index name",
+		indexes:           make(map[string]string),
+		aliases:           make(map[string]string),
+	}
+	for name, cmd := range map[string]server.Cmd{
+		"FT._LIST":       s.cmdList,
+		"FT.INFO":        s.cmdInfo,
+		"FT.CREATE":      s.cmdCreate,
+		"FT.DROPINDEX":   s.cmdDropIndex,
+		"FT.ALIASADD":    s.cmdAliasAdd,
+		"FT.ALIASUPDATE": s.cmdAliasAdd,
+		"FT.ALIASDEL":    s.cmdAliasDel,
+	} {
+		if err := s.Server().Register(name, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// Client 返回连接到 Server 的客户端（与生产环境一样使用 RESP2），测试结束时自动关闭
+func (s *Server) Client(t *testing.T) *redisCli.Client {
+	t.Helper()
+	client := redisCli.NewClient(&redisCli.Options{Addr: s.Addr(), Protocol: 2})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// AddIndex 直接登记一个索引，相当于 FT.CREATE name ON HASH PREFIX 1 prefix
+func (s *Server) AddIndex(name, prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes[name] = prefix
+}
+
+// Indexes 返回当前的索引名（按字典序）
+func (s *Server) Indexes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.indexes))
+	for name := range s.indexes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// KeysWithPrefix 返回以 prefix 开头的 key（按字典序）
+func (s *Server) KeysWithPrefix(prefix string) []string {
+	var keys []string
+	for _, key := range s.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// resolve 把别名解析为索引名，调用方需要持有 s.mu
+func (s *Server) resolve(name string) (string, bool) {
+	if target, ok := s.aliases[name]; ok {
+		name = target
+	}
+	_, ok := s.indexes[name]
+	return name, ok
+}
+
+func (s *Server) cmdList(c *server.Peer, cmd string, args []string) {
+	c.WriteStrings(s.Indexes())
+}
+
+func (s *Server) cmdInfo(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	s.mu.Lock()
+	name, ok := s.resolve(args[0])
+	s.mu.Unlock()
+	if !ok {
+		c.WriteError(s.UnknownIndexReply)
+		return
+	}
+	c.WriteLen(4)
+	c.WriteBulk("index_name")
+	c.WriteBulk(name)
+	c.WriteBulk("attributes")
+	c.WriteLen(0)
+}
+
+func (s *Server) cmdCreate(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	prefix := ""
+	if i := slices.Index(args, "PREFIX"); i >= 0 && i+2 < len(args) {
+		prefix = args[i+2]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.indexes[args[0]]; ok {
+		c.WriteError("Index already exists")
+		return
+	}
+	s.indexes[args[0]] = prefix
+	c.WriteOK()
+}
+
+func (s *Server) cmdDropIndex(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	s.mu.Lock()
+	prefix, ok := s.indexes[args[0]]
+	delete(s.indexes, args[0])
+	s.mu.Unlock()
+	if !ok {
+		c.WriteError(s.UnknownIndexReply)
+		return
+	}
+	if len(args) > 1 && strings.EqualFold(args[1], "DD") {
+		for _, key := range s.KeysWithPrefix(prefix) {
+			s.Del(key)
+		}
+	}
+	c.WriteOK()
+}
+
+func (s *Server) cmdAliasAdd(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.indexes[args[1]]; !ok {
+		c.WriteError(s.UnknownIndexReply)
+		return
+	}
+	s.aliases[args[0]] = args[1]
+	c.WriteOK()
+}
+
+func (s *Server) cmdAliasDel(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.aliases[args[0]]; !ok {
+		c.WriteError("Alias does not exist")
+		return
+	}
+	delete(s.aliases, args[0])
+	c.WriteOK()
+}
@@ -0,0 +1,61 @@
+package redis
+
+import "time"
+
+const (
+	// 重置密码验证码的有效期
+	passwordResetExpire = 15 * time.Minute
+	// 同一个重置验证码最多允许输错的次数，超过后验证码作废，防止暴力猜测
+	passwordResetMaxAttempts = 5
+)
+
+// GeneratePasswordResetCode 为邮箱生成重置密码的 6 位数字验证码（有效期 15 分钟）
+func GeneratePasswordResetCode(email string) (string, error) {
+	if err := Rdb.Del(ctx, GeneratePasswordResetAttempts(email)).Err(); err != nil {
+		return "", err
+	}
+	return generateCode(GeneratePasswordReset(email), passwordResetExpire)
+}
+
+// CheckPasswordResetCode 校验重置密码验证码，校验成功时不删除验证码，
+// 需要在密码真正更新后调用 DeletePasswordResetCode 使其失效
+// 输错次数达到上限后验证码作废，需要重新申请
+func CheckPasswordResetCode(email, code string) (bool, error) {
+	key := GeneratePasswordReset(email)
+	ok, err := verifyCode(key, code, false)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	attemptsKey := GeneratePasswordResetAttempts(email)
+	attempts, err := Rdb.Incr(ctx, attemptsKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if attempts == 1 {
+		if err := Rdb.Expire(ctx, attemptsKey, passwordResetExpire).Err(); err != nil {
+			return false, err
+		}
+	}
+	if attempts >= passwordResetMaxAttempts {
+		if err := delKeys(key, attemptsKey); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// DeletePasswordResetCode 密码重置成功后使验证码失效
+func DeletePasswordResetCode(email string) error {
+	return delKeys(GeneratePasswordReset(email), GeneratePasswordResetAttempts(email))
+}
+
+// delKeys 逐个删除 key：集群模式下这些 key 通常不在同一个 slot，一条 DEL 删除多个 key 会返回 CROSSSLOT 错误
+func delKeys(keys ...string) error {
+	pipe := Rdb.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
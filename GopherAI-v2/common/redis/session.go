@@ -0,0 +1,197 @@
+package redis
+
+import (
+	"GopherAI/config"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	redisCli "github.com/redis/go-redis/v9"
+)
+
+// 未配置 refresh_expire_duration 时刷新令牌的默认有效期
+const defaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrRefreshTokenInvalid 刷新令牌不存在、已过期或已注销
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效或已过期")
+	// ErrRefreshTokenReused 已轮换的刷新令牌被再次使用，整条令牌链已被注销
+	ErrRefreshTokenReused = errors.New("刷新令牌被重复使用，已注销该登录会话")
+)
+
+// 刷新令牌的格式为 "链ID.令牌ID"
+// 同一次登录后续轮换出的令牌属于同一条链，链在 Redis 中保存为一个 hash：
+// user -> 账号，current -> 当前有效的令牌ID
+// 只有 current 对应的令牌可以刷新；拿着已轮换掉的令牌来刷新说明令牌可能已泄露，直接注销整条链
+// 每个账号的链ID另外记录在一个集合中（refresh:user:账号），修改密码、注销账号时据此注销该账号的所有链；
+// 集合中可能残留已过期或已注销的链ID，注销时删除不存在的 key 不影响结果
+
+// rotateRefreshScript 原子地校验并轮换刷新令牌
+// 返回账号表示轮换成功，0 表示链不存在，-1 表示令牌已被使用过（链已被删除）
+const rotateRefreshScript = `
+local cur = redis.call('HGET', KEYS[1], 'current')
+if not cur then
+	return 0
+end
+if cur ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'current', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return redis.call('HGET', KEYS[1], 'user')
+`
+
+// refreshTokenTTL 返回配置的刷新令牌有效期（refresh_expire_duration，单位小时）
+func refreshTokenTTL() time.Duration {
+	if hours := config.GetConfig().RefreshExpireDuration; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultRefreshTokenTTL
+}
+
+// CreateRefreshToken 为账号创建一条新的刷新令牌链，返回第一个刷新令牌
+func CreateRefreshToken(username string) (string, error) {
+	chainID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	tokenID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := refreshTokenTTL()
+	key := GenerateRefreshToken(chainID)
+	pipe := Rdb.TxPipeline()
+	pipe.HSet(ctx, key, "user", username, "current", tokenID)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	// 集群模式下账号的集合与链不在同一个 slot，单独写入；集合的有效期跟随最近创建或轮换的链
+	if err := trackRefreshChain(username, chainID, ttl); err != nil {
+		Rdb.Del(ctx, key)
+		return "", err
+	}
+	return chainID + "." + tokenID, nil
+}
+
+// RotateRefreshToken 使用刷新令牌换取新的刷新令牌，旧令牌随即失效
+// 令牌无效时返回 ErrRefreshTokenInvalid；已轮换的令牌被再次使用时注销整条链并返回 ErrRefreshTokenReused
+func RotateRefreshToken(refreshToken string) (username, newToken string, err error) {
+	chainID, tokenID, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return "", "", ErrRefreshTokenInvalid
+	}
+	newID, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	ttl := refreshTokenTTL()
+	res, err := Rdb.Eval(ctx, rotateRefreshScript, []string{GenerateRefreshToken(chainID)},
+		tokenID, newID, ttl.Milliseconds()).Result()
+	if err != nil {
+		return "", "", err
+	}
+	switch v := res.(type) {
+	case string:
+		if err := Rdb.Expire(ctx, GenerateRefreshTokenUser(v), ttl).Err(); err != nil {
+			return "", "", err
+		}
+		return v, chainID + "." + newID, nil
+	case int64:
+		if v < 0 {
+			return "", "", ErrRefreshTokenReused
+		}
+		return "", "", ErrRefreshTokenInvalid
+	}
+	return "", "", fmt.Errorf("刷新令牌轮换返回了未知结果: %v", res)
+}
+
+// RevokeRefreshToken 注销刷新令牌所在的整条链（退出登录）
+// 令牌格式错误时返回 ErrRefreshTokenInvalid，链已不存在时不返回错误
+func RevokeRefreshToken(refreshToken string) error {
+	chainID, _, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return ErrRefreshTokenInvalid
+	}
+	key := GenerateRefreshToken(chainID)
+	username, err := Rdb.HGet(ctx, key, "user").Result()
+	if err == redisCli.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := Rdb.Del(ctx, key).Err(); err != nil {
+		return err
+	}
+	return Rdb.SRem(ctx, GenerateRefreshTokenUser(username), chainID).Err()
+}
+
+// trackRefreshChain 把链ID加入账号的集合
+func trackRefreshChain(username, chainID string, ttl time.Duration) error {
+	key := GenerateRefreshTokenUser(username)
+	pipe := Rdb.TxPipeline()
+	pipe.SAdd(ctx, key, chainID)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RevokeUserRefreshTokens 注销账号的所有刷新令牌链（修改密码、重置密码、注销账号时调用），返回注销的链数
+// 已注销的链再拿来刷新时返回 ErrRefreshTokenInvalid
+func RevokeUserRefreshTokens(ctx context.Context, username string) (int, error) {
+	setKey := GenerateRefreshTokenUser(username)
+	chainIDs, err := Rdb.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("注销刷新令牌失败: %w", err)
+	}
+	// 集群模式下每条链在各自的 slot，逐个删除
+	pipe := Rdb.Pipeline()
+	dels := make([]*redisCli.IntCmd, len(chainIDs))
+	for i, chainID := range chainIDs {
+		dels[i] = pipe.Del(ctx, GenerateRefreshToken(chainID))
+	}
+	pipe.Del(ctx, setKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("注销刷新令牌失败: %w", err)
+	}
+	revoked := 0
+	for _, cmd := range dels {
+		revoked += int(cmd.Val())
+	}
+	return revoked, nil
+}
+
+// parseRefreshToken 拆分刷新令牌中的链ID和令牌ID
+func parseRefreshToken(refreshToken string) (chainID, tokenID string, ok bool) {
+	chainID, tokenID, ok = strings.Cut(refreshToken, ".")
+	if !ok || !isHex(chainID) || !isHex(tokenID) {
+		return "", "", false
+	}
+	return chainID, tokenID, true
+}
+
+// randomHex 生成 n 字节的随机数并以十六进制表示
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isHex(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
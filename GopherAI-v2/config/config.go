@@ -1,7 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 )
@@ -10,11 +16,46 @@ type MainConfig struct {
 	Port    int    `toml:"port"`
 	AppName string `toml:"appName"`
 	Host    string `toml:"host"`
+	// UploadDir 用户上传文件的根目录，每个用户一个子目录；为空时使用工作目录下的 uploads
+	// 工作目录不固定或同一台机器运行多个实例时应配置为绝对路径
+	UploadDir string `toml:"uploadDir"`
+}
+
+// 默认的上传文件根目录
+const defaultUploadDir = "uploads"
+
+// ErrInvalidUploadUser 用户名不能作为上传目录名（为空、包含路径分隔符或 ..），可通过 errors.Is 判断
+var ErrInvalidUploadUser = errors.New("invalid username for upload directory")
+
+// UploadBaseDir 返回上传文件的根目录
+func (c MainConfig) UploadBaseDir() string {
+	if c.UploadDir == "" {
+		return defaultUploadDir
+	}
+	return c.UploadDir
+}
+
+// UserUploadDir 返回用户的上传目录 UploadBaseDir/用户名
+// 用户名必须是单独的一级目录名，"../etc"、"a/b"、".." 等会跳出或穿越根目录的用户名返回 ErrInvalidUploadUser
+func (c MainConfig) UserUploadDir(username string) (string, error) {
+	if username == "" || username == "." || username == ".." ||
+		strings.ContainsAny(username, "/\\\x00") || filepath.VolumeName(username) != "" {
+		return "", fmt.Errorf("%q: %w", username, ErrInvalidUploadUser)
+	}
+	base := c.UploadBaseDir()
+	dir := filepath.Join(base, username)
+	// 兜底检查：拼接后的目录必须仍在根目录下
+	if rel, err := filepath.Rel(base, dir); err != nil || rel != username {
+		return "", fmt.Errorf("%q: %w", username, ErrInvalidUploadUser)
+	}
+	return dir, nil
 }
 
 type EmailConfig struct {
 	Authcode string `toml:"authcode"`
 	Email    string `toml:"email" `
+	SmtpHost string `toml:"smtpHost"` // SMTP 服务器，默认 smtp.qq.com
+	SmtpPort int    `toml:"smtpPort"` // SMTP 端口，默认 587
 }
 
 type RedisConfig struct {
@@ -22,6 +63,19 @@ type RedisConfig struct {
 	RedisDb       int    `toml:"db"`
 	RedisHost     string `toml:"host"`
 	RedisPassword string `toml:"password"`
+	RedisUsername string `toml:"username"` // Redis 6 ACL 用户名，为空时只使用密码认证
+	// TLS 配置（托管 Redis 通常需要开启）
+	RedisUseTLS bool   `toml:"useTLS"`
+	RedisCACert string `toml:"caCert"` // 自定义 CA 证书路径，为空时使用系统证书
+	// 集群模式：开启后连接 clusterAddrs 中的节点，host / port / db 不再生效
+	RedisClusterMode  bool     `toml:"clusterMode"`
+	RedisClusterAddrs []string `toml:"clusterAddrs"`
+	// 连接池与超时配置，为 0 时使用 go-redis 的默认值
+	RedisPoolSize     int `toml:"poolSize"`     // 连接池大小，默认每个 CPU 10 个连接
+	RedisDialTimeout  int `toml:"dialTimeout"`  // 建立连接超时（毫秒），默认 5000
+	RedisReadTimeout  int `toml:"readTimeout"`  // 读超时（毫秒），默认 3000
+	RedisWriteTimeout int `toml:"writeTimeout"` // 写超时（毫秒），默认与读超时相同
+	RedisMaxRetries   int `toml:"maxRetries"`   // 命令失败时的最大重试次数，默认 3，-1 表示不重试
 }
 
 type MysqlConfig struct {
@@ -34,10 +88,11 @@ type MysqlConfig struct {
 }
 
 type JwtConfig struct {
-	ExpireDuration int    `toml:"expire_duration"`
-	Issuer         string `toml:"issuer"`
-	Subject        string `toml:"subject"`
-	Key            string `toml:"key"`
+	ExpireDuration        int    `toml:"expire_duration"`         // 访问令牌有效期（小时）
+	RefreshExpireDuration int    `toml:"refresh_expire_duration"` // 刷新令牌有效期（小时）
+	Issuer                string `toml:"issuer"`
+	Subject               string `toml:"subject"`
+	Key                   string `toml:"key"`
 }
 
 type Rabbitmq struct {
@@ -54,6 +109,89 @@ type RagModelConfig struct {
 	RagDocDir         string `toml:"docDir"`
 	RagBaseUrl        string `toml:"baseUrl"`
 	RagDimension      int    `toml:"dimension"`
+	// 新建知识库时把向量截断到的维度（截断后重新归一化），0 表示不降维；必须小于 dimension，
+	// 只有支持截断的模型才能使用，支持的模型和维度见 common/rag/dimension.go
+	RagReducedDimension int `toml:"reducedDimension"`
+	// 向量模型服务：ark（默认）/ openai / ollama
+	RagEmbeddingProvider string `toml:"embeddingProvider"`
+	// 向量模型服务地址，为空时使用 baseUrl
+	RagEmbeddingBaseUrl string `toml:"embeddingBaseUrl"`
+	// 向量缓存时间（秒），0 表示使用默认值 24 小时
+	RagEmbeddingCacheTTL int `toml:"embeddingCacheTTL"`
+	// 检索结果缓存时间（秒），0 表示不缓存检索结果
+	RagResultCacheTTL int `toml:"resultCacheTTL"`
+	// 复用的 RAG 查询器空闲多久（秒）后关闭，0 表示使用默认值 300，小于 0 表示不复用查询器
+	RagQueryPoolIdleTTL int `toml:"queryPoolIdleTTL"`
+	// 调用向量模型遇到临时错误时最多尝试的次数，0 表示使用默认值 3
+	RagEmbeddingMaxAttempts int `toml:"embeddingMaxAttempts"`
+	// 单次向量模型请求最多包含的文本数，超过时拆成多次请求，0 表示使用默认值 64
+	RagMaxEmbeddingInputs int `toml:"maxEmbeddingInputs"`
+	// 重排序模型（可选），未配置时无法开启重排序
+	RagRerankBaseUrl string `toml:"rerankBaseUrl"`
+	RagRerankModel   string `toml:"rerankModel"`
+	// 调用重排序模型的超时时间（毫秒），0 表示使用默认值 10000
+	RagRerankTimeout int `toml:"rerankTimeout"`
+	// 健康检查超时时间（毫秒），0 表示使用默认值 2000
+	RagHealthCheckTimeout int `toml:"healthCheckTimeout"`
+	// 用户登录后是否在后台预热 RAG 查询（见 rag.Warmup），减少第一次提问的延迟
+	RagWarmupOnLogin bool `toml:"warmupOnLogin"`
+	// 每个用户每分钟最多检索的次数（令牌桶，允许一分钟额度的突发），0 表示不限制
+	RagQueryRateLimit int `toml:"queryRateLimit"`
+	// 每个用户每分钟最多开始索引的次数（每次上传一个文件），与检索分开限制，0 表示不限制
+	RagIndexRateLimit int `toml:"indexRateLimit"`
+	// 问答时对话模型的采样温度，不配置时使用模型默认值
+	RagChatTemperature *float32 `toml:"chatTemperature"`
+	// 问答时最多生成的 token 数，0 表示使用模型默认值
+	RagChatMaxTokens int `toml:"chatMaxTokens"`
+	// 抓取网页的超时时间（毫秒），0 表示使用默认值 15000
+	RagFetchTimeout int `toml:"fetchTimeout"`
+	// 抓取网页时最多读取的字节数，0 表示使用默认值 5MB
+	RagFetchMaxBytes int64 `toml:"fetchMaxBytes"`
+	// 向量模型每百万 token 的价格，用于估算索引费用，0 表示不估算费用
+	RagEmbeddingPricePerMillionTokens float64 `toml:"embeddingPricePerMillionTokens"`
+	// 访问模型服务（向量模型、对话模型、重排序模型）的 API Key，为空时读取环境变量 OPENAI_API_KEY
+	RagApiKey string `toml:"apiKey"`
+	// 向量模型单独使用的 API Key，为空时使用 apiKey
+	RagEmbeddingApiKey string `toml:"embeddingApiKey"`
+	// 备用向量模型（例如本地 Ollama），主向量模型服务不可用时用于新建的知识库，维度必须与 dimension 相同
+	RagFallbackEmbeddingProvider string `toml:"fallbackEmbeddingProvider"`
+	RagFallbackEmbeddingBaseUrl  string `toml:"fallbackEmbeddingBaseUrl"`
+	RagFallbackEmbeddingModel    string `toml:"fallbackEmbeddingModel"` // 为空表示不使用备用向量模型
+	// 重排序模型或对话模型调用失败时的处理方式：degrade（默认，重排序失败时返回向量检索的顺序，
+	// 问答时对话模型失败返回检索到的参考文档和 rag.ErrGenerationUnavailable）/ fail（直接返回错误）
+	RagModelFailureMode string `toml:"modelFailureMode"`
+	// 向量存储：redis（默认）或 memory（进程内存，不需要 Redis，只支持向量检索，重启后数据丢失）
+	RagVectorStore string `toml:"vectorStore"`
+	// 文档块原文（content 字段）写入 Redis 前的压缩方式：空（不压缩）/ gzip，只对之后新建的知识库生效，
+	// 压缩的知识库不支持关键词检索（见 rag 包 compress.go）
+	RagContentCompression string `toml:"contentCompression"`
+	// 新建 Redis 向量索引使用的算法：FLAT（默认）或 HNSW，大知识库建议使用 HNSW
+	RagVectorIndexAlgorithm string `toml:"vectorIndexAlgorithm"`
+	// 新建 Redis 向量索引使用的距离度量：COSINE（默认）/ L2 / IP，如何选择见 common/rag/distance.go
+	RagDistanceMetric string `toml:"distanceMetric"`
+	// HNSW 参数，0 表示使用默认值（M=16，EF_CONSTRUCTION=200，EF_RUNTIME=10）
+	RagHnswM              int `toml:"hnswM"`
+	RagHnswEfConstruction int `toml:"hnswEfConstruction"`
+	RagHnswEfRuntime      int `toml:"hnswEfRuntime"`
+}
+
+// 未配置 apiKey 时读取的环境变量
+const apiKeyEnv = "OPENAI_API_KEY"
+
+// APIKey 返回访问模型服务的 API Key，未配置 apiKey 时读取环境变量 OPENAI_API_KEY
+func (c RagModelConfig) APIKey() string {
+	if c.RagApiKey != "" {
+		return c.RagApiKey
+	}
+	return os.Getenv(apiKeyEnv)
+}
+
+// EmbeddingAPIKey 返回向量模型使用的 API Key，未配置 embeddingApiKey 时与 APIKey 相同
+func (c RagModelConfig) EmbeddingAPIKey() string {
+	if c.RagEmbeddingApiKey != "" {
+		return c.RagEmbeddingApiKey
+	}
+	return c.APIKey()
 }
 
 type VoiceServiceConfig struct {
@@ -61,45 +199,171 @@ type VoiceServiceConfig struct {
 	VoiceServiceSecretKey string `toml:"voiceServiceSecretKey"`
 }
 
+// LoginSecurityConfig 登录失败锁定配置，为 0 的项使用默认值
+type LoginSecurityConfig struct {
+	MaxFailedAttempts int `toml:"maxFailedAttempts"` // 时间窗口内允许的最大失败次数，默认 5
+	FailWindowMinutes int `toml:"failWindowMinutes"` // 失败次数统计窗口（分钟），默认 15
+	LockMinutes       int `toml:"lockMinutes"`       // 锁定时长（分钟），默认 30
+}
+
+// QuotaLimits 单个用户的知识库配额，0 表示不限制
+type QuotaLimits struct {
+	MaxFiles  int64 `toml:"maxFiles"`  // 最多索引的文件数
+	MaxBytes  int64 `toml:"maxBytes"`  // 已索引文件的总字节数上限
+	MaxChunks int64 `toml:"maxChunks"` // 最多存储的文档块数
+}
+
+// QuotaConfig 知识库配额：全局配额对所有用户生效，users 中可以为个别用户单独设置
+type QuotaConfig struct {
+	QuotaLimits
+	// Users 按用户名覆盖全局配额，未设置（为 0）的项沿用全局配额，-1 表示对该用户不限制
+	Users map[string]QuotaLimits `toml:"users"`
+}
+
+// Limits 返回用户实际生效的配额，0 表示不限制
+func (c QuotaConfig) Limits(username string) QuotaLimits {
+	limits := c.QuotaLimits
+	override, ok := c.Users[username]
+	if !ok {
+		return limits
+	}
+	pick := func(global, user int64) int64 {
+		switch {
+		case user < 0:
+			return 0
+		case user > 0:
+			return user
+		}
+		return global
+	}
+	return QuotaLimits{
+		MaxFiles:  pick(limits.MaxFiles, override.MaxFiles),
+		MaxBytes:  pick(limits.MaxBytes, override.MaxBytes),
+		MaxChunks: pick(limits.MaxChunks, override.MaxChunks),
+	}
+}
+
 type Config struct {
-	EmailConfig        `toml:"emailConfig"`
-	RedisConfig        `toml:"redisConfig"`
-	MysqlConfig        `toml:"mysqlConfig"`
-	JwtConfig          `toml:"jwtConfig"`
-	MainConfig         `toml:"mainConfig"`
-	Rabbitmq           `toml:"rabbitmqConfig"`
-	RagModelConfig     `toml:"ragModelConfig"`
-	VoiceServiceConfig `toml:"voiceServiceConfig"`
+	EmailConfig         `toml:"emailConfig"`
+	RedisConfig         `toml:"redisConfig"`
+	MysqlConfig         `toml:"mysqlConfig"`
+	JwtConfig           `toml:"jwtConfig"`
+	MainConfig          `toml:"mainConfig"`
+	Rabbitmq            `toml:"rabbitmqConfig"`
+	RagModelConfig      `toml:"ragModelConfig"`
+	VoiceServiceConfig  `toml:"voiceServiceConfig"`
+	LoginSecurityConfig `toml:"loginSecurityConfig"`
+	QuotaConfig         `toml:"quotaConfig"`
 }
 
 type RedisKeyConfig struct {
-	CaptchaPrefix   string
-	IndexName       string
-	IndexNamePrefix string
+	CaptchaPrefix               string
+	CaptchaCooldownPrefix       string
+	CaptchaHourlyPrefix         string
+	LoginFailPrefix             string
+	LoginLockPrefix             string
+	PasswordResetPrefix         string
+	PasswordResetAttemptsPrefix string
+	EmailChangePrefix           string
+	EmailChangeAttemptsPrefix   string
+	RefreshTokenPrefix          string
+	RefreshTokenUserPrefix      string
+	RAGHistoryPrefix            string
+	IndexName                   string
+	IndexNamePrefix             string
+	ClusterIndexPrefix          string
+	LegacyIndexName             string
+	EmbeddingCachePrefix        string
+	IndexEmbedderPrefix         string
+	QuotaUserPrefix             string
+	QuotaIndexPrefix            string
+	ResultCachePrefix           string
+	ResultCacheVersionPrefix    string
+	RateLimitPrefix             string
 }
 
 var DefaultRedisKeyConfig = RedisKeyConfig{
-	CaptchaPrefix:   "captcha:%s",
-	IndexName:       "rag_docs:%s:idx",
-	IndexNamePrefix: "rag_docs:%s:",
+	CaptchaPrefix:               "captcha:%s",
+	CaptchaCooldownPrefix:       "captcha:cooldown:%s",
+	CaptchaHourlyPrefix:         "captcha:hourly:%s",
+	LoginFailPrefix:             "login:fail:%s",
+	LoginLockPrefix:             "login:lock:%s",
+	PasswordResetPrefix:         "reset:%s",
+	PasswordResetAttemptsPrefix: "reset:attempts:%s",
+	EmailChangePrefix:           "email:change:%s", // 账号 -> 待确认的新邮箱和验证码
+	EmailChangeAttemptsPrefix:   "email:change:attempts:%s",
+	RefreshTokenPrefix:          "refresh:{%s}",    // 刷新令牌链ID，hash tag 保证同一条链在同一个 slot
+	RefreshTokenUserPrefix:      "refresh:user:%s", // 账号 -> 该账号所有刷新令牌链ID的集合
+	RAGHistoryPrefix:            "rag:history:%s",
+	IndexName:                   "rag_docs:%s:%s:idx", // 用户名 + 文件名
+	IndexNamePrefix:             "rag_docs:%s:%s:",
+	ClusterIndexPrefix:          "rag_docs:{%s:%s}:", // 集群模式下用 hash tag 让同一知识库的数据落在同一个 slot
+	LegacyIndexName:             "rag_docs:%s:idx",   // 旧版本只按文件名区分，仅用于兼容已有索引
+	EmbeddingCachePrefix:        "embedding:%s:%s",   // 模型名 + sha256(文本)
+	IndexEmbedderPrefix:         "rag:embedder:%s",   // 索引名 -> 建立索引时使用的向量模型
+	ResultCachePrefix:           "rag:result:%s:%s",  // 索引名 + sha256(版本号、问题和检索参数)
+	ResultCacheVersionPrefix:    "rag:result:version:%s",
+	QuotaUserPrefix:             "quota:user:%s",   // 用户名 -> 已使用的文件数、字节数、文档块数
+	QuotaIndexPrefix:            "quota:index:%s",  // 索引名 -> 每个来源文件占用的字节数和文档块数
+	RateLimitPrefix:             "ratelimit:%s:%s", // 操作（query / index） + 用户名 -> 令牌桶
 }
 
-var config *Config
+// 配置文件路径（相对于 main.go 所在的目录）
+const configPath = "config/config.toml"
+
+var (
+	// mu 保护 config 指针：重新加载时整体替换为新的 *Config，不修改旧值，
+	// 读者拿到的 *Config 始终是一份完整的配置
+	mu     sync.RWMutex
+	config *Config
+)
 
 // InitConfig 初始化项目配置
 func InitConfig() error {
-	// 设置配置文件路径（相对于 main.go 所在的目录）
-	if _, err := toml.DecodeFile("config/config.toml", config); err != nil {
+	conf, err := loadConfig(configPath)
+	if err != nil {
 		log.Fatal(err.Error())
 		return err
 	}
+	mu.Lock()
+	config = conf
+	mu.Unlock()
 	return nil
 }
 
+// SetConfig 替换当前配置，用于测试或不从文件加载配置的场景
+func SetConfig(conf *Config) {
+	mu.Lock()
+	config = conf
+	mu.Unlock()
+}
+
+// loadConfig 从文件读取配置
+func loadConfig(path string) (*Config, error) {
+	conf := new(Config)
+	if _, err := toml.DecodeFile(path, conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// GetConfig 返回当前配置，不要修改返回值；配置重新加载后再次调用才能拿到新值
 func GetConfig() *Config {
+	mu.RLock()
+	conf := config
+	mu.RUnlock()
+	if conf != nil {
+		return conf
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
 	if config == nil {
-		config = new(Config)
-		_ = InitConfig()
+		conf, err := loadConfig(configPath)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		config = conf
 	}
 	return config
 }
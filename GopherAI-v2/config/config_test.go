@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUserUploadDir(t *testing.T) {
+	base := t.TempDir()
+
+	tests := []struct {
+		name     string
+		base     string
+		username string
+		want     string // 空表示返回 ErrInvalidUploadUser
+	}{
+		{"default base", "", "alice", filepath.Join("uploads", "alice")},
+		{"configured base", base, "alice", filepath.Join(base, "alice")},
+		{"unicode username", base, "张三", filepath.Join(base, "张三")},
+		{"dots inside name", base, "a..b", filepath.Join(base, "a..b")},
+		{"parent traversal", base, "../etc", ""},
+		{"deep traversal", base, "../../../../etc", ""},
+		{"traversal with default base", "", "../etc", ""},
+		{"backslash traversal", base, `..\etc`, ""},
+		{"nested path", base, "alice/bob", ""},
+		{"absolute path", base, "/etc", ""},
+		{"parent", base, "..", ""},
+		{"current", base, ".", ""},
+		{"empty", base, "", ""},
+		{"nul byte", base, "alice\x00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MainConfig{UploadDir: tt.base}
+			dir, err := c.UserUploadDir(tt.username)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidUploadUser) {
+					t.Fatalf("UserUploadDir(%q) = %q, %v, want ErrInvalidUploadUser", tt.username, dir, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UserUploadDir(%q): %v", tt.username, err)
+			}
+			if dir != tt.want {
+				t.Errorf("UserUploadDir(%q) = %q, want %q", tt.username, dir, tt.want)
+			}
+			// 返回的目录必须在根目录下
+			rel, err := filepath.Rel(c.UploadBaseDir(), dir)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				t.Errorf("UserUploadDir(%q) = %q escapes %q", tt.username, dir, c.UploadBaseDir())
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// ReloadListener 配置重新加载后的回调，old 和 new 都不能修改
+type ReloadListener func(old, new *Config)
+
+var (
+	listenersMu sync.Mutex
+	listeners   []ReloadListener
+)
+
+// OnReload 注册配置重新加载后的回调，长期存在的组件（例如连接池、缓存）可以据此更新自己的参数
+// 回调在 Reload 所在的 goroutine 中按注册顺序执行，不要在回调中调用 Reload
+func OnReload(listener ReloadListener) {
+	listenersMu.Lock()
+	listeners = append(listeners, listener)
+	listenersMu.Unlock()
+}
+
+// immutableField 启动后不能通过重新加载修改的配置项
+type immutableField struct {
+	name string
+	get  func(c *Config) any
+	set  func(dst, src *Config) // 把 src 中的值复制到 dst
+}
+
+// 已建立的连接、已创建的向量索引依赖这些配置，修改后需要重启（向量维度、模型还需要重建索引）
+var immutableFields = []immutableField{
+	{"mainConfig", func(c *Config) any { return c.MainConfig }, func(d, s *Config) { d.MainConfig = s.MainConfig }},
+	{"redisConfig", func(c *Config) any { return c.RedisConfig }, func(d, s *Config) { d.RedisConfig = s.RedisConfig }},
+	{"mysqlConfig", func(c *Config) any { return c.MysqlConfig }, func(d, s *Config) { d.MysqlConfig = s.MysqlConfig }},
+	{"rabbitmqConfig", func(c *Config) any { return c.Rabbitmq }, func(d, s *Config) { d.Rabbitmq = s.Rabbitmq }},
+	{"ragModelConfig.dimension",
+		func(c *Config) any { return c.RagDimension },
+		func(d, s *Config) { d.RagDimension = s.RagDimension }},
+	{"ragModelConfig.reducedDimension",
+		func(c *Config) any { return c.RagReducedDimension },
+		func(d, s *Config) { d.RagReducedDimension = s.RagReducedDimension }},
+	{"ragModelConfig.embeddingModel",
+		func(c *Config) any { return c.RagEmbeddingModel },
+		func(d, s *Config) { d.RagEmbeddingModel = s.RagEmbeddingModel }},
+	{"ragModelConfig.embeddingProvider",
+		func(c *Config) any { return c.RagEmbeddingProvider },
+		func(d, s *Config) { d.RagEmbeddingProvider = s.RagEmbeddingProvider }},
+	{"ragModelConfig.vectorStore",
+		func(c *Config) any { return c.RagVectorStore },
+		func(d, s *Config) { d.RagVectorStore = s.RagVectorStore }},
+}
+
+// Reload 重新读取配置文件并替换当前配置
+// 新配置校验失败时保留当前配置并返回错误；不能在运行时修改的项（见 immutableFields）保持原值，
+// 并打印警告，返回被忽略的配置项名称
+func Reload() ([]string, error) {
+	next, err := loadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reload config: %w", err)
+	}
+	if err := next.Validate(); err != nil {
+		return nil, fmt.Errorf("reload config: %w", err)
+	}
+
+	mu.Lock()
+	old := config
+	var ignored []string
+	if old != nil {
+		for _, f := range immutableFields {
+			if !reflect.DeepEqual(f.get(old), f.get(next)) {
+				ignored = append(ignored, f.name)
+				f.set(next, old)
+			}
+		}
+	}
+	config = next
+	mu.Unlock()
+
+	for _, name := range ignored {
+		log.Printf("config reload: %s cannot be changed at runtime, keeping the current value (restart to apply)", name)
+	}
+
+	listenersMu.Lock()
+	current := append([]ReloadListener(nil), listeners...)
+	listenersMu.Unlock()
+	for _, listener := range current {
+		listener(old, next)
+	}
+	return ignored, nil
+}
+
+// ReloadOnSIGHUP 收到 SIGHUP 信号时重新加载配置，返回的函数用于停止监听
+func ReloadOnSIGHUP() (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if _, err := Reload(); err != nil {
+					log.Printf("config reload failed: %v", err)
+					continue
+				}
+				log.Println("config reloaded")
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
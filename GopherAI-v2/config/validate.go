@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Validate 检查配置中必须正确填写的项，一次性返回所有问题（errors.Join 合并，每行一个）
+// 应在启动时调用一次，避免配置错误时在第一次访问 Redis 或调用向量模型时才报出难以理解的错误
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// ragModelConfig
+	rag := c.RagModelConfig
+	if err := validateURL(rag.RagBaseUrl); err != nil {
+		add("ragModelConfig.baseUrl: %v", err)
+	}
+	if rag.RagEmbeddingBaseUrl != "" {
+		if err := validateURL(rag.RagEmbeddingBaseUrl); err != nil {
+			add("ragModelConfig.embeddingBaseUrl: %v", err)
+		}
+	}
+	if rag.RagRerankBaseUrl != "" {
+		if err := validateURL(rag.RagRerankBaseUrl); err != nil {
+			add("ragModelConfig.rerankBaseUrl: %v", err)
+		}
+	}
+	if rag.RagDimension <= 0 {
+		add("ragModelConfig.dimension: must be > 0, got %d", rag.RagDimension)
+	}
+	if rag.RagReducedDimension < 0 || (rag.RagReducedDimension > 0 && rag.RagReducedDimension >= rag.RagDimension) {
+		add("ragModelConfig.reducedDimension: must be 0 or between 1 and dimension-1 (%d), got %d",
+			rag.RagDimension-1, rag.RagReducedDimension)
+	}
+	if rag.RagEmbeddingModel == "" {
+		add("ragModelConfig.embeddingModel: is required")
+	}
+	switch rag.RagVectorStore {
+	case "", "redis", "memory":
+	default:
+		add("ragModelConfig.vectorStore: must be redis or memory, got %q", rag.RagVectorStore)
+	}
+	switch rag.RagModelFailureMode {
+	case "", "degrade", "fail":
+	default:
+		add("ragModelConfig.modelFailureMode: must be degrade or fail, got %q", rag.RagModelFailureMode)
+	}
+	switch rag.RagContentCompression {
+	case "", "gzip":
+	default:
+		add("ragModelConfig.contentCompression: must be empty or gzip, got %q", rag.RagContentCompression)
+	}
+	switch strings.ToUpper(rag.RagVectorIndexAlgorithm) {
+	case "", "FLAT", "HNSW":
+	default:
+		add("ragModelConfig.vectorIndexAlgorithm: must be FLAT or HNSW, got %q", rag.RagVectorIndexAlgorithm)
+	}
+	switch strings.ToUpper(rag.RagDistanceMetric) {
+	case "", "COSINE", "L2", "IP":
+	default:
+		add("ragModelConfig.distanceMetric: must be COSINE, L2 or IP, got %q", rag.RagDistanceMetric)
+	}
+
+	// quotaConfig
+	quota := c.QuotaConfig
+	if quota.MaxFiles < 0 || quota.MaxBytes < 0 || quota.MaxChunks < 0 {
+		add("quotaConfig: maxFiles, maxBytes and maxChunks must be >= 0 (0 means unlimited)")
+	}
+
+	// redisConfig
+	redis := c.RedisConfig
+	if redis.RedisClusterMode {
+		if len(redis.RedisClusterAddrs) == 0 {
+			add("redisConfig.clusterAddrs: is required when clusterMode is enabled")
+		}
+	} else {
+		if redis.RedisHost == "" {
+			add("redisConfig.host: is required")
+		}
+		if redis.RedisPort <= 0 || redis.RedisPort > 65535 {
+			add("redisConfig.port: must be between 1 and 65535, got %d", redis.RedisPort)
+		}
+		if redis.RedisDb < 0 {
+			add("redisConfig.db: must be >= 0, got %d", redis.RedisDb)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateURL 检查地址是带 http / https 协议和主机名的绝对 URL
+func validateURL(raw string) error {
+	if raw == "" {
+		return errors.New("is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: must be an absolute http(s) URL", raw)
+	}
+	return nil
+}
@@ -2,10 +2,14 @@ package file
 
 import (
 	"GopherAI/common/code"
+	"GopherAI/common/rag"
 	"GopherAI/controller"
 	"GopherAI/service/file"
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,9 +38,27 @@ func UploadRagFile(c *gin.Context) {
 	}
 
 	//indexer 会在 service 层根据实际文件名创建
-	filePath, err := file.UploadRagFile(username, uploadedFile)
+	filePath, err := file.UploadRagFile(c.Request.Context(), username, uploadedFile)
 	if err != nil {
 		log.Println("UploadFile fail ", err)
+		var limitErr *rag.RateLimitError
+		switch {
+		case errors.As(err, &limitErr):
+			// 上传过于频繁，返回429并告知需要等待的秒数
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, res.CodeOf(code.CodeTooManyRequests))
+			return
+		case errors.Is(err, rag.ErrQuotaExceeded):
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeQuotaExceeded))
+			return
+		case errors.Is(err, rag.ErrEmptyDocument), errors.Is(err, rag.ErrNoChunksIndexed):
+			// 文件没有可以索引的内容，属于请求本身的问题
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+			return
+		case errors.Is(err, rag.ErrEmbeddingFailed):
+			c.JSON(http.StatusOK, res.CodeOf(code.AIModelFail))
+			return
+		}
 		c.JSON(http.StatusOK, res.CodeOf(code.CodeServerBusy))
 		return
 	}
@@ -4,7 +4,9 @@ import (
 	"GopherAI/common/code"
 	"GopherAI/controller"
 	"GopherAI/service/user"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,7 +20,8 @@ type (
 	// omitempty当字段为空的时候，不返回这个东西
 	LoginResponse struct {
 		controller.Response
-		Token string `json:"token,omitempty"`
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
 	}
 	//验证码由后端生成，存放到redis中，固然需要先发送一次请求CaptchaRequest,然后用返回的验证码
 	//邮箱以及密码进行注册，后续再将账号进行返回
@@ -30,7 +33,8 @@ type (
 	//注册成功之后，直接让其进行登录状态
 	RegisterResponse struct {
 		controller.Response
-		Token string `json:"token,omitempty"`
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
 	}
 
 	CaptchaRequest struct {
@@ -40,6 +44,67 @@ type (
 	CaptchaResponse struct {
 		controller.Response
 	}
+
+	PasswordResetRequest struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	PasswordResetResponse struct {
+		controller.Response
+	}
+
+	ResetPasswordRequest struct {
+		Email       string `json:"email" binding:"required"`
+		Captcha     string `json:"captcha" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+
+	ResetPasswordResponse struct {
+		controller.Response
+	}
+
+	UpdatePasswordRequest struct {
+		OldPassword string `json:"oldPassword" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+
+	UpdatePasswordResponse struct {
+		controller.Response
+	}
+
+	EmailChangeRequest struct {
+		NewEmail string `json:"newEmail" binding:"required"`
+	}
+
+	EmailChangeResponse struct {
+		controller.Response
+	}
+
+	ConfirmEmailChangeRequest struct {
+		Captcha string `json:"captcha" binding:"required"`
+	}
+
+	ConfirmEmailChangeResponse struct {
+		controller.Response
+	}
+
+	RefreshRequest struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+
+	RefreshResponse struct {
+		controller.Response
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
+	}
+
+	LogoutRequest struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+
+	LogoutResponse struct {
+		controller.Response
+	}
 )
 
 func Login(c *gin.Context) {
@@ -51,7 +116,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, code_ := user.Login(req.Username, req.Password)
+	token, refreshToken, code_ := user.Login(req.Username, req.Password)
 	if code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
@@ -59,6 +124,7 @@ func Login(c *gin.Context) {
 
 	res.Success()
 	res.Token = token
+	res.RefreshToken = refreshToken
 	c.JSON(http.StatusOK, res)
 
 }
@@ -72,7 +138,7 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	token, code_ := user.Register(req.Email, req.Password, req.Captcha)
+	token, refreshToken, code_ := user.Register(req.Email, req.Password, req.Captcha)
 	if code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
@@ -80,6 +146,7 @@ func Register(c *gin.Context) {
 
 	res.Success()
 	res.Token = token
+	res.RefreshToken = refreshToken
 	c.JSON(http.StatusOK, res)
 }
 
@@ -93,7 +160,13 @@ func HandleCaptcha(c *gin.Context) {
 	}
 
 	//给service层进行处理
-	code_ := user.SendCaptcha(req.Email)
+	retryAfter, code_ := user.SendCaptcha(req.Email)
+	if code_ == code.CodeTooManyRequests {
+		//发送过于频繁，返回429并告知需要等待的秒数
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, res.CodeOf(code_))
+		return
+	}
 	if code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
@@ -103,3 +176,145 @@ func HandleCaptcha(c *gin.Context) {
 	res.Success()
 	c.JSON(http.StatusOK, res)
 }
+
+// HandlePasswordResetRequest 申请重置密码，邮箱已注册时发送重置验证码
+// 无论邮箱是否注册都返回成功
+func HandlePasswordResetRequest(c *gin.Context) {
+	req := new(PasswordResetRequest)
+	res := new(PasswordResetResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	retryAfter, code_ := user.RequestPasswordReset(req.Email)
+	if code_ == code.CodeTooManyRequests {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, res.CodeOf(code_))
+		return
+	}
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// ResetPassword 使用重置验证码设置新密码
+func ResetPassword(c *gin.Context) {
+	req := new(ResetPasswordRequest)
+	res := new(ResetPasswordResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := user.ResetPassword(req.Email, req.Captcha, req.NewPassword)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdatePassword 已登录用户修改密码，需要校验原密码
+func UpdatePassword(c *gin.Context) {
+	req := new(UpdatePasswordRequest)
+	res := new(UpdatePasswordResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	username := c.GetString("userName") // From JWT middleware
+	code_ := user.UpdatePassword(username, req.OldPassword, req.NewPassword)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// HandleEmailChangeRequest 已登录用户申请更换邮箱，向新邮箱发送验证码
+func HandleEmailChangeRequest(c *gin.Context) {
+	req := new(EmailChangeRequest)
+	res := new(EmailChangeResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	username := c.GetString("userName") // From JWT middleware
+	retryAfter, code_ := user.RequestEmailChange(username, req.NewEmail)
+	if code_ == code.CodeTooManyRequests {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, res.CodeOf(code_))
+		return
+	}
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// ConfirmEmailChange 使用新邮箱收到的验证码确认更换邮箱
+func ConfirmEmailChange(c *gin.Context) {
+	req := new(ConfirmEmailChangeRequest)
+	res := new(ConfirmEmailChangeResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	username := c.GetString("userName") // From JWT middleware
+	code_ := user.ConfirmEmailChange(username, req.Captcha)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌和刷新令牌
+func Refresh(c *gin.Context) {
+	req := new(RefreshRequest)
+	res := new(RefreshResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	token, refreshToken, code_ := user.RefreshSession(req.RefreshToken)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	res.Token = token
+	res.RefreshToken = refreshToken
+	c.JSON(http.StatusOK, res)
+}
+
+// Logout 退出登录，注销刷新令牌
+func Logout(c *gin.Context) {
+	req := new(LogoutRequest)
+	res := new(LogoutResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := user.Logout(req.RefreshToken)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
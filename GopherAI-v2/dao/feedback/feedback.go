@@ -0,0 +1,90 @@
+package feedback
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/model"
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 检索结果反馈：记录用户认为哪些检索到的文档块有帮助，按文档块汇总后可以找出质量差的文档块。
+// 目前只记录和统计，检索排序还没有使用这些数据。
+
+// ErrEmptyDocID 反馈没有指定文档块
+var ErrEmptyDocID = errors.New("feedback doc id is empty")
+
+// 分页查询文档块反馈汇总时的默认数量和最大数量
+const (
+	defaultHelpfulnessLimit = 20
+	maxHelpfulnessLimit     = 100
+)
+
+// RecordFeedback 记录一次反馈：query 为用户的问题，docID 为检索结果中文档块的 ID
+func RecordFeedback(ctx context.Context, query, docID string, helpful bool) error {
+	docID = strings.TrimSpace(docID)
+	if docID == "" {
+		return ErrEmptyDocID
+	}
+	return mysql.DB.WithContext(ctx).Create(&model.Feedback{
+		Query:   strings.TrimSpace(query),
+		DocID:   docID,
+		Helpful: helpful,
+	}).Error
+}
+
+// GetDocumentHelpfulness 汇总一个文档块收到的反馈，没有反馈时各项为 0
+func GetDocumentHelpfulness(ctx context.Context, docID string) (*model.DocumentHelpfulness, error) {
+	var rows []*model.DocumentHelpfulness
+	err := helpfulnessQuery(ctx).Where("doc_id = ?", docID).Group("doc_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return &model.DocumentHelpfulness{DocID: docID}, nil
+	}
+	return withRatio(rows[0]), nil
+}
+
+// ListLeastHelpfulDocuments 按有帮助的反馈占比从低到高列出文档块（质量最差的在前）
+// 只统计反馈数不少于 minVotes 的文档块，避免一两次点踩就被判为低质量；limit 为 0 时使用默认值 20，超过 100 时按 100 处理
+func ListLeastHelpfulDocuments(ctx context.Context, minVotes, limit int) ([]*model.DocumentHelpfulness, error) {
+	if limit <= 0 {
+		limit = defaultHelpfulnessLimit
+	}
+	if limit > maxHelpfulnessLimit {
+		limit = maxHelpfulnessLimit
+	}
+	var rows []*model.DocumentHelpfulness
+	err := helpfulnessQuery(ctx).
+		Group("doc_id").
+		Having("COUNT(*) >= ?", max(minVotes, 1)).
+		Order("SUM(CASE WHEN helpful THEN 1 ELSE 0 END) / COUNT(*) ASC, total DESC, doc_id ASC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		withRatio(row)
+	}
+	return rows, nil
+}
+
+// helpfulnessQuery 按文档块统计有帮助 / 没帮助的反馈数
+func helpfulnessQuery(ctx context.Context) *gorm.DB {
+	return mysql.DB.WithContext(ctx).Model(&model.Feedback{}).Select(
+		"doc_id, " +
+			"SUM(CASE WHEN helpful THEN 1 ELSE 0 END) AS helpful, " +
+			"SUM(CASE WHEN helpful THEN 0 ELSE 1 END) AS unhelpful, " +
+			"COUNT(*) AS total")
+}
+
+func withRatio(h *model.DocumentHelpfulness) *model.DocumentHelpfulness {
+	if h.Total > 0 {
+		h.Ratio = float64(h.Helpful) / float64(h.Total)
+	}
+	return h
+}
@@ -16,6 +16,13 @@ func CreateSession(session *model.Session) (*model.Session, error) {
 	return session, err
 }
 
+// GetSessionIDsByUserName 查询用户所有会话的 ID（包括已删除的会话）
+func GetSessionIDsByUserName(username string) ([]string, error) {
+	var ids []string
+	err := mysql.DB.Unscoped().Model(&model.Session{}).Where("user_name = ?", username).Pluck("id", &ids).Error
+	return ids, err
+}
+
 func GetSessionByID(sessionID string) (*model.Session, error) {
 	var session model.Session
 	err := mysql.DB.Where("id = ?", sessionID).First(&session).Error
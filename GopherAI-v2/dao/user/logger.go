@@ -0,0 +1,19 @@
+package user
+
+import (
+	"io"
+	"log/slog"
+)
+
+// logger 用户 dao 使用的日志记录器，默认使用 slog.Default()
+// 不记录密码、哈希等敏感信息
+var logger = slog.Default()
+
+// SetLogger 替换用户 dao 使用的日志记录器，传入 nil 时不输出任何日志（便于测试）
+// 应在初始化阶段调用
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	logger = l
+}
@@ -2,23 +2,33 @@ package user
 
 import (
 	"GopherAI/common/mysql"
+	myredis "GopherAI/common/redis"
+	"GopherAI/dao/session"
+	"GopherAI/dao/visibility"
 	"GopherAI/model"
 	"GopherAI/utils"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-const (
-	CodeMsg     = "GopherAI验证码如下(验证码仅限于2分钟有效): "
-	UserNameMsg = "GopherAI的账号如下，请保留好，后续可以用账号/邮箱进行登录 "
-)
-
 var ctx = context.Background()
 
-// 登录标识支持 username / email
+// NormalizeIdentifier 统一账号 / 邮箱的格式（去掉首尾空白并转小写），
+// 保证 "Alice@Example.com" 与 "alice@example.com" 视为同一个账号
+func NormalizeIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// 登录标识支持 username / email，不区分大小写
+// 软删除的用户不会被查到（gorm 默认过滤 deleted_at 不为空的行）
 func IsExistUser(username string) (bool, *model.User) {
+	username = NormalizeIdentifier(username)
+
 	// 1) 先按 username 查
 	u, err := mysql.GetUserByUsername(username)
 	if err == nil && u != nil {
@@ -40,15 +50,333 @@ func IsExistUser(username string) (bool, *model.User) {
 	return false, nil
 }
 
-func Register(username, email, password string) (*model.User, bool) {
-	if user, err := mysql.InsertUser(&model.User{
+// ErrUserExists 账号或邮箱已被注册，可通过 errors.Is 判断
+var ErrUserExists = errors.New("user already exists")
+
+// UserExistsError 注册冲突的具体字段
+type UserExistsError struct {
+	Field string // username / email
+}
+
+func (e *UserExistsError) Error() string {
+	return fmt.Sprintf("%s already exists", e.Field)
+}
+
+// Is 使 errors.Is(err, ErrUserExists) 成立
+func (e *UserExistsError) Is(target error) bool {
+	return target == ErrUserExists
+}
+
+// Register 注册用户，账号和邮箱统一以小写形式存储
+// 邮箱或密码不合法时返回 *utils.FieldError；
+// 账号或邮箱已存在时返回 *UserExistsError（errors.Is(err, ErrUserExists) 为 true）
+func Register(username, email, password string) (*model.User, error) {
+	username = NormalizeIdentifier(username)
+	email = NormalizeIdentifier(email)
+
+	if err := utils.ValidateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	if ok, _ := IsExistUser(username); ok {
+		return nil, &UserExistsError{Field: "username"}
+	}
+	if ok, _ := IsExistUser(email); ok {
+		return nil, &UserExistsError{Field: "email"}
+	}
+	// 软删除的账号在宽限期内仍可能被恢复，其账号和邮箱不能被重新注册
+	if _, err := mysql.GetDeletedUserByUsername(username); err == nil {
+		return nil, &UserExistsError{Field: "username"}
+	}
+	if _, err := mysql.GetDeletedUserByEmail(email); err == nil {
+		return nil, &UserExistsError{Field: "email"}
+	}
+
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := mysql.InsertUser(&model.User{
 		Email:    email,
 		Name:     username,
 		Username: username,
-		Password: utils.MD5(password),
-	}); err != nil {
-		return nil, false
+		Password: passwordHash,
+	})
+	// 上面的检查与插入之间可能有并发注册，最终由唯一索引保证账号和邮箱不重复
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, duplicateUserError(username)
+	}
+	if err != nil {
+		logger.Error("insert user failed", "username", username, "error", err)
+		return nil, err
+	}
+	logger.Info("user registered", "username", user.Username, "user_id", user.ID)
+	return user, nil
+}
+
+// duplicateUserError 插入或更新时违反唯一索引，判断冲突的是账号还是邮箱
+func duplicateUserError(username string) error {
+	if _, err := mysql.GetUserByUsername(username); err == nil {
+		return &UserExistsError{Field: "username"}
+	}
+	if _, err := mysql.GetDeletedUserByUsername(username); err == nil {
+		return &UserExistsError{Field: "username"}
+	}
+	return &UserExistsError{Field: "email"}
+}
+
+// SetPassword 校验新密码强度后以 bcrypt 哈希保存，并注销该账号所有的刷新令牌（所有设备需要重新登录），
+// 被盗用的刷新令牌在密码修改后不能再换取访问令牌
+// 新密码不合法时返回 *utils.FieldError
+func SetPassword(user *model.User, newPassword string) error {
+	if err := utils.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	passwordHash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := mysql.UpdateUserPassword(user.ID, passwordHash); err != nil {
+		logger.Error("update password failed", "username", user.Username, "error", err)
+		return err
+	}
+	user.Password = passwordHash
+	logger.Info("password updated", "username", user.Username)
+
+	// 密码已经更新，注销失败不回滚，只记录日志
+	if revoked, err := myredis.RevokeUserRefreshTokens(ctx, user.Username); err != nil {
+		logger.Error("revoke refresh tokens after password change failed", "username", user.Username, "error", err)
 	} else {
-		return user, true
+		logger.Info("refresh tokens revoked after password change", "username", user.Username, "chains", revoked)
+	}
+	return nil
+}
+
+// GetUserByID 按用户 ID（JWT 中的 id）查询用户，不存在时返回 ErrUserNotFound
+func GetUserByID(id int64) (*model.User, error) {
+	u, err := mysql.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+// 分页查询用户时每页的默认数量和最大数量
+const (
+	defaultListUsersLimit = 20
+	maxListUsersLimit     = 100
+)
+
+// ErrInvalidPagination 分页参数不合法
+var ErrInvalidPagination = errors.New("invalid pagination: offset and limit must be >= 0")
+
+// ListUsers 分页查询用户（供管理员使用），按注册时间排序，同时返回用户总数
+// limit 为 0 时使用默认值 20，超过 100 时按 100 处理；返回的用户不包含密码哈希
+func ListUsers(ctx context.Context, offset, limit int) ([]*model.User, int64, error) {
+	if offset < 0 || limit < 0 {
+		return nil, 0, ErrInvalidPagination
+	}
+	if limit == 0 {
+		limit = defaultListUsersLimit
+	}
+	if limit > maxListUsersLimit {
+		limit = maxListUsersLimit
+	}
+	users, total, err := mysql.ListUsers(ctx, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, u := range users {
+		u.Password = ""
+	}
+	return users, total, nil
+}
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("user not found")
+	// ErrWrongPassword 原密码错误
+	ErrWrongPassword = errors.New("wrong old password")
+)
+
+// UpdatePassword 已登录用户修改密码：校验原密码后设置新密码
+// 用户不存在时返回 ErrUserNotFound，原密码错误时返回 ErrWrongPassword，
+// 新密码不合法时返回 *utils.FieldError
+func UpdatePassword(username, oldPassword, newPassword string) error {
+	u, err := mysql.GetUserByUsername(NormalizeIdentifier(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warn("update password failed: user not found", "username", username)
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if !utils.CheckPassword(u.Password, oldPassword) {
+		logger.Warn("update password failed: wrong old password", "username", u.Username)
+		return ErrWrongPassword
+	}
+	return SetPassword(u, newPassword)
+}
+
+// CheckEmailAvailable 校验邮箱格式，并确认邮箱没有被其它账号（包括宽限期内软删除的账号）使用
+// 邮箱不合法时返回 *utils.FieldError，已被使用时返回 *UserExistsError（errors.Is(err, ErrUserExists) 为 true）
+func CheckEmailAvailable(email string) error {
+	email = NormalizeIdentifier(email)
+	if err := utils.ValidateEmail(email); err != nil {
+		return err
+	}
+	if ok, _ := IsExistUser(email); ok {
+		return &UserExistsError{Field: "email"}
+	}
+	if _, err := mysql.GetDeletedUserByEmail(email); err == nil {
+		return &UserExistsError{Field: "email"}
+	}
+	return nil
+}
+
+// UpdateEmail 把账号的邮箱更新为 newEmail，调用方需要先完成新邮箱的验证
+// 用户不存在时返回 ErrUserNotFound；新邮箱不合法或已被使用时的错误与 CheckEmailAvailable 相同
+func UpdateEmail(username, newEmail string) error {
+	newEmail = NormalizeIdentifier(newEmail)
+	u, err := mysql.GetUserByUsername(NormalizeIdentifier(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warn("update email failed: user not found", "username", username)
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if err := CheckEmailAvailable(newEmail); err != nil {
+		return err
+	}
+	err = mysql.UpdateUserEmail(u.ID, newEmail)
+	// 确认之前新邮箱可能已被其它账号注册，由唯一索引保证不会出现两个账号使用同一个邮箱
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &UserExistsError{Field: "email"}
+	}
+	if err != nil {
+		logger.Error("update email failed", "username", u.Username, "error", err)
+		return err
+	}
+	logger.Info("email updated", "username", u.Username)
+	return nil
+}
+
+// 软删除的账号可以被恢复的宽限期
+const restoreGraceWindow = 30 * 24 * time.Hour
+
+// ErrRestoreExpired 账号删除已超过宽限期，不能再恢复
+var ErrRestoreExpired = errors.New("restore grace window expired")
+
+// SoftDeleteUser 软删除用户，知识库索引由 service 层清理（见 service/user.SoftDeleteUser）
+// 用户不存在时返回 ErrUserNotFound
+func SoftDeleteUser(username string) error {
+	u, err := mysql.GetUserByUsername(NormalizeIdentifier(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if err := mysql.SoftDeleteUser(u.ID); err != nil {
+		return err
+	}
+	logger.Info("user soft deleted", "username", u.Username)
+	return nil
+}
+
+// RestoreUser 恢复宽限期内被软删除的用户（供管理员使用）
+// 知识库索引在删除时已被清理，恢复后需要重新上传文件
+// 没有被软删除的用户时返回 ErrUserNotFound，超过宽限期时返回 ErrRestoreExpired
+func RestoreUser(username string) error {
+	u, err := mysql.GetDeletedUserByUsername(NormalizeIdentifier(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if time.Since(u.DeletedAt.Time) > restoreGraceWindow {
+		logger.Warn("restore user rejected: grace window expired", "username", u.Username)
+		return ErrRestoreExpired
+	}
+	if err := mysql.RestoreUser(u.ID); err != nil {
+		return err
+	}
+	logger.Info("user restored", "username", u.Username)
+	return nil
+}
+
+// DeleteAllUserData 删除用户在 MySQL 和 Redis 中的数据（注销账号 / 数据删除请求）：
+//  1. 删除知识库的公开标记
+//  2. 删除 Redis 中的验证码、登录失败计数、配额、刷新令牌和 RAG 对话历史
+//  3. 删除用户记录：hard 为 false 时软删除（宽限期内仍可恢复账号，但数据已不可恢复），为 true 时彻底删除
+//
+// 知识库索引和上传的原始文件由 service 层在调用之前删除（见 service/user.DeleteAllUserData）。
+// 每一步都会尽量执行，失败的步骤合并成一个错误返回；已经删除的数据不会导致失败，可以重复调用。
+// 会话和消息记录保留在数据库中。
+func DeleteAllUserData(ctx context.Context, username string, hard bool) error {
+	username = NormalizeIdentifier(username)
+	u, err := mysql.GetUserByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		u, err = mysql.GetDeletedUserByUsername(username)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 用户记录已被彻底删除，仍然清理可能残留的数据
+		u, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	fail := func(step string, err error) {
+		logger.Error("delete user data failed", "username", username, "step", step, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", step, err))
+	}
+
+	if err := visibility.DeleteUserIndexVisibility(ctx, username); err != nil {
+		fail("delete index visibility", err)
+	}
+
+	email := ""
+	if u != nil {
+		email = u.Email
+	}
+	if err := myredis.DeleteUserKeys(ctx, username, email); err != nil {
+		fail("delete redis keys", err)
+	}
+	if _, err := myredis.RevokeUserRefreshTokens(ctx, username); err != nil {
+		fail("revoke refresh tokens", err)
+	}
+	if sessionIDs, err := session.GetSessionIDsByUserName(username); err != nil {
+		fail("list sessions", err)
+	} else if err := myredis.DeleteRAGHistories(ctx, sessionIDs); err != nil {
+		fail("delete rag histories", err)
+	}
+
+	if u != nil {
+		switch {
+		case hard:
+			err = mysql.HardDeleteUser(u.ID)
+		case !u.DeletedAt.Valid:
+			err = mysql.SoftDeleteUser(u.ID)
+		}
+		if err != nil {
+			fail("delete user", err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
+	logger.Info("user data deleted", "username", username, "hard", hard)
+	return nil
 }
@@ -0,0 +1,195 @@
+package user
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/model"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// useTestDB 把 mysql.DB 替换为临时目录中的 SQLite 数据库（已建好用户表），测试结束后恢复
+func useTestDB(t *testing.T) {
+	t.Helper()
+	SetLogger(nil)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger:         gormlogger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(new(model.User)); err != nil {
+		t.Fatal(err)
+	}
+	old := mysql.DB
+	mysql.DB = db
+	t.Cleanup(func() { mysql.DB = old })
+}
+
+func TestIsExistUser(t *testing.T) {
+	useTestDB(t)
+	alice, err := mysql.InsertUser(&model.User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := mysql.InsertUser(&model.User{Username: "gone", Email: "gone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mysql.SoftDeleteUser(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		identifier string
+		wantID     int64 // 0 表示不存在
+	}{
+		{"username", "alice", alice.ID},
+		{"username upper case", "ALICE", alice.ID},
+		{"username with spaces", "  Alice ", alice.ID},
+		{"email", "alice@example.com", alice.ID},
+		{"email mixed case", "Alice@Example.COM", alice.ID},
+		{"unknown", "bob", 0},
+		{"soft deleted", "gone", 0},
+		{"soft deleted email", "gone@example.com", 0},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, u := IsExistUser(tt.identifier)
+			if ok != (tt.wantID != 0) {
+				t.Fatalf("IsExistUser(%q) = %v, want %v", tt.identifier, ok, tt.wantID != 0)
+			}
+			if ok && u.ID != tt.wantID {
+				t.Errorf("IsExistUser(%q) returned user %d, want %d", tt.identifier, u.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	useTestDB(t)
+	if _, err := Register("alice", "alice@example.com", "secret123"); err != nil {
+		t.Fatal(err)
+	}
+	gone, err := Register("gone", "gone@example.com", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mysql.SoftDeleteUser(gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		username  string
+		email     string
+		wantField string // 空表示注册成功
+	}{
+		{"same username", "alice", "other@example.com", "username"},
+		{"username different case", "ALICE", "other@example.com", "username"},
+		{"same email", "bob", "alice@example.com", "email"},
+		{"email different case", "bob", "Alice@Example.com", "email"},
+		{"username taken by an email", "alice@example.com", "bob@example.com", "username"},
+		{"soft deleted username", "gone", "other@example.com", "username"},
+		{"soft deleted email", "bob", "gone@example.com", "email"},
+		{"new user", "bob", "bob@example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := Register(tt.username, tt.email, "secret123")
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Register: %v", err)
+				}
+				if u.Username != NormalizeIdentifier(tt.username) || u.Email != NormalizeIdentifier(tt.email) {
+					t.Errorf("stored %q / %q, want lower case", u.Username, u.Email)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUserExists) {
+				t.Fatalf("err = %v, want ErrUserExists", err)
+			}
+			var existsErr *UserExistsError
+			if !errors.As(err, &existsErr) || existsErr.Field != tt.wantField {
+				t.Errorf("err = %v, want conflict on %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+// 检查与插入之间的并发注册由唯一索引兜底，冲突的字段由 duplicateUserError 判断
+func TestDuplicateKeyIsUserExists(t *testing.T) {
+	useTestDB(t)
+	if _, err := mysql.InsertUser(&model.User{Username: "alice", Email: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		user      *model.User
+		wantField string
+	}{
+		{"username", &model.User{Username: "alice", Email: "new@example.com"}, "username"},
+		{"email", &model.User{Username: "bob", Email: "alice@example.com"}, "email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mysql.InsertUser(tt.user)
+			if !errors.Is(err, gorm.ErrDuplicatedKey) {
+				t.Fatalf("insert err = %v, want gorm.ErrDuplicatedKey", err)
+			}
+			var existsErr *UserExistsError
+			if err := duplicateUserError(tt.user.Username); !errors.As(err, &existsErr) || existsErr.Field != tt.wantField {
+				t.Errorf("duplicateUserError(%q) = %v, want conflict on %s", tt.user.Username, err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestGetUserByID(t *testing.T) {
+	useTestDB(t)
+	alice, err := mysql.InsertUser(&model.User{Username: "alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.ID == 0 {
+		t.Fatal("InsertUser did not set the generated id")
+	}
+	gone, err := mysql.InsertUser(&model.User{Username: "gone", Email: "gone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mysql.SoftDeleteUser(gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		id       int64
+		wantName string
+		wantErr  error
+	}{
+		{"existing", alice.ID, "alice", nil},
+		{"soft deleted", gone.ID, "", ErrUserNotFound},
+		{"missing", gone.ID + 100, "", ErrUserNotFound},
+		{"zero", 0, "", ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := GetUserByID(tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetUserByID(%d) err = %v, want %v", tt.id, err, tt.wantErr)
+			}
+			if tt.wantErr == nil && u.Username != tt.wantName {
+				t.Errorf("GetUserByID(%d) = %q, want %q", tt.id, u.Username, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package visibility
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/common/rag"
+	"GopherAI/model"
+	"context"
+	"errors"
+	"strings"
+)
+
+// 知识库可见性：知识库默认是私有的，只有所属用户可以检索；标记为公开后，
+// 其他用户在查询时开启 rag.QueryOptions.RetrieveFromPublic 即可同时检索它。
+// 这里记录每个知识库的可见性，并通过 rag.SetPublicIndexLister 告诉 rag 包哪些知识库是公开的。
+
+// ErrEmptyIndexRef 没有指定知识库的所属用户或文件名
+var ErrEmptyIndexRef = errors.New("index owner and filename are required")
+
+// MarkIndexPublic 把 owner 的 filename 知识库标记为公开，已经是公开的不报错
+// owner 必须是发起请求的用户本人，不能替其他用户公开知识库
+func MarkIndexPublic(ctx context.Context, owner, filename string) error {
+	return setIndexVisibility(ctx, owner, filename, true)
+}
+
+// MarkIndexPrivate 把 owner 的 filename 知识库恢复为私有
+func MarkIndexPrivate(ctx context.Context, owner, filename string) error {
+	return setIndexVisibility(ctx, owner, filename, false)
+}
+
+func setIndexVisibility(ctx context.Context, owner, filename string, public bool) error {
+	owner, filename = strings.TrimSpace(owner), strings.TrimSpace(filename)
+	if owner == "" || filename == "" {
+		return ErrEmptyIndexRef
+	}
+	var v model.IndexVisibility
+	return mysql.DB.WithContext(ctx).
+		Where("owner = ? AND filename = ?", owner, filename).
+		Assign(map[string]interface{}{"public": public}).
+		FirstOrCreate(&v).Error
+}
+
+// IsIndexPublic 知识库是否公开，没有记录时为私有
+func IsIndexPublic(ctx context.Context, owner, filename string) (bool, error) {
+	var count int64
+	err := mysql.DB.WithContext(ctx).Model(&model.IndexVisibility{}).
+		Where("owner = ? AND filename = ? AND public = ?", owner, filename, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListPublicIndexes 列出所有公开的知识库，按标记时间排序
+func ListPublicIndexes(ctx context.Context) ([]rag.IndexRef, error) {
+	var rows []*model.IndexVisibility
+	err := mysql.DB.WithContext(ctx).
+		Where("public = ?", true).
+		Order("updated_at ASC, id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]rag.IndexRef, len(rows))
+	for i, row := range rows {
+		refs[i] = rag.IndexRef{Owner: row.Owner, Filename: row.Filename}
+	}
+	return refs, nil
+}
+
+// DeleteUserIndexVisibility 删除用户所有知识库的可见性记录（知识库被替换或账号注销时调用）
+func DeleteUserIndexVisibility(ctx context.Context, owner string) error {
+	return mysql.DB.WithContext(ctx).Where("owner = ?", owner).Delete(&model.IndexVisibility{}).Error
+}
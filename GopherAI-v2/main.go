@@ -4,9 +4,11 @@ import (
 	"GopherAI/common/aihelper"
 	"GopherAI/common/mysql"
 	"GopherAI/common/rabbitmq"
+	"GopherAI/common/rag"
 	"GopherAI/common/redis"
 	"GopherAI/config"
 	"GopherAI/dao/message"
+	"GopherAI/dao/visibility"
 	"GopherAI/router"
 	"fmt"
 	"log"
@@ -53,17 +55,40 @@ func main() {
 	conf := config.GetConfig()
 	host := conf.MainConfig.Host
 	port := conf.MainConfig.Port
+	//检查配置，所有问题一次性列出
+	if err := conf.Validate(); err != nil {
+		log.Println("invalid config:\n" + err.Error())
+		return
+	}
+	//收到 SIGHUP 时重新加载配置
+	defer config.ReloadOnSIGHUP()()
+	//检查模型服务的 API Key
+	if err := rag.CheckAPIKeys(); err != nil {
+		log.Println("CheckAPIKeys error , " + err.Error())
+		return
+	}
 	//初始化mysql
 	if err := mysql.InitMysql(); err != nil {
 		log.Println("InitMysql error , " + err.Error())
 		return
 	}
+	//公开知识库的列表记录在mysql中
+	rag.SetPublicIndexLister(visibility.ListPublicIndexes)
 	//初始化AIHelperManager
 	readDataFromDB()
 
 	//初始化redis
-	redis.Init()
+	if err := redis.Init(); err != nil {
+		log.Println("InitRedis error , " + err.Error())
+		return
+	}
 	log.Println("redis init success  ")
+	defer func() {
+		if err := redis.CloseRedis(); err != nil {
+			log.Println("close redis error , " + err.Error())
+		}
+	}()
+	defer rag.CloseQueryPool()
 	rabbitmq.InitRabbitMQ()
 	log.Println("rabbitmq init success  ")
 
@@ -32,14 +32,16 @@ func Auth() gin.HandlerFunc {
 		}
 
 		log.Println("token is ", token)
-		userName, ok := myjwt.ParseToken(token)
-		if !ok {
+		claims, err := myjwt.ParseToken(token)
+		if err != nil {
+			log.Println("parse token failed: ", err)
 			c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidToken))
 			c.Abort()
 			return
 		}
 
-		c.Set("userName", userName)
+		c.Set("userName", claims.Username)
+		c.Set("userID", claims.ID)
 		c.Next()
 	}
 }
@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"GopherAI/config"
+	"GopherAI/model"
+	"GopherAI/utils/myjwt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthSetsUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conf := &config.Config{}
+	conf.Key = "test-key"
+	config.SetConfig(conf)
+
+	token, err := myjwt.IssueToken(&model.User{ID: 42, Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		query    string
+		wantID   any // nil 表示请求被拒绝
+		wantName string
+	}{
+		{"bearer header", "Bearer " + token, "", int64(42), "alice"},
+		{"query parameter", "", token, int64(42), "alice"},
+		{"missing token", "", "", nil, ""},
+		{"invalid token", "Bearer not-a-token", "", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID, gotName any
+			reached := false
+			r := gin.New()
+			r.GET("/", Auth(), func(c *gin.Context) {
+				reached = true
+				gotID, _ = c.Get("userID")
+				gotName, _ = c.Get("userName")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/?token="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			if reached != (tt.wantID != nil) {
+				t.Fatalf("handler reached = %v, want %v", reached, tt.wantID != nil)
+			}
+			if !reached {
+				return
+			}
+			if gotID != tt.wantID || gotName != tt.wantName {
+				t.Errorf("userID, userName = %v, %v, want %v, %v", gotID, gotName, tt.wantID, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// Feedback 用户对一条检索结果是否有帮助的反馈（点赞 / 点踩）
+type Feedback struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Query     string    `gorm:"type:text" json:"query"`
+	DocID     string    `gorm:"index;not null;type:varchar(255)" json:"doc_id"` // 检索结果中文档块的 ID
+	Helpful   bool      `gorm:"not null" json:"helpful"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DocumentHelpfulness 一个文档块收到的反馈汇总
+type DocumentHelpfulness struct {
+	DocID     string  `json:"doc_id"`
+	Helpful   int64   `json:"helpful"`
+	Unhelpful int64   `json:"unhelpful"`
+	Total     int64   `json:"total"`
+	Ratio     float64 `json:"ratio"` // 有帮助的反馈占比，0-1
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+)
+
+// IndexVisibility 知识库的可见性，按 所属用户 + 文件名 区分；没有记录的知识库是私有的
+type IndexVisibility struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Owner     string    `gorm:"uniqueIndex:idx_owner_filename;not null;type:varchar(50)" json:"owner"`
+	Filename  string    `gorm:"uniqueIndex:idx_owner_filename;not null;type:varchar(255)" json:"filename"`
+	Public    bool      `gorm:"index;not null" json:"public"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -4,11 +4,14 @@ import (
 	"GopherAI/middleware/jwt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func InitRouter() *gin.Engine {
 
 	r := gin.Default()
+	// Prometheus 采集 RAG 指标（注册在默认的 Registerer 上，见 rag.SetMetricsRegisterer）
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	enterRouter := r.Group("/api/v1")
 	{
 		RegisterUserRouter(enterRouter.Group("/user"))
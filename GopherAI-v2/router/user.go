@@ -2,6 +2,7 @@ package router
 
 import (
 	"GopherAI/controller/user"
+	"GopherAI/middleware/jwt"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,5 +12,14 @@ func RegisterUserRouter(r *gin.RouterGroup) {
 		r.POST("/register", user.Register)
 		r.POST("/login", user.Login)
 		r.POST("/captcha", user.HandleCaptcha)
+		r.POST("/refresh", user.Refresh)
+		r.POST("/logout", user.Logout)
+		r.POST("/password/reset/request", user.HandlePasswordResetRequest)
+		r.POST("/password/reset", user.ResetPassword)
+		//修改密码需要登录
+		r.POST("/password", jwt.Auth(), user.UpdatePassword)
+		//更换邮箱需要登录，新邮箱验证通过后才会生效
+		r.POST("/email/change/request", jwt.Auth(), user.HandleEmailChangeRequest)
+		r.POST("/email/change", jwt.Auth(), user.ConfirmEmailChange)
 	}
 }
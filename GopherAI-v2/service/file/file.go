@@ -3,6 +3,7 @@ package file
 import (
 	"GopherAI/common/rag"
 	"GopherAI/config"
+	"GopherAI/dao/visibility"
 	"GopherAI/utils"
 	"context"
 	"io"
@@ -10,19 +11,31 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-// 上传rag相关文件（这里只允许文本文件）
+// 上传rag相关文件（这里只允许文本文件、PDF 与 Word 文档）
 // 其实可以直接将其向量化进行保存，但这边依旧存储到服务器上以便后续可以在服务器上查看历史RAG文件
-func UploadRagFile(username string, file *multipart.FileHeader) (string, error) {
+// ctx 取消（例如客户端断开、服务关闭）时停止向量化
+func UploadRagFile(ctx context.Context, username string, file *multipart.FileHeader) (string, error) {
 	// 校验文件类型和文件名
 	if err := utils.ValidateFile(file); err != nil {
 		log.Printf("File validation failed: %v", err)
 		return "", err
 	}
 
+	// 上传会替换已有的知识库，在删除旧文件之前检查限流
+	if err := rag.CheckIndexRateLimit(ctx, username); err != nil {
+		log.Printf("Index rate limited for user %s: %v", username, err)
+		return "", err
+	}
+
 	// 创建用户目录
-	userDir := filepath.Join("uploads", username)
+	userDir, err := config.GetConfig().UserUploadDir(username)
+	if err != nil {
+		log.Printf("Invalid upload directory for user %s: %v", username, err)
+		return "", err
+	}
 	if err := os.MkdirAll(userDir, 0755); err != nil {
 		log.Printf("Failed to create user directory %s: %v", userDir, err)
 		return "", err
@@ -35,13 +48,17 @@ func UploadRagFile(username string, file *multipart.FileHeader) (string, error)
 			if !f.IsDir() {
 				filename := f.Name()
 				// 删除该文件对应的 Redis 索引
-				if err := rag.DeleteIndex(context.Background(), filename); err != nil {
+				if err := rag.DeleteIndex(ctx, username, filename); err != nil {
 					log.Printf("Failed to delete index for %s: %v", filename, err)
 					// 继续执行，不因为索引删除失败而中断文件上传
 				}
 			}
 		}
 	}
+	// 新上传的知识库默认是私有的，旧知识库的公开标记一并删除
+	if err := visibility.DeleteUserIndexVisibility(ctx, username); err != nil {
+		log.Printf("Failed to delete index visibility for user %s: %v", username, err)
+	}
 	// 删除用户目录中的所有文件
 	if err := utils.RemoveAllFilesInDir(userDir); err != nil {
 		log.Printf("Failed to clean user directory %s: %v", userDir, err)
@@ -79,23 +96,29 @@ func UploadRagFile(username string, file *multipart.FileHeader) (string, error)
 	log.Printf("File uploaded successfully: %s", filePath)
 
 	// 创建 RAG 索引器并对文件进行向量化
-	indexer, err := rag.NewRAGIndexer(filename, config.GetConfig().RagModelConfig.RagEmbeddingModel)
+	indexer, err := rag.NewRAGIndexer(ctx, username, filename, config.GetConfig().RagModelConfig.RagEmbeddingModel, 0)
 	if err != nil {
 		log.Printf("Failed to create RAG indexer: %v", err)
 		// 删除已上传的文件
 		os.Remove(filePath)
 		return "", err
 	}
+	defer indexer.Close()
 
-	// 读取文件内容并创建向量索引
-	if err := indexer.IndexFile(context.Background(), filePath); err != nil {
+	// 读取文件内容并创建向量索引（Markdown 文件按标题切块）
+	indexOpts := rag.IndexOptions{}
+	if strings.ToLower(ext) == ".md" {
+		indexOpts.Strategy = rag.ChunkStrategyMarkdown
+	}
+	chunks, err := indexer.IndexFile(ctx, filePath, indexOpts)
+	if err != nil {
 		log.Printf("Failed to index file: %v", err)
 		// 删除已上传的文件和索引
 		os.Remove(filePath)
-		rag.DeleteIndex(context.Background(), filename)
+		rag.DeleteIndex(context.Background(), username, filename)
 		return "", err
 	}
 
-	log.Printf("File indexed successfully: %s", filename)
+	log.Printf("File indexed successfully: %s (%d chunks)", filename, chunks)
 	return filePath, nil
 }
@@ -3,87 +3,452 @@ package user
 import (
 	"GopherAI/common/code"
 	myemail "GopherAI/common/email"
+	"GopherAI/common/rag"
 	myredis "GopherAI/common/redis"
+	"GopherAI/config"
 	"GopherAI/dao/user"
 	"GopherAI/model"
 	"GopherAI/utils"
 	"GopherAI/utils/myjwt"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
 )
 
-func Login(username, password string) (string, code.Code) {
+func Login(username, password string) (string, string, code.Code) {
 	var userInformation *model.User
 	var ok bool
 	//1:判断用户是否存在
 	if ok, userInformation = user.IsExistUser(username); !ok {
 
-		return "", code.CodeUserNotExist
+		return "", "", code.CodeUserNotExist
 	}
-	//2:判断用户是否密码账号正确
-	if userInformation.Password != utils.MD5(password) {
-		return "", code.CodeInvalidPassword
+	//2:连续多次密码错误的账号会被临时锁定（按账号计数，账号/邮箱登录共用）
+	if locked, _ := myredis.IsLocked(userInformation.Username); locked {
+		return "", "", code.CodeAccountLocked
 	}
-	//3:返回一个Token
-	token, err := myjwt.GenerateToken(userInformation.ID, userInformation.Username)
 
-	if err != nil {
-		return "", code.CodeServerBusy
+	//3:判断用户是否密码账号正确
+	if !utils.CheckPassword(userInformation.Password, password) {
+		if err := myredis.RecordFailedLogin(userInformation.Username); err != nil {
+			log.Printf("record failed login for %s failed: %v", userInformation.Username, err)
+		}
+		return "", "", code.CodeInvalidPassword
+	}
+	if err := myredis.ResetFailedLogin(userInformation.Username); err != nil {
+		log.Printf("reset failed login for %s failed: %v", userInformation.Username, err)
 	}
-	return token, code.CodeSuccess
+	//4:返回访问令牌和刷新令牌
+	token, refreshToken, code_ := issueSession(userInformation)
+	if code_ == code.CodeSuccess {
+		warmupRAG(userInformation.Username)
+	}
+	return token, refreshToken, code_
 }
 
-func Register(email, password, captcha string) (string, code.Code) {
+// 登录后预热 RAG 查询的超时时间
+const ragWarmupTimeout = 10 * time.Second
+
+// warmupRAG 配置了 warmupOnLogin 时在后台预热用户的 RAG 查询，不阻塞登录
+func warmupRAG(username string) {
+	if !config.GetConfig().RagModelConfig.RagWarmupOnLogin {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), ragWarmupTimeout)
+		defer cancel()
+		rag.Warmup(ctx, username)
+	}()
+}
+
+// 随机生成的账号与已有账号冲突时最多重新生成的次数
+const maxUsernameAttempts = 3
+
+func Register(email, password, captcha string) (string, string, code.Code) {
 
-	var ok bool
 	var userInformation *model.User
 
+	//0:校验邮箱格式和密码强度（在消耗验证码之前）
+	if code_ := validateRegisterParams(email, password); code_ != code.CodeSuccess {
+		return "", "", code_
+	}
+
 	//1:先判断用户是否已经存在了
 	if ok, _ := user.IsExistUser(email); ok {
-		return "", code.CodeUserExist
+		return "", "", code.CodeUserExist
 	}
 
 	//2:从redis中验证验证码是否有效
-	if ok, _ := myredis.CheckCaptchaForEmail(email, captcha); !ok {
-		return "", code.CodeInvalidCaptcha
+	if ok, _ := myredis.VerifyCode(email, captcha); !ok {
+		return "", "", code.CodeInvalidCaptcha
 	}
 
-	//3：生成11位的账号
-	username := utils.GetRandomNumbers(11)
-
-	//4：注册到数据库中
-	if userInformation, ok = user.Register(username, email, password); !ok {
-		return "", code.CodeServerBusy
+	//3：生成11位的账号，并注册到数据库中（账号冲突时重新生成）
+	var username string
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		username = utils.GetRandomNumbers(11)
+		var err error
+		userInformation, err = user.Register(username, email, password)
+		if err == nil {
+			break
+		}
+		var existsErr *user.UserExistsError
+		if errors.As(err, &existsErr) && existsErr.Field == "username" {
+			continue
+		}
+		if errors.Is(err, user.ErrUserExists) {
+			return "", "", code.CodeUserExist
+		}
+		if code_ := fieldErrorCode(err); code_ != code.CodeSuccess {
+			return "", "", code_
+		}
+		return "", "", code.CodeServerBusy
+	}
+	if userInformation == nil {
+		return "", "", code.CodeServerBusy
 	}
 
 	//5：将账号一并发送到对应邮箱上去，后续需要账号登录
-	if err := myemail.SendCaptcha(email, username, user.UserNameMsg); err != nil {
-		return "", code.CodeServerBusy
+	if err := myemail.GetMailer().SendUsername(email, username); err != nil {
+		return "", "", code.CodeServerBusy
 	}
 
-	// 6:生成Token
-	token, err := myjwt.GenerateToken(userInformation.ID, userInformation.Username)
+	// 6:生成访问令牌和刷新令牌
+	return issueSession(userInformation)
+}
 
-	if err != nil {
-		return "", code.CodeServerBusy
+// validateRegisterParams 校验注册时的邮箱和密码
+func validateRegisterParams(email, password string) code.Code {
+	if err := utils.ValidateEmail(user.NormalizeIdentifier(email)); err != nil {
+		return fieldErrorCode(err)
 	}
+	if err := utils.ValidatePassword(password); err != nil {
+		return fieldErrorCode(err)
+	}
+	return code.CodeSuccess
+}
 
-	return token, code.CodeSuccess
+// fieldErrorCode 将字段校验错误转换为对应的响应码，不是字段校验错误时返回 CodeSuccess
+func fieldErrorCode(err error) code.Code {
+	var fieldErr *utils.FieldError
+	if !errors.As(err, &fieldErr) {
+		return code.CodeSuccess
+	}
+	switch fieldErr.Field {
+	case "email":
+		return code.CodeInvalidEmail
+	case "password":
+		return code.CodeIllegalPassword
+	default:
+		return code.CodeInvalidParams
+	}
 }
 
 // 往指定邮箱发送验证码
 // 分为以下任务：
+// 0：检查发送频率，被限流时额外返回需要等待的时间
 // 1：先存放redis
 // 2：再进行远程发送
-func SendCaptcha(email_ string) code.Code {
-	send_code := utils.GetRandomNumbers(6)
-	//1:先存放到redis
-	if err := myredis.SetCaptchaForEmail(email_, send_code); err != nil {
-		return code.CodeServerBusy
+func SendCaptcha(email_ string) (time.Duration, code.Code) {
+	//0:同一邮箱发送频率限制
+	if err := myredis.CheckCaptchaRateLimit(email_); err != nil {
+		var limitErr *myredis.RateLimitError
+		if errors.As(err, &limitErr) {
+			return limitErr.RetryAfter, code.CodeTooManyRequests
+		}
+		return 0, code.CodeServerBusy
+	}
+
+	//1:先生成验证码并存放到redis
+	send_code, err := myredis.GenerateVerificationCode(email_)
+	if err != nil {
+		return 0, code.CodeServerBusy
 	}
 
 	//2:再进行远程发送
-	if err := myemail.SendCaptcha(email_, send_code, myemail.CodeMsg); err != nil {
+	if err := myemail.GetMailer().SendCode(email_, send_code); err != nil {
+		return 0, code.CodeServerBusy
+	}
+
+	return 0, code.CodeSuccess
+}
+
+// RequestPasswordReset 申请重置密码：邮箱对应的用户存在时发送重置验证码（15 分钟有效）
+// 无论邮箱是否注册都返回成功，避免通过该接口探测哪些邮箱已注册
+func RequestPasswordReset(email string) (time.Duration, code.Code) {
+	email = user.NormalizeIdentifier(email)
+
+	//0:与注册验证码共用发送频率限制
+	if err := myredis.CheckCaptchaRateLimit(email); err != nil {
+		var limitErr *myredis.RateLimitError
+		if errors.As(err, &limitErr) {
+			return limitErr.RetryAfter, code.CodeTooManyRequests
+		}
+		return 0, code.CodeServerBusy
+	}
+
+	//1:邮箱未注册时直接返回成功
+	ok, userInformation := user.IsExistUser(email)
+	if !ok || userInformation.Email != email {
+		return 0, code.CodeSuccess
+	}
+
+	//2:生成重置验证码并发送
+	resetCode, err := myredis.GeneratePasswordResetCode(email)
+	if err != nil {
+		return 0, code.CodeServerBusy
+	}
+	if err := myemail.GetMailer().SendResetCode(email, resetCode); err != nil {
+		return 0, code.CodeServerBusy
+	}
+	return 0, code.CodeSuccess
+}
+
+// ResetPassword 使用重置验证码设置新密码，成功后验证码失效，该账号所有的刷新令牌被注销
+func ResetPassword(email, resetCode, newPassword string) code.Code {
+	email = user.NormalizeIdentifier(email)
+
+	//1:先校验新密码强度，避免验证码被无效请求消耗
+	if err := utils.ValidatePassword(newPassword); err != nil {
+		return fieldErrorCode(err)
+	}
+
+	//2:校验重置验证码
+	ok, err := myredis.CheckPasswordResetCode(email, resetCode)
+	if err != nil {
+		return code.CodeServerBusy
+	}
+	if !ok {
+		return code.CodeInvalidCaptcha
+	}
+
+	//3:更新密码
+	exist, userInformation := user.IsExistUser(email)
+	if !exist {
+		return code.CodeInvalidCaptcha
+	}
+	if err := user.SetPassword(userInformation, newPassword); err != nil {
+		if code_ := fieldErrorCode(err); code_ != code.CodeSuccess {
+			return code_
+		}
+		return code.CodeServerBusy
+	}
+
+	//4:验证码只能使用一次
+	if err := myredis.DeletePasswordResetCode(email); err != nil {
+		log.Printf("delete password reset code for %s failed: %v", email, err)
+	}
+	return code.CodeSuccess
+}
+
+// UpdatePassword 已登录用户修改密码，成功后该账号所有的刷新令牌（包括当前设备）被注销，需要重新登录
+func UpdatePassword(username, oldPassword, newPassword string) code.Code {
+	err := user.UpdatePassword(username, oldPassword, newPassword)
+	switch {
+	case err == nil:
+		return code.CodeSuccess
+	case errors.Is(err, user.ErrUserNotFound):
+		return code.CodeUserNotExist
+	case errors.Is(err, user.ErrWrongPassword):
+		return code.CodeInvalidPassword
+	}
+	if code_ := fieldErrorCode(err); code_ != code.CodeSuccess {
+		return code_
+	}
+	log.Printf("update password for %s failed: %v", username, err)
+	return code.CodeServerBusy
+}
+
+// RequestEmailChange 已登录用户申请更换邮箱：校验新邮箱的格式和是否已被使用后，向新邮箱发送验证码（15 分钟有效）
+// 确认之前原邮箱保持不变，仍然可以用于登录和重置密码
+func RequestEmailChange(username, newEmail string) (time.Duration, code.Code) {
+	newEmail = user.NormalizeIdentifier(newEmail)
+
+	//0:校验新邮箱格式
+	if err := utils.ValidateEmail(newEmail); err != nil {
+		return 0, fieldErrorCode(err)
+	}
+	ok, userInformation := user.IsExistUser(username)
+	if !ok {
+		return 0, code.CodeUserNotExist
+	}
+	if userInformation.Email == newEmail {
+		return 0, code.CodeInvalidParams
+	}
+
+	//1:新邮箱不能已被其它账号使用
+	if err := user.CheckEmailAvailable(newEmail); err != nil {
+		if errors.Is(err, user.ErrUserExists) {
+			return 0, code.CodeUserExist
+		}
+		if code_ := fieldErrorCode(err); code_ != code.CodeSuccess {
+			return 0, code_
+		}
+		return 0, code.CodeServerBusy
+	}
+
+	//2:与注册验证码共用新邮箱的发送频率限制
+	if err := myredis.CheckCaptchaRateLimit(newEmail); err != nil {
+		var limitErr *myredis.RateLimitError
+		if errors.As(err, &limitErr) {
+			return limitErr.RetryAfter, code.CodeTooManyRequests
+		}
+		return 0, code.CodeServerBusy
+	}
+
+	//3:生成验证码并发送到新邮箱
+	changeCode, err := myredis.GenerateEmailChangeCode(userInformation.Username, newEmail)
+	if err != nil {
+		return 0, code.CodeServerBusy
+	}
+	if err := myemail.GetMailer().SendEmailChangeCode(newEmail, changeCode); err != nil {
+		return 0, code.CodeServerBusy
+	}
+	return 0, code.CodeSuccess
+}
+
+// ConfirmEmailChange 使用新邮箱收到的验证码确认更换邮箱，验证码正确后才更新邮箱，成功后验证码失效
+func ConfirmEmailChange(username, changeCode string) code.Code {
+	username = user.NormalizeIdentifier(username)
+
+	//1:校验验证码，取出申请时的新邮箱
+	newEmail, ok, err := myredis.CheckEmailChangeCode(username, changeCode)
+	if err != nil {
 		return code.CodeServerBusy
 	}
+	if !ok {
+		return code.CodeInvalidCaptcha
+	}
 
+	//2:更新邮箱（申请之后新邮箱可能已被其它账号注册）
+	err = user.UpdateEmail(username, newEmail)
+	switch {
+	case err == nil:
+	case errors.Is(err, user.ErrUserNotFound):
+		return code.CodeUserNotExist
+	case errors.Is(err, user.ErrUserExists):
+		if err := myredis.DeleteEmailChangeCode(username); err != nil {
+			log.Printf("delete email change code for %s failed: %v", username, err)
+		}
+		return code.CodeUserExist
+	default:
+		if code_ := fieldErrorCode(err); code_ != code.CodeSuccess {
+			return code_
+		}
+		log.Printf("update email for %s failed: %v", username, err)
+		return code.CodeServerBusy
+	}
+
+	//3:验证码只能使用一次
+	if err := myredis.DeleteEmailChangeCode(username); err != nil {
+		log.Printf("delete email change code for %s failed: %v", username, err)
+	}
 	return code.CodeSuccess
 }
+
+// issueSession 为用户签发访问令牌（JWT）并创建新的刷新令牌链
+func issueSession(u *model.User) (string, string, code.Code) {
+	token, err := myjwt.IssueToken(u)
+	if err != nil {
+		return "", "", code.CodeServerBusy
+	}
+	refreshToken, err := myredis.CreateRefreshToken(u.Username)
+	if err != nil {
+		log.Printf("create refresh token for %s failed: %v", u.Username, err)
+		return "", "", code.CodeServerBusy
+	}
+	return token, refreshToken, code.CodeSuccess
+}
+
+// RefreshSession 使用刷新令牌换取新的访问令牌，同时轮换刷新令牌（旧令牌失效）
+// 已轮换的刷新令牌被再次使用时，该登录会话的整条令牌链都会被注销
+func RefreshSession(refreshToken string) (string, string, code.Code) {
+	username, newRefreshToken, err := myredis.RotateRefreshToken(refreshToken)
+	if err != nil {
+		if errors.Is(err, myredis.ErrRefreshTokenReused) {
+			log.Printf("refresh token reuse detected, session revoked")
+		}
+		if errors.Is(err, myredis.ErrRefreshTokenInvalid) || errors.Is(err, myredis.ErrRefreshTokenReused) {
+			return "", "", code.CodeInvalidToken
+		}
+		return "", "", code.CodeServerBusy
+	}
+
+	//账号在此期间被删除时注销会话
+	ok, userInformation := user.IsExistUser(username)
+	if !ok || userInformation.Username != username {
+		if err := myredis.RevokeRefreshToken(newRefreshToken); err != nil {
+			log.Printf("revoke refresh token for %s failed: %v", username, err)
+		}
+		return "", "", code.CodeInvalidToken
+	}
+
+	token, err := myjwt.IssueToken(userInformation)
+	if err != nil {
+		return "", "", code.CodeServerBusy
+	}
+	return token, newRefreshToken, code.CodeSuccess
+}
+
+// Logout 注销刷新令牌，之后不能再用它换取访问令牌
+func Logout(refreshToken string) code.Code {
+	if err := myredis.RevokeRefreshToken(refreshToken); err != nil {
+		if errors.Is(err, myredis.ErrRefreshTokenInvalid) {
+			return code.CodeInvalidToken
+		}
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
+
+// SoftDeleteUser 软删除用户，并删除该用户所有知识库的向量索引
+// 上传的原始文件保留在服务器上；索引删除失败只记录日志，不影响账号删除
+func SoftDeleteUser(ctx context.Context, username string) error {
+	username = user.NormalizeIdentifier(username)
+	if err := user.SoftDeleteUser(username); err != nil {
+		return err
+	}
+	if err := deleteUserIndexes(ctx, username); err != nil {
+		log.Printf("delete indexes for deleted user %s failed: %v", username, err)
+	}
+	return nil
+}
+
+// DeleteAllUserData 删除用户的全部数据（注销账号 / 数据删除请求）：
+// 先删除所有知识库的向量索引和上传目录中的原始文件，再由 dao 删除 MySQL 和 Redis 中的数据（见 user.DeleteAllUserData）
+// 每一步都会尽量执行，失败的步骤合并成一个错误返回；可以重复调用
+func DeleteAllUserData(ctx context.Context, username string, hard bool) error {
+	username = user.NormalizeIdentifier(username)
+
+	var errs []error
+	if err := deleteUserIndexes(ctx, username); err != nil {
+		errs = append(errs, err)
+	}
+	if dir, err := config.GetConfig().UserUploadDir(username); err != nil {
+		errs = append(errs, fmt.Errorf("resolve upload dir: %w", err))
+	} else if err := os.RemoveAll(dir); err != nil {
+		errs = append(errs, fmt.Errorf("remove uploads: %w", err))
+	}
+	if err := user.DeleteAllUserData(ctx, username, hard); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// deleteUserIndexes 删除用户所有知识库的向量索引，已经不存在的索引不算失败
+func deleteUserIndexes(ctx context.Context, username string) error {
+	filenames, err := rag.ListIndexesForUser(ctx, username)
+	if err != nil {
+		return fmt.Errorf("list indexes: %w", err)
+	}
+	var errs []error
+	for _, filename := range filenames {
+		if err := rag.DeleteIndex(ctx, username, filename); err != nil && !errors.Is(err, rag.ErrIndexNotFound) {
+			errs = append(errs, fmt.Errorf("delete index %s: %w", filename, err))
+		}
+	}
+	return errors.Join(errs...)
+}
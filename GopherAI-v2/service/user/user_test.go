@@ -0,0 +1,331 @@
+package user
+
+import (
+	"GopherAI/common/code"
+	myemail "GopherAI/common/email"
+	"GopherAI/common/mysql"
+	"GopherAI/common/rag"
+	myredis "GopherAI/common/redis"
+	"GopherAI/common/redis/redistest"
+	"GopherAI/config"
+	"GopherAI/dao/user"
+	"GopherAI/model"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// recordingMailer 不发送邮件，只记录发往每个邮箱的最后一个更换邮箱验证码
+type recordingMailer struct {
+	myemail.NoopMailer
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (m *recordingMailer) SendEmailChangeCode(to, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[to] = code
+	return nil
+}
+
+func (m *recordingMailer) code(to string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.codes[to]
+}
+
+// testEnv 测试使用的 Redis（带 RediSearch 命令）、邮件发送器和上传目录
+type testEnv struct {
+	mailer    *recordingMailer
+	redis     *redistest.Server
+	uploadDir string
+}
+
+// setup 使用 SQLite 和 miniredis 替换 MySQL、Redis，并创建 alice 和 bob 两个用户
+func setup(t *testing.T) *testEnv {
+	t.Helper()
+	env := &testEnv{
+		mailer:    &recordingMailer{codes: map[string]string{}},
+		redis:     redistest.Run(t),
+		uploadDir: t.TempDir(),
+	}
+	conf := &config.Config{}
+	conf.UploadDir = env.uploadDir
+	config.SetConfig(conf)
+	user.SetLogger(nil)
+	myredis.SetLogger(nil)
+	rag.SetLogger(nil)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger:         gormlogger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(new(model.User), new(model.Session), new(model.IndexVisibility)); err != nil {
+		t.Fatal(err)
+	}
+	oldDB := mysql.DB
+	mysql.DB = db
+
+	oldRdb := myredis.Rdb
+	myredis.Rdb = env.redis.Client(t)
+
+	myemail.SetMailer(env.mailer)
+	t.Cleanup(func() {
+		myemail.SetMailer(nil)
+		myredis.Rdb = oldRdb
+		mysql.DB = oldDB
+	})
+
+	for _, u := range []*model.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", Email: "bob@example.com"},
+	} {
+		if _, err := mysql.InsertUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return env
+}
+
+func emailOf(t *testing.T, username string) string {
+	t.Helper()
+	ok, u := user.IsExistUser(username)
+	if !ok {
+		t.Fatalf("user %s not found", username)
+	}
+	return u.Email
+}
+
+func TestRequestEmailChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		newEmail string
+		want     code.Code
+	}{
+		{"valid", "alice", "alice@new.example.com", code.CodeSuccess},
+		{"normalized", "alice", "  Alice@New.Example.COM ", code.CodeSuccess},
+		{"taken by another user", "alice", "bob@example.com", code.CodeUserExist},
+		{"taken with different case", "alice", "BOB@Example.com", code.CodeUserExist},
+		{"same as current", "alice", "alice@example.com", code.CodeInvalidParams},
+		{"invalid email", "alice", "not-an-email", code.CodeInvalidEmail},
+		{"unknown user", "carol", "carol@example.com", code.CodeUserNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setup(t)
+			if _, got := RequestEmailChange(tt.username, tt.newEmail); got != tt.want {
+				t.Fatalf("RequestEmailChange = %v, want %v", got, tt.want)
+			}
+			sent := env.mailer.code(user.NormalizeIdentifier(tt.newEmail))
+			if (sent != "") != (tt.want == code.CodeSuccess) {
+				t.Errorf("code sent = %q, want sent only on success", sent)
+			}
+			// 确认之前原邮箱保持不变
+			if got := emailOf(t, "alice"); got != "alice@example.com" {
+				t.Errorf("email = %s before confirmation", got)
+			}
+		})
+	}
+}
+
+func TestRequestEmailChangeRateLimited(t *testing.T) {
+	setup(t)
+	if _, got := RequestEmailChange("alice", "alice@new.example.com"); got != code.CodeSuccess {
+		t.Fatalf("first request = %v", got)
+	}
+	retryAfter, got := RequestEmailChange("alice", "alice@new.example.com")
+	if got != code.CodeTooManyRequests || retryAfter <= 0 {
+		t.Errorf("second request = %v (retry after %v), want %v", got, retryAfter, code.CodeTooManyRequests)
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	const newEmail = "alice@new.example.com"
+
+	tests := []struct {
+		name string
+		// confirm 返回提交的验证码，sent 为发到新邮箱的验证码
+		confirm   func(t *testing.T, sent string) string
+		want      code.Code
+		wantEmail string
+	}{
+		{
+			name:      "correct code",
+			confirm:   func(t *testing.T, sent string) string { return sent },
+			want:      code.CodeSuccess,
+			wantEmail: newEmail,
+		},
+		{
+			name:      "code mismatch",
+			confirm:   func(t *testing.T, sent string) string { return wrongCode(sent) },
+			want:      code.CodeInvalidCaptcha,
+			wantEmail: "alice@example.com",
+		},
+		{
+			name:      "empty code",
+			confirm:   func(t *testing.T, sent string) string { return "" },
+			want:      code.CodeInvalidCaptcha,
+			wantEmail: "alice@example.com",
+		},
+		{
+			name: "too many mismatches",
+			confirm: func(t *testing.T, sent string) string {
+				for i := 0; i < 5; i++ {
+					if got := ConfirmEmailChange("alice", wrongCode(sent)); got != code.CodeInvalidCaptcha {
+						t.Fatalf("attempt %d = %v", i+1, got)
+					}
+				}
+				return sent
+			},
+			want:      code.CodeInvalidCaptcha,
+			wantEmail: "alice@example.com",
+		},
+		{
+			// 申请之后新邮箱被其它账号注册
+			name: "duplicate email",
+			confirm: func(t *testing.T, sent string) string {
+				if _, err := mysql.InsertUser(&model.User{Username: "carol", Email: newEmail}); err != nil {
+					t.Fatal(err)
+				}
+				return sent
+			},
+			want:      code.CodeUserExist,
+			wantEmail: "alice@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setup(t)
+			if _, got := RequestEmailChange("alice", newEmail); got != code.CodeSuccess {
+				t.Fatalf("RequestEmailChange = %v", got)
+			}
+			sent := env.mailer.code(newEmail)
+			if got := ConfirmEmailChange("alice", tt.confirm(t, sent)); got != tt.want {
+				t.Fatalf("ConfirmEmailChange = %v, want %v", got, tt.want)
+			}
+			if got := emailOf(t, "alice"); got != tt.wantEmail {
+				t.Errorf("email = %s, want %s", got, tt.wantEmail)
+			}
+			// 成功或新邮箱已被使用后验证码都会失效，不能再次使用
+			if tt.want != code.CodeInvalidCaptcha {
+				if got := ConfirmEmailChange("alice", sent); got != code.CodeInvalidCaptcha {
+					t.Errorf("reused code = %v, want %v", got, code.CodeInvalidCaptcha)
+				}
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChangeWithoutRequest(t *testing.T) {
+	setup(t)
+	if got := ConfirmEmailChange("alice", "123456"); got != code.CodeInvalidCaptcha {
+		t.Errorf("ConfirmEmailChange = %v, want %v", got, code.CodeInvalidCaptcha)
+	}
+}
+
+// wrongCode 返回与 code 不同的 6 位验证码
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+// addKnowledgeBase 为用户上传 filename 并建立带 n 个文档块的第 0 代索引，返回文档块的 key 前缀
+func (env *testEnv) addKnowledgeBase(t *testing.T, username, filename string, n int) string {
+	t.Helper()
+	dir := filepath.Join(env.uploadDir, username)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filename), []byte("# "+username), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prefix := myredis.GenerateIndexNamePrefix(username, filename)
+	env.redis.AddIndex(myredis.GenerateIndexName(username, filename), prefix)
+	for i := 0; i < n; i++ {
+		env.redis.HSet(fmt.Sprintf("%s%d", prefix, i), "content", username, "vector", "\x00\x00")
+	}
+	return prefix
+}
+
+func TestDeleteAllUserData(t *testing.T) {
+	ctx := context.Background()
+	for _, hard := range []bool{false, true} {
+		t.Run(fmt.Sprintf("hard=%v", hard), func(t *testing.T) {
+			env := setup(t)
+			alicePrefixes := []string{
+				env.addKnowledgeBase(t, "alice", "shared.md", 3),
+				env.addKnowledgeBase(t, "alice", "notes.txt", 2),
+			}
+			// bob 上传了同名文件，不受 alice 注销的影响
+			bobPrefix := env.addKnowledgeBase(t, "bob", "shared.md", 2)
+
+			if err := DeleteAllUserData(ctx, "alice", hard); err != nil {
+				t.Fatalf("DeleteAllUserData: %v", err)
+			}
+			// 索引连同文档块（内容、元数据和向量）一起删除
+			for _, prefix := range alicePrefixes {
+				if keys := env.redis.KeysWithPrefix(prefix); len(keys) != 0 {
+					t.Errorf("chunks left after account deletion: %v", keys)
+				}
+			}
+			if got := env.redis.Indexes(); len(got) != 1 || got[0] != myredis.GenerateIndexName("bob", "shared.md") {
+				t.Errorf("indexes = %v, want only bob's", got)
+			}
+			if keys := env.redis.KeysWithPrefix(bobPrefix); len(keys) != 2 {
+				t.Errorf("bob's chunks = %v, want 2", keys)
+			}
+			if _, err := os.Stat(filepath.Join(env.uploadDir, "alice")); !os.IsNotExist(err) {
+				t.Errorf("upload dir still exists: %v", err)
+			}
+			if ok, _ := user.IsExistUser("alice"); ok {
+				t.Error("alice still exists")
+			}
+
+			// 可以重复调用；已经不存在的索引不算失败
+			if err := DeleteAllUserData(ctx, "alice", hard); err != nil {
+				t.Errorf("second DeleteAllUserData: %v", err)
+			}
+			if err := rag.DeleteIndex(ctx, "alice", "shared.md"); !errors.Is(err, rag.ErrIndexNotFound) {
+				t.Errorf("DeleteIndex of deleted index = %v, want ErrIndexNotFound", err)
+			}
+		})
+	}
+}
+
+func TestSoftDeleteUserDropsIndexes(t *testing.T) {
+	env := setup(t)
+	alicePrefix := env.addKnowledgeBase(t, "alice", "shared.md", 3)
+	bobPrefix := env.addKnowledgeBase(t, "bob", "shared.md", 2)
+
+	if err := SoftDeleteUser(context.Background(), "Alice"); err != nil {
+		t.Fatalf("SoftDeleteUser: %v", err)
+	}
+	if ok, _ := user.IsExistUser("alice"); ok {
+		t.Error("alice still exists")
+	}
+	// 向量索引和文档块都被删除，上传的原始文件保留
+	if keys := env.redis.KeysWithPrefix(alicePrefix); len(keys) != 0 {
+		t.Errorf("orphaned chunks left: %v", keys)
+	}
+	if _, err := os.Stat(filepath.Join(env.uploadDir, "alice", "shared.md")); err != nil {
+		t.Errorf("uploaded file removed: %v", err)
+	}
+	if keys := env.redis.KeysWithPrefix(bobPrefix); len(keys) != 2 {
+		t.Errorf("bob's chunks = %v, want 2", keys)
+	}
+}
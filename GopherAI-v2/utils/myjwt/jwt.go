@@ -2,9 +2,22 @@ package myjwt
 
 import (
 	"GopherAI/config"
+	"GopherAI/model"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 未配置 expire_duration 时 token 的默认有效期
+const defaultTokenTTL = 24 * time.Hour
+
+var (
+	// ErrTokenExpired token 已过期，需要重新登录
+	ErrTokenExpired = errors.New("token is expired")
+	// ErrTokenMalformed token 格式错误、签名不匹配或签名算法不受支持
+	ErrTokenMalformed = errors.New("token is malformed")
 )
 
 type Claims struct {
@@ -13,15 +26,28 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(id int64, username string) (string, error) {
+// tokenTTL 返回配置的 token 有效期（expire_duration，单位小时）
+func tokenTTL() time.Duration {
+	if hours := config.GetConfig().ExpireDuration; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultTokenTTL
+}
+
+// IssueToken 为用户签发 JWT，包含用户 ID、账号、签发时间与过期时间
+func IssueToken(user *model.User) (string, error) {
+	if user == nil {
+		return "", errors.New("issue token: nil user")
+	}
+	now := time.Now()
 	claims := Claims{
-		ID:       id,
-		Username: username,
+		ID:       user.ID,
+		Username: user.Username,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.GetConfig().ExpireDuration) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL())),
 			Issuer:    config.GetConfig().Issuer,
 			Subject:   config.GetConfig().Subject,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
@@ -30,14 +56,25 @@ func GenerateToken(id int64, username string) (string, error) {
 	return token.SignedString([]byte(config.GetConfig().Key))
 }
 
-// ParseToken 解析Token
-func ParseToken(token string) (string, bool) {
+// ParseToken 解析Token，校验签名与有效期
+// 过期时返回 ErrTokenExpired，其它无效情况（包括没有过期时间）返回 ErrTokenMalformed（均可用 errors.Is 判断）
+func ParseToken(token string) (*Claims, error) {
 	claims := new(Claims)
 	t, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
 		return []byte(config.GetConfig().Key), nil
-	})
-	if !t.Valid || err != nil || claims == nil {
-		return "", false
+	},
+		// 只接受签发时使用的 HS256，防止算法替换攻击
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("%w: %v", ErrTokenExpired, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+	if t == nil || !t.Valid {
+		return nil, ErrTokenMalformed
 	}
-	return claims.Username, true
+	return claims, nil
 }
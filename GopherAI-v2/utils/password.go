@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword 使用 bcrypt 对密码加盐哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验密码是否与存储的哈希匹配
+// 兼容早期使用 MD5 存储的密码（bcrypt 哈希以 "$2" 开头）
+func CheckPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	return hash == MD5(password)
+}
@@ -86,12 +86,14 @@ func RemoveAllFilesInDir(dir string) error {
 	return nil
 }
 
-// ValidateFile 校验文件是否为允许的文本文件（.md 或 .txt）
+// ValidateFile 校验文件是否为允许的文档类型（.md、.txt、.pdf、.docx、.csv 或 .tsv）
 func ValidateFile(file *multipart.FileHeader) error {
 	// 校验文件扩展名
 	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if ext != ".md" && ext != ".txt" {
-		return fmt.Errorf("文件类型不正确，只允许 .md 或 .txt 文件，当前扩展名: %s", ext)
+	switch ext {
+	case ".md", ".txt", ".pdf", ".docx", ".csv", ".tsv":
+	default:
+		return fmt.Errorf("文件类型不正确，只允许 .md、.txt、.pdf、.docx、.csv 或 .tsv 文件，当前扩展名: %s", ext)
 	}
 
 	return nil
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// 邮箱最大长度，与 users.email 列的长度一致
+	maxEmailLength = 100
+	// 密码长度限制
+	minPasswordLength = 8
+	maxPasswordLength = 64
+	// bcrypt 只接受不超过 72 字节的密码，中文、emoji 等字符每个占 3-4 字节
+	maxPasswordBytes = 72
+)
+
+// FieldError 某个字段校验不通过，Field 为字段名（email / password），Reason 为原因
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// ValidateEmail 校验邮箱格式，只接受形如 user@example.com 的纯地址（不带显示名）
+func ValidateEmail(email string) error {
+	if email == "" {
+		return &FieldError{Field: "email", Reason: "邮箱不能为空"}
+	}
+	if len(email) > maxEmailLength {
+		return &FieldError{Field: "email", Reason: fmt.Sprintf("邮箱长度不能超过 %d", maxEmailLength)}
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return &FieldError{Field: "email", Reason: "邮箱格式不正确"}
+	}
+	// mail.ParseAddress 允许 user@localhost 这类地址，这里要求域名中带有点
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return &FieldError{Field: "email", Reason: "邮箱域名不正确"}
+	}
+	return nil
+}
+
+// ValidatePassword 校验密码强度：长度 8-64 位且不超过 72 字节，至少包含一个字母和一个数字
+func ValidatePassword(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < minPasswordLength {
+		return &FieldError{Field: "password", Reason: fmt.Sprintf("密码长度不能少于 %d 位", minPasswordLength)}
+	}
+	if length > maxPasswordLength {
+		return &FieldError{Field: "password", Reason: fmt.Sprintf("密码长度不能超过 %d 位", maxPasswordLength)}
+	}
+	if len(password) > maxPasswordBytes {
+		return &FieldError{Field: "password", Reason: fmt.Sprintf("密码不能超过 %d 字节（中文等字符每个占多个字节）", maxPasswordBytes)}
+	}
+
+	var hasLetter, hasDigit bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLetter(c):
+			hasLetter = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return &FieldError{Field: "password", Reason: "密码必须同时包含字母和数字"}
+	}
+	return nil
+}
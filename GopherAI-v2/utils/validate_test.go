@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"alice@example.com", true},
+		{"alice.smith+tag@mail.example.co", true},
+		{"", false},
+		{"alice", false},
+		{"alice@", false},
+		{"@example.com", false},
+		{"alice@localhost", false},
+		{"alice@.example.com", false},
+		{"alice@example.com.", false},
+		{"Alice <alice@example.com>", false},
+		{" alice@example.com", false},
+		{"alice@exa mple.com", false},
+		{strings.Repeat("a", 90) + "@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			err := ValidateEmail(tt.email)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("ValidateEmail(%q) = %v, want nil", tt.email, err)
+				}
+				return
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != "email" {
+				t.Errorf("ValidateEmail(%q) = %v, want email FieldError", tt.email, err)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"letters and digits", "secret123", true},
+		{"minimum length", "abcdef12", true},
+		{"maximum length", strings.Repeat("a", 63) + "1", true},
+		{"unicode letters", "密码密码密码12", true},
+		// bcrypt 最多接受 72 字节，多字节字符按字节计算
+		{"maximum bytes", strings.Repeat("密", 23) + "a1", true},
+		{"too many bytes", strings.Repeat("密", 24) + "1", false},
+		{"emoji within rune limit", strings.Repeat("😀", 60) + "a1", false},
+		{"too short", "abc1234", false},
+		{"too long", strings.Repeat("a", 64) + "1", false},
+		{"letters only", "abcdefgh", false},
+		{"digits only", "12345678", false},
+		{"symbols and digits", "!!!!1234", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePassword(tt.password)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("ValidatePassword(%q) = %v, want nil", tt.password, err)
+				}
+				// 校验通过的密码一定能被 bcrypt 哈希
+				if _, err := HashPassword(tt.password); err != nil {
+					t.Errorf("HashPassword(%q) = %v", tt.password, err)
+				}
+				return
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != "password" {
+				t.Errorf("ValidatePassword(%q) = %v, want password FieldError", tt.password, err)
+			}
+		})
+	}
+}